@@ -0,0 +1,90 @@
+package alerts
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Alert is an operational notice for branch staff, such as an item running
+// low on stock.
+type Alert struct {
+	Type      string    `json:"type"`
+	BranchID  uint      `json:"branch_id"`
+	Subject   string    `json:"subject"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notifier delivers alerts to whoever needs to act on them.
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+var (
+	mu       sync.RWMutex
+	notifier Notifier = LogNotifier{}
+)
+
+// SetNotifier replaces the notifier used by Send.
+func SetNotifier(n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	notifier = n
+}
+
+// Send delivers an alert through the configured notifier. Delivery failures
+// are logged rather than returned so they never fail the operation that
+// raised the alert.
+func Send(alert Alert) {
+	if alert.CreatedAt.IsZero() {
+		alert.CreatedAt = time.Now()
+	}
+
+	mu.RLock()
+	n := notifier
+	mu.RUnlock()
+
+	if err := n.Notify(alert); err != nil {
+		log.Printf("Failed to send %s alert: %v", alert.Type, err)
+	}
+}
+
+// LogNotifier writes alerts to the standard logger.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(alert Alert) error {
+	log.Printf("[alert] %s (branch %d): %s", alert.Subject, alert.BranchID, alert.Message)
+	return nil
+}
+
+// FileNotifier appends alerts to a file as JSON lines.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (f *FileNotifier) Notify(alert Alert) error {
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,9 @@
+package config
+
+import "os"
+
+// AlertLogFile is the file alerts are appended to. When empty, alerts are
+// written to the application log.
+func AlertLogFile() string {
+	return os.Getenv("ALERT_LOG_FILE")
+}
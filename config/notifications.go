@@ -0,0 +1,15 @@
+package config
+
+import "os"
+
+// NotificationOutboxFile is where customer messages are written when no
+// gateway is configured. When empty, messages only go to the application log.
+func NotificationOutboxFile() string {
+	return os.Getenv("NOTIFY_OUTBOX_FILE")
+}
+
+// NotificationWebhookToken authenticates delivery receipts posted by the
+// messaging gateway. The webhook is disabled while it is empty.
+func NotificationWebhookToken() string {
+	return os.Getenv("NOTIFY_WEBHOOK_TOKEN")
+}
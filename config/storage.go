@@ -0,0 +1,54 @@
+package config
+
+import (
+	"laundre/storage"
+	"os"
+	"strconv"
+)
+
+// StorageDir is where uploaded files are kept when using local storage.
+func StorageDir() string {
+	if dir := os.Getenv("STORAGE_DIR"); dir != "" {
+		return dir
+	}
+	return "./uploads"
+}
+
+// StorageBackend selects where uploads are stored: "local" (default) or
+// "s3" for any S3-compatible service configured through the S3_* variables.
+func StorageBackend() string {
+	if backend := os.Getenv("STORAGE_BACKEND"); backend != "" {
+		return backend
+	}
+	return "local"
+}
+
+func S3() storage.S3Config {
+	return storage.S3Config{
+		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    os.Getenv("S3_BUCKET"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+	}
+}
+
+// MaxUploadMB caps the size of a single upload, 10 MB unless
+// MAX_UPLOAD_MB says otherwise.
+func MaxUploadMB() int64 {
+	if mb, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_MB"), 10, 64); err == nil && mb > 0 {
+		return mb
+	}
+	return 10
+}
+
+// FileURLSecret signs time-limited download links.
+func FileURLSecret() string {
+	return os.Getenv("FILE_URL_SECRET")
+}
+
+// PublicURL is prepended to signed download links, e.g.
+// "https://laundre.example.com". Links are relative when it is empty.
+func PublicURL() string {
+	return os.Getenv("PUBLIC_URL")
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"laundre/models"
+	"laundre/notifications"
+	"laundre/scheduler"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errAbandoned = errors.New("order cannot be disposed")
+
+func RunAbandonedLaundry(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		queued, err := scheduler.RunAbandonedLaundry(db, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process abandoned laundry", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Abandoned laundry processed", "queued": queued})
+	}
+}
+
+// GetAbandonedOrders lists finished orders still waiting for pickup for at
+// least min_days (default: the branch's reminder threshold), with how long
+// they have waited, where they are in the branch policy and which notices
+// the customer was sent.
+func GetAbandonedOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		minDays := -1
+		if value := c.Query("min_days"); value != "" {
+			days, err := strconv.Atoi(value)
+			if err != nil || days < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "min_days must be a non-negative number"})
+				return
+			}
+			minDays = days
+		}
+
+		query := db.Preload("Branch").Preload("Customer").
+			Where("status = ? AND completed_at IS NOT NULL AND picked_up_at IS NULL AND disposed_at IS NULL", "done")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+
+		var orders []models.Order
+		if err := query.Order("completed_at asc").Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders", "details": err.Error()})
+			return
+		}
+
+		ids := make([]uint, 0, len(orders))
+		for _, order := range orders {
+			ids = append(ids, order.ID)
+		}
+
+		var notices []models.Notification
+		if err := db.Select("order_id, event, status, created_at").
+			Where("order_id IN ? AND event IN ?", ids,
+				[]string{notifications.EventPickupReminder, notifications.EventFinalNotice}).
+			Find(&notices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notices", "details": err.Error()})
+			return
+		}
+		sent := map[uint][]gin.H{}
+		for _, notice := range notices {
+			sent[*notice.OrderID] = append(sent[*notice.OrderID], gin.H{
+				"event":      notice.Event,
+				"status":     notice.Status,
+				"created_at": notice.CreatedAt,
+			})
+		}
+
+		var unpaid []struct {
+			OrderID    uint
+			TotalPrice float64
+		}
+		if err := db.Model(&models.Transaction{}).Select("order_id, total_price").
+			Where("order_id IN ? AND payment_status = ?", ids, "unpaid").Scan(&unpaid).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions", "details": err.Error()})
+			return
+		}
+		outstanding := map[uint]float64{}
+		for _, row := range unpaid {
+			outstanding[row.OrderID] = row.TotalPrice
+		}
+
+		now := time.Now()
+		rows := []gin.H{}
+		for _, order := range orders {
+			days := scheduler.DaysSince(order.DoneSince(), now)
+			threshold := minDays
+			if threshold < 0 {
+				threshold = order.Branch.PickupReminderDays
+			}
+			if days < threshold {
+				continue
+			}
+
+			rows = append(rows, gin.H{
+				"order_id":        order.ID,
+				"branch_id":       order.BranchID,
+				"branch_name":     order.Branch.Name,
+				"customer_id":     order.CustomerID,
+				"customer_name":   order.Customer.Name,
+				"customer_phone":  order.Customer.Phone,
+				"service":         order.Service,
+				"done_at":         order.DoneSince(),
+				"days_since_done": days,
+				"stage":           order.Branch.AbandonmentStage(days),
+				"outstanding":     outstanding[order.ID],
+				"notices":         sent[order.ID],
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": rows})
+	}
+}
+
+// DisposeOrder closes an uncollected order with what was done with the
+// laundry. The branch's disposal period must have passed; admins may record
+// an earlier outcome, e.g. when the customer gave the laundry up.
+func DisposeOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Outcome string `json:"outcome" binding:"required,oneof=disposed donated sold returned"`
+			Notes   string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var order models.Order
+		if err := db.First(&order, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if !canAccessBranch(c, order.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		message := ""
+		var disposition models.OrderDisposition
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Branch").First(&order, order.ID).Error; err != nil {
+				return err
+			}
+			if order.Status != "done" || order.PickedUpAt != nil || order.DisposedAt != nil {
+				message = "Only finished orders that were never picked up can be disposed"
+				return errAbandoned
+			}
+
+			now := time.Now()
+			days := scheduler.DaysSince(order.DoneSince(), now)
+			if !isAdmin(c) && (order.CompletedAt == nil || order.Branch.DisposalDays == 0 || days < order.Branch.DisposalDays) {
+				message = "The branch disposal period has not passed yet"
+				return errAbandoned
+			}
+
+			disposition = models.OrderDisposition{
+				OrderID:       order.ID,
+				BranchID:      order.BranchID,
+				Outcome:       req.Outcome,
+				Notes:         req.Notes,
+				DaysSinceDone: days,
+				RecordedBy:    currentUserID(c),
+			}
+			if err := tx.Create(&disposition).Error; err != nil {
+				return err
+			}
+
+			order.DisposedAt = &now
+			return tx.Model(&order).Update("disposed_at", now).Error
+		})
+
+		if err != nil {
+			if err == errAbandoned {
+				c.JSON(http.StatusConflict, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dispose order", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order closed as abandoned", "data": disposition})
+	}
+}
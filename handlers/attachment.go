@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"laundre/config"
+	"laundre/models"
+	"laundre/storage"
+	"laundre/utils"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var maxUploadSize = config.MaxUploadMB() << 20
+
+const thumbnailSize = 320
+
+var uploadExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// saveUpload stores the multipart "file" field and records it as an
+// attachment of the given owner. It writes the error response itself and
+// reports whether the upload succeeded.
+func saveUpload(c *gin.Context, db *gorm.DB, store storage.Storage, ownerType string, ownerID uint) (models.Attachment, bool) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required", "details": err.Error()})
+		return models.Attachment{}, false
+	}
+
+	if fileHeader.Size > maxUploadSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File exceeds the %d MB limit", maxUploadSize>>20)})
+		return models.Attachment{}, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file", "details": err.Error()})
+		return models.Attachment{}, false
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType := http.DetectContentType(head[:n])
+	extension, ok := uploadExtensions[contentType]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file type: " + contentType})
+		return models.Attachment{}, false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file", "details": err.Error()})
+		return models.Attachment{}, false
+	}
+
+	key := fmt.Sprintf("%s/%d/%s%s", ownerType, ownerID, randomToken(16), extension)
+	if err := store.Save(key, file); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file", "details": err.Error()})
+		return models.Attachment{}, false
+	}
+
+	thumbnailKey := ""
+	if strings.HasPrefix(contentType, "image/") {
+		thumbnailKey = saveThumbnail(store, file, key)
+	}
+
+	attachment := models.Attachment{
+		OwnerType:    ownerType,
+		OwnerID:      ownerID,
+		FileName:     filepath.Base(fileHeader.Filename),
+		ContentType:  contentType,
+		Size:         fileHeader.Size,
+		StorageKey:   key,
+		ThumbnailKey: thumbnailKey,
+		UploadedBy:   currentUserID(c),
+	}
+
+	if err := db.Create(&attachment).Error; err != nil {
+		removeStoredFiles(store, attachment)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment", "details": err.Error()})
+		return models.Attachment{}, false
+	}
+
+	return attachment, true
+}
+
+// saveThumbnail stores a preview of the uploaded image next to it. A picture
+// that cannot be decoded is still kept, just without a preview.
+func saveThumbnail(store storage.Storage, file io.ReadSeeker, key string) string {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+
+	thumbnail, err := utils.Thumbnail(file, thumbnailSize)
+	if err != nil {
+		log.Println("Failed to create thumbnail for", key, err)
+		return ""
+	}
+
+	thumbnailKey := strings.TrimSuffix(key, filepath.Ext(key)) + "_thumb.jpg"
+	if err := store.Save(thumbnailKey, bytes.NewReader(thumbnail)); err != nil {
+		log.Println("Failed to store thumbnail for", key, err)
+		return ""
+	}
+	return thumbnailKey
+}
+
+// removeStoredFiles deletes an attachment's file and its thumbnail from
+// storage.
+func removeStoredFiles(store storage.Storage, attachment models.Attachment) error {
+	if attachment.ThumbnailKey != "" {
+		if err := store.Delete(attachment.ThumbnailKey); err != nil {
+			return err
+		}
+	}
+	return store.Delete(attachment.StorageKey)
+}
+
+func serveAttachment(c *gin.Context, store storage.Storage, attachment models.Attachment) {
+	if c.Query("variant") == "thumbnail" {
+		serveThumbnail(c, store, attachment)
+		return
+	}
+
+	reader, err := store.Open(attachment.StorageKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file", "details": err.Error()})
+		}
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition": "inline; filename=" + strconv.Quote(attachment.FileName),
+	})
+}
+
+func deleteAttachment(db *gorm.DB, store storage.Storage, attachment models.Attachment) error {
+	if err := db.Delete(&attachment).Error; err != nil {
+		return err
+	}
+	return removeStoredFiles(store, attachment)
+}
+
+func serveThumbnail(c *gin.Context, store storage.Storage, attachment models.Attachment) {
+	if attachment.ThumbnailKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment has no thumbnail"})
+		return
+	}
+
+	reader, err := store.Open(attachment.ThumbnailKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file", "details": err.Error()})
+		}
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "image/jpeg", reader, nil)
+}
+
+// attachmentBranchID resolves the branch that owns an attachment so access
+// can be checked without knowing the owner type up front.
+func attachmentBranchID(db *gorm.DB, attachment models.Attachment) (uint, error) {
+	var branchID uint
+	var err error
+	switch attachment.OwnerType {
+	case "expense":
+		err = db.Model(&models.Expense{}).Where("id = ?", attachment.OwnerID).Pluck("branch_id", &branchID).Error
+	case "order_item":
+		err = db.Model(&models.OrderItem{}).Joins("JOIN orders ON orders.id = order_items.order_id").
+			Where("order_items.id = ?", attachment.OwnerID).Pluck("orders.branch_id", &branchID).Error
+	default:
+		return 0, fmt.Errorf("unknown attachment owner %q", attachment.OwnerType)
+	}
+	if err == nil && branchID == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	return branchID, err
+}
+
+// signedFileURL links to the public file route. Backends that presign their
+// own URLs are used directly so downloads do not pass through the API.
+func signedFileURL(store storage.Storage, attachment models.Attachment, variant string, ttl time.Duration) (string, error) {
+	key := attachment.StorageKey
+	if variant == "thumbnail" {
+		key = attachment.ThumbnailKey
+	}
+	if presigner, ok := store.(storage.Presigner); ok {
+		return presigner.PresignGet(key, ttl)
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	url := fmt.Sprintf("%s/files/%d?expires=%d&signature=%s",
+		config.PublicURL(), attachment.ID, expires, utils.SignFile(attachment.ID, variant, expires))
+	if variant != "" {
+		url += "&variant=" + variant
+	}
+	return url, nil
+}
+
+// GetAttachmentURL issues time-limited download links for an attachment and
+// its thumbnail. ttl is in minutes, 15 by default and at most a day.
+func GetAttachmentURL(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var attachment models.Attachment
+		if err := db.First(&attachment, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+
+		branchID, err := attachmentBranchID(db, attachment)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment owner not found"})
+			return
+		}
+		if !canAccessBranch(c, branchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		minutes, err := strconv.Atoi(c.DefaultQuery("ttl", "15"))
+		if err != nil || minutes <= 0 || minutes > 24*60 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must be between 1 and 1440 minutes"})
+			return
+		}
+		ttl := time.Duration(minutes) * time.Minute
+
+		url, err := signedFileURL(store, attachment, "", ttl)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download link", "details": err.Error()})
+			return
+		}
+
+		var thumbnailURL string
+		if attachment.ThumbnailKey != "" {
+			if thumbnailURL, err = signedFileURL(store, attachment, "thumbnail", ttl); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download link", "details": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"url":           url,
+			"thumbnail_url": thumbnailURL,
+			"expires_at":    time.Now().Add(ttl),
+		})
+	}
+}
+
+// DownloadSignedFile serves an attachment to anyone holding a valid, unexpired
+// link from GetAttachmentURL. It sits outside the authenticated API so links
+// can be opened from a browser or shared with a customer.
+func DownloadSignedFile(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+		if err != nil || time.Now().Unix() > expires {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Download link has expired"})
+			return
+		}
+
+		variant := c.Query("variant")
+		if !utils.VerifyFile(uint(id), variant, expires, c.Query("signature")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid download link"})
+			return
+		}
+
+		var attachment models.Attachment
+		if err := db.First(&attachment, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+
+		serveAttachment(c, store, attachment)
+	}
+}
@@ -9,15 +9,56 @@ import (
 )
 
 type CreateBranchRequest struct {
-	Name    string `json:"name" binding:"required"`
-	Address string `json:"address" binding:"required"`
-	Phone   string `json:"phone" binding:"required"`
+	Name                     string  `json:"name" binding:"required"`
+	Address                  string  `json:"address" binding:"required"`
+	Phone                    string  `json:"phone" binding:"required"`
+	ExpenseApprovalThreshold float64 `json:"expense_approval_threshold" binding:"omitempty,min=0"`
+	ReminderDays             *string `json:"reminder_days"`
+	EscalateAfterDays        *int    `json:"escalate_after_days" binding:"omitempty,min=0"`
+	PickupReminderDays       *int    `json:"pickup_reminder_days" binding:"omitempty,min=0"`
+	FinalNoticeDays          *int    `json:"final_notice_days" binding:"omitempty,min=0"`
+	DisposalDays             *int    `json:"disposal_days" binding:"omitempty,min=0"`
 }
 
 type UpdateBranchRequest struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
-	Phone   string `json:"phone"`
+	Name                     string   `json:"name"`
+	Address                  string   `json:"address"`
+	Phone                    string   `json:"phone"`
+	ExpenseApprovalThreshold *float64 `json:"expense_approval_threshold" binding:"omitempty,min=0"`
+	ReminderDays             *string  `json:"reminder_days"`
+	EscalateAfterDays        *int     `json:"escalate_after_days" binding:"omitempty,min=0"`
+	PickupReminderDays       *int     `json:"pickup_reminder_days" binding:"omitempty,min=0"`
+	FinalNoticeDays          *int     `json:"final_notice_days" binding:"omitempty,min=0"`
+	DisposalDays             *int     `json:"disposal_days" binding:"omitempty,min=0"`
+}
+
+// applyAbandonment copies the abandoned laundry settings that were given
+// onto the branch.
+func applyAbandonment(branch *models.Branch, pickupReminderDays, finalNoticeDays, disposalDays *int) {
+	if pickupReminderDays != nil {
+		branch.PickupReminderDays = *pickupReminderDays
+	}
+	if finalNoticeDays != nil {
+		branch.FinalNoticeDays = *finalNoticeDays
+	}
+	if disposalDays != nil {
+		branch.DisposalDays = *disposalDays
+	}
+}
+
+// applyDunning copies the reminder settings onto the branch, rejecting
+// schedules that cannot be parsed.
+func applyDunning(branch *models.Branch, reminderDays *string, escalateAfterDays *int) error {
+	if reminderDays != nil {
+		if _, err := models.ParseReminderDays(*reminderDays); err != nil {
+			return err
+		}
+		branch.ReminderDays = *reminderDays
+	}
+	if escalateAfterDays != nil {
+		branch.EscalateAfterDays = *escalateAfterDays
+	}
+	return nil
 }
 
 func CreateBranch(db *gorm.DB) gin.HandlerFunc {
@@ -29,10 +70,21 @@ func CreateBranch(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		branch := models.Branch{
-			Name:    req.Name,
-			Address: req.Address,
-			Phone:   req.Phone,
+			Name:                     req.Name,
+			Address:                  req.Address,
+			Phone:                    req.Phone,
+			ExpenseApprovalThreshold: req.ExpenseApprovalThreshold,
+			ReminderDays:             models.DefaultReminderDays,
+			EscalateAfterDays:        models.DefaultEscalateAfterDays,
+			PickupReminderDays:       models.DefaultPickupReminderDays,
+			FinalNoticeDays:          models.DefaultFinalNoticeDays,
+			DisposalDays:             models.DefaultDisposalDays,
+		}
+		if err := applyDunning(&branch, req.ReminderDays, req.EscalateAfterDays); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
+		applyAbandonment(&branch, req.PickupReminderDays, req.FinalNoticeDays, req.DisposalDays)
 
 		if err := db.Create(&branch).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create branch"})
@@ -90,6 +142,14 @@ func UpdateBranch(db *gorm.DB) gin.HandlerFunc {
 		if req.Phone != "" {
 			branch.Phone = req.Phone
 		}
+		if req.ExpenseApprovalThreshold != nil {
+			branch.ExpenseApprovalThreshold = *req.ExpenseApprovalThreshold
+		}
+		if err := applyDunning(&branch, req.ReminderDays, req.EscalateAfterDays); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		applyAbandonment(&branch, req.PickupReminderDays, req.FinalNoticeDays, req.DisposalDays)
 
 		if err := db.Save(&branch).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branch"})
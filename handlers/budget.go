@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"laundre/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BudgetRequest struct {
+	BranchID   uint    `json:"branch_id" binding:"required"`
+	CategoryID *uint   `json:"category_id"`
+	Month      string  `json:"month" binding:"required"`
+	Amount     float64 `json:"amount" binding:"required,gt=0"`
+}
+
+type RevenueTargetRequest struct {
+	BranchID uint    `json:"branch_id" binding:"required"`
+	Month    string  `json:"month" binding:"required"`
+	Amount   float64 `json:"amount" binding:"required,gt=0"`
+}
+
+// monthRange returns the first instant of a YYYY-MM month and of the month
+// after it.
+func monthRange(month string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", month, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// monthlyExpenses sums a branch's expenses for the month with one of the
+// given statuses. A nil categoryID covers all categories.
+func monthlyExpenses(db *gorm.DB, branchID uint, categoryID *uint, month string, statuses ...string) (float64, error) {
+	start, end, err := monthRange(month)
+	if err != nil {
+		return 0, err
+	}
+
+	query := db.Model(&models.Expense{}).
+		Where("branch_id = ? AND status IN ? AND created_at >= ? AND created_at < ?", branchID, statuses, start, end)
+	if categoryID != nil {
+		query = query.Where("category_id = ?", *categoryID)
+	}
+
+	var total sql.NullFloat64
+	if err := query.Select("sum(amount)").Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return total.Float64, nil
+}
+
+// budgetWarnings lists the budgets the expense takes over in its month,
+// counting pending expenses as already committed. The expense is counted at
+// its current amount in place of anything already stored for it, so it can
+// be checked before it is saved.
+func budgetWarnings(db *gorm.DB, expense models.Expense) ([]string, error) {
+	date := expense.CreatedAt
+	if date.IsZero() {
+		date = time.Now()
+	}
+	month := date.Format("2006-01")
+
+	others := db
+	if expense.ID != 0 {
+		others = db.Where("id <> ?", expense.ID).Session(&gorm.Session{})
+	}
+
+	var budgets []models.Budget
+	query := db.Preload("Category").Where("branch_id = ? AND month = ?", expense.BranchID, month)
+	if expense.CategoryID != nil {
+		query = query.Where("category_id IS NULL OR category_id = ?", *expense.CategoryID)
+	} else {
+		query = query.Where("category_id IS NULL")
+	}
+	if err := query.Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for _, budget := range budgets {
+		spent, err := monthlyExpenses(others, budget.BranchID, budget.CategoryID, month, "approved", "pending")
+		if err != nil {
+			return nil, err
+		}
+		spent = roundAmount(spent + expense.Amount)
+		if spent <= budget.Amount {
+			continue
+		}
+
+		name := "branch"
+		if budget.Category != nil {
+			name = budget.Category.Name
+		}
+		warnings = append(warnings, fmt.Sprintf("%s budget for %s exceeded: %.2f spent of %.2f", name, month, spent, budget.Amount))
+	}
+
+	return warnings, nil
+}
+
+func SaveBudget(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BudgetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if _, _, err := monthRange(req.Month); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month must use the YYYY-MM format"})
+			return
+		}
+		if !validExpenseCategory(db, req.CategoryID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expense category"})
+			return
+		}
+
+		var budget models.Budget
+		query := db.Where("branch_id = ? AND month = ?", req.BranchID, req.Month)
+		if req.CategoryID != nil {
+			query = query.Where("category_id = ?", *req.CategoryID)
+		} else {
+			query = query.Where("category_id IS NULL")
+		}
+		if err := query.First(&budget).Error; err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save budget", "details": err.Error()})
+			return
+		}
+
+		budget.BranchID = req.BranchID
+		budget.CategoryID = req.CategoryID
+		budget.Month = req.Month
+		budget.Amount = req.Amount
+
+		if err := db.Save(&budget).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save budget", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Budget saved successfully", "data": budget})
+	}
+}
+
+func GetBudgets(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var budgets []models.Budget
+
+		query := db.Preload("Branch").Preload("Category")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		}
+		if month := c.Query("month"); month != "" {
+			query = query.Where("month = ?", month)
+		}
+
+		if err := query.Order("month desc, branch_id asc").Find(&budgets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve budgets", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": budgets})
+	}
+}
+
+func DeleteBudget(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.Budget{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete budget", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Budget deleted successfully"})
+	}
+}
+
+func SaveRevenueTarget(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RevenueTargetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if _, _, err := monthRange(req.Month); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month must use the YYYY-MM format"})
+			return
+		}
+
+		var target models.RevenueTarget
+		err := db.Where("branch_id = ? AND month = ?", req.BranchID, req.Month).First(&target).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save revenue target", "details": err.Error()})
+			return
+		}
+
+		target.BranchID = req.BranchID
+		target.Month = req.Month
+		target.Amount = req.Amount
+
+		if err := db.Save(&target).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save revenue target", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Revenue target saved successfully", "data": target})
+	}
+}
+
+func GetRevenueTargets(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var targets []models.RevenueTarget
+
+		query := db.Preload("Branch")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		}
+		if month := c.Query("month"); month != "" {
+			query = query.Where("month = ?", month)
+		}
+
+		if err := query.Order("month desc, branch_id asc").Find(&targets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revenue targets", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": targets})
+	}
+}
+
+func GetBudgetReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			BranchID uint   `form:"branch_id" binding:"required"`
+			Month    string `form:"month"`
+		}
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "branch_id is required", "details": err.Error()})
+			return
+		}
+		if req.Month == "" {
+			req.Month = time.Now().Format("2006-01")
+		}
+
+		start, end, err := monthRange(req.Month)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month must use the YYYY-MM format"})
+			return
+		}
+
+		var budgets []models.Budget
+		if err := db.Preload("Category").Where("branch_id = ? AND month = ?", req.BranchID, req.Month).
+			Find(&budgets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve budgets", "details": err.Error()})
+			return
+		}
+
+		var rows []gin.H
+		var categoryBudgets float64
+		var branchBudget *float64
+		for _, budget := range budgets {
+			actual, err := monthlyExpenses(db, req.BranchID, budget.CategoryID, req.Month, "approved")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate expenses", "details": err.Error()})
+				return
+			}
+			pending, err := monthlyExpenses(db, req.BranchID, budget.CategoryID, req.Month, "pending")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate expenses", "details": err.Error()})
+				return
+			}
+
+			category := "All categories"
+			if budget.Category != nil {
+				category = budget.Category.Name
+				categoryBudgets += budget.Amount
+			} else {
+				branchBudget = &budget.Amount
+			}
+
+			rows = append(rows, gin.H{
+				"budget_id":    budget.ID,
+				"category_id":  budget.CategoryID,
+				"category":     category,
+				"budget":       budget.Amount,
+				"actual":       actual,
+				"pending":      pending,
+				"remaining":    roundAmount(budget.Amount - actual),
+				"percent_used": roundAmount(actual / budget.Amount * 100),
+				"over_budget":  actual > budget.Amount,
+			})
+		}
+
+		actualExpenses, err := monthlyExpenses(db, req.BranchID, nil, req.Month, "approved")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate expenses", "details": err.Error()})
+			return
+		}
+
+		// A branch-wide budget overrides the sum of the category budgets.
+		totalBudget := categoryBudgets
+		if branchBudget != nil {
+			totalBudget = *branchBudget
+		}
+
+		var actualRevenue sql.NullFloat64
+		if err := db.Model(&models.Transaction{}).
+			Where("branch_id = ? AND created_at >= ? AND created_at < ?", req.BranchID, start, end).
+			Select("sum(total_price)").Scan(&actualRevenue).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate revenue", "details": err.Error()})
+			return
+		}
+
+		revenue := gin.H{"target": nil, "actual": actualRevenue.Float64}
+		var target models.RevenueTarget
+		if err := db.Where("branch_id = ? AND month = ?", req.BranchID, req.Month).First(&target).Error; err == nil {
+			revenue["target"] = target.Amount
+			revenue["variance"] = roundAmount(actualRevenue.Float64 - target.Amount)
+			revenue["percent_achieved"] = roundAmount(actualRevenue.Float64 / target.Amount * 100)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"branch_id": req.BranchID,
+			"month":     req.Month,
+			"expenses": gin.H{
+				"budgets":            rows,
+				"total_budget":       totalBudget,
+				"branch_wide_budget": branchBudget != nil,
+				"total_actual":       actualExpenses,
+				"variance":           roundAmount(totalBudget - actualExpenses),
+			},
+			"revenue": revenue,
+		})
+	}
+}
@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"laundre/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ComplaintRequest struct {
+	OrderID     uint   `json:"order_id" binding:"required"`
+	OrderItemID *uint  `json:"order_item_id"`
+	Category    string `json:"category" binding:"required,oneof=stain damage lost shrinkage color_bleed late service other"`
+	Severity    string `json:"severity" binding:"omitempty,oneof=low medium high critical"`
+	Description string `json:"description" binding:"required"`
+}
+
+// ResolveComplaintRequest settles a complaint. Amount is required for
+// compensation and discounts; compensation is paid either as an expense
+// (method expense, optionally under category_id) or as a refund against
+// revenue (method refund).
+type ResolveComplaintRequest struct {
+	Resolution    string  `json:"resolution" binding:"required,oneof=rewash compensation discount"`
+	Amount        float64 `json:"amount" binding:"min=0"`
+	Method        string  `json:"method" binding:"omitempty,oneof=expense refund"`
+	PaymentMethod string  `json:"payment_method" binding:"omitempty,oneof=cash transfer qris"`
+	CategoryID    *uint   `json:"category_id"`
+	Notes         string  `json:"notes"`
+}
+
+func (req ResolveComplaintRequest) validate() (string, bool) {
+	switch req.Resolution {
+	case "compensation":
+		if req.Amount <= 0 {
+			return "Compensation requires an amount", false
+		}
+		if req.Method == "" {
+			return "Compensation requires a method of expense or refund", false
+		}
+	case "discount":
+		if req.Amount <= 0 {
+			return "A discount requires an amount", false
+		}
+	}
+	return "", true
+}
+
+var errComplaint = errors.New("invalid complaint")
+
+func findComplaint(c *gin.Context, db *gorm.DB) (models.Complaint, bool) {
+	var complaint models.Complaint
+	if err := db.Preload("Order").Preload("Customer").Preload("OrderItem").
+		First(&complaint, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Complaint not found"})
+		return complaint, false
+	}
+
+	if !canAccessBranch(c, complaint.BranchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+		return complaint, false
+	}
+
+	return complaint, true
+}
+
+func CreateComplaint(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ComplaintRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var order models.Order
+		if err := db.First(&order, req.OrderID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if !canAccessBranch(c, order.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		if req.OrderItemID != nil {
+			var count int64
+			db.Model(&models.OrderItem{}).Where("id = ? AND order_id = ?", *req.OrderItemID, order.ID).Count(&count)
+			if count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Order item does not belong to the order"})
+				return
+			}
+		}
+
+		complaint := models.Complaint{
+			BranchID:    order.BranchID,
+			OrderID:     order.ID,
+			CustomerID:  order.CustomerID,
+			OrderItemID: req.OrderItemID,
+			Category:    req.Category,
+			Severity:    req.Severity,
+			Description: req.Description,
+			Status:      "open",
+			ReportedBy:  currentUserID(c),
+		}
+		if complaint.Severity == "" {
+			complaint.Severity = "medium"
+		}
+
+		if err := db.Create(&complaint).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create complaint", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Complaint created successfully", "data": complaint})
+	}
+}
+
+func GetComplaints(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.Complaint{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+		for _, field := range []string{"status", "category", "severity", "customer_id", "order_id"} {
+			if value := c.Query(field); value != "" {
+				query = query.Where(field+" = ?", value)
+			}
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var complaints []models.Complaint
+		if err := query.Preload("Customer").Order("created_at desc, id desc").
+			Offset(offset).Limit(limit).Find(&complaints).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve complaints", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": complaints,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+func GetComplaint(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		complaint, ok := findComplaint(c, db)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": complaint})
+	}
+}
+
+// UpdateComplaint edits the details of an unresolved complaint and lets
+// staff mark it as being worked on.
+func UpdateComplaint(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		complaint, ok := findComplaint(c, db)
+		if !ok {
+			return
+		}
+
+		if complaint.Status != "open" && complaint.Status != "in_progress" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Complaint is already closed"})
+			return
+		}
+
+		var req struct {
+			Category    string `json:"category" binding:"omitempty,oneof=stain damage lost shrinkage color_bleed late service other"`
+			Severity    string `json:"severity" binding:"omitempty,oneof=low medium high critical"`
+			Description string `json:"description"`
+			Status      string `json:"status" binding:"omitempty,oneof=open in_progress"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.Category != "" {
+			complaint.Category = req.Category
+		}
+		if req.Severity != "" {
+			complaint.Severity = req.Severity
+		}
+		if req.Description != "" {
+			complaint.Description = req.Description
+		}
+		if req.Status != "" {
+			complaint.Status = req.Status
+		}
+
+		if err := db.Omit(clause.Associations).Save(&complaint).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update complaint", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Complaint updated successfully", "data": complaint})
+	}
+}
+
+// ResolveComplaint closes a complaint with its resolution. A rewash opens a
+// free urgent order for the same customer. Refunds and discounts are booked
+// against sales returns; a discount is only possible while the order is
+// unpaid and lowers its transaction total. Compensation paid as an expense
+// follows the branch's expense approval threshold.
+func ResolveComplaint(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResolveComplaintRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		if message, ok := req.validate(); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
+		if !validExpenseCategory(db, req.CategoryID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expense category"})
+			return
+		}
+
+		complaint, ok := findComplaint(c, db)
+		if !ok {
+			return
+		}
+
+		userID := currentUserID(c)
+		paymentMethod := req.PaymentMethod
+		if paymentMethod == "" {
+			paymentMethod = "cash"
+		}
+
+		message := ""
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&complaint, complaint.ID).Error; err != nil {
+				return err
+			}
+			if complaint.Status != "open" && complaint.Status != "in_progress" {
+				message = "Complaint is already closed"
+				return errComplaint
+			}
+
+			now := time.Now()
+			complaint.Status = "resolved"
+			complaint.Resolution = &req.Resolution
+			complaint.ResolutionNotes = req.Notes
+			complaint.ResolvedBy = &userID
+			complaint.ResolvedAt = &now
+
+			switch req.Resolution {
+			case "rewash":
+				rewash := models.Order{
+					BranchID:     complaint.Order.BranchID,
+					CustomerID:   complaint.Order.CustomerID,
+					Status:       "urgent",
+					Service:      complaint.Order.Service,
+					Weight:       complaint.Order.Weight,
+					Instructions: complaint.Order.Instructions,
+				}
+				if err := tx.Create(&rewash).Error; err != nil {
+					return err
+				}
+				complaint.RewashOrderID = &rewash.ID
+
+			case "discount":
+				var transaction models.Transaction
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+					Where("order_id = ?", complaint.OrderID).First(&transaction).Error; err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						message = "Order has no transaction to discount"
+						return errComplaint
+					}
+					return err
+				}
+				if transaction.PaymentStatus == "paid" {
+					message = "Order is already paid, settle with a refund instead"
+					return errComplaint
+				}
+				if transaction.InvoiceID != nil {
+					message = "Order is already invoiced, void the invoice before discounting it"
+					return errComplaint
+				}
+				if req.Amount > transaction.TotalPrice {
+					message = fmt.Sprintf("Discount exceeds the order total of %.2f", transaction.TotalPrice)
+					return errComplaint
+				}
+
+				complaint.CompensationAmount = roundAmount(req.Amount)
+				transaction.TotalPrice = roundAmount(transaction.TotalPrice - complaint.CompensationAmount)
+				if err := tx.Model(&transaction).Update("total_price", transaction.TotalPrice).Error; err != nil {
+					return err
+				}
+				if err := models.PostComplaintSettlement(tx, complaint, models.AccountReceivable, ""); err != nil {
+					return err
+				}
+
+			case "compensation":
+				complaint.CompensationAmount = roundAmount(req.Amount)
+				complaint.CompensationMethod = &req.Method
+
+				if req.Method == "refund" {
+					if err := models.PostComplaintSettlement(tx, complaint, models.AccountCash, paymentMethod); err != nil {
+						return err
+					}
+					break
+				}
+
+				expense := models.Expense{
+					BranchID:    complaint.BranchID,
+					CategoryID:  req.CategoryID,
+					Description: fmt.Sprintf("Compensation for complaint #%d on order #%d", complaint.ID, complaint.OrderID),
+					Amount:      complaint.CompensationAmount,
+					SubmittedBy: &userID,
+				}
+				status, err := approvalStatus(c, tx, complaint.BranchID, expense.Amount)
+				if err != nil {
+					return err
+				}
+				expense.Status = status
+				if status == "approved" && isAdmin(c) {
+					expense.ApprovedBy = &userID
+					expense.ApprovedAt = &now
+				}
+				if err := tx.Create(&expense).Error; err != nil {
+					return err
+				}
+				if expense.Status == "approved" {
+					if err := models.PostExpense(tx, expense); err != nil {
+						return err
+					}
+				}
+				complaint.ExpenseID = &expense.ID
+			}
+
+			return tx.Omit(clause.Associations).Save(&complaint).Error
+		})
+
+		if err != nil {
+			if err == errComplaint {
+				c.JSON(http.StatusConflict, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve complaint", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Complaint resolved", "data": complaint})
+	}
+}
+
+func RejectComplaint(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		complaint, ok := findComplaint(c, db)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Notes string `json:"notes" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if complaint.Status != "open" && complaint.Status != "in_progress" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Complaint is already closed"})
+			return
+		}
+
+		userID := currentUserID(c)
+		now := time.Now()
+		complaint.Status = "rejected"
+		complaint.ResolutionNotes = req.Notes
+		complaint.ResolvedBy = &userID
+		complaint.ResolvedAt = &now
+
+		if err := db.Omit(clause.Associations).Save(&complaint).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject complaint", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Complaint rejected", "data": complaint})
+	}
+}
+
+// GetComplaintReport compares complaints against the orders taken per branch
+// over a period. The rate is complaints per hundred orders.
+func GetComplaintReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		startDate := c.DefaultQuery("start_date", now.Format("2006-01")+"-01")
+		endDate := c.DefaultQuery("end_date", now.Format("2006-01-02"))
+		if !validDate(startDate) || !validDate(endDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must use the YYYY-MM-DD format"})
+			return
+		}
+
+		branchQuery := db.Model(&models.Branch{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			branchQuery = branchQuery.Where("id = ?", branchID)
+		} else if !isAdmin(c) {
+			branchQuery = branchQuery.Where("id = ?", currentBranchID(c))
+		}
+
+		var branches []models.Branch
+		if err := branchQuery.Order("id asc").Find(&branches).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve branches", "details": err.Error()})
+			return
+		}
+
+		ids := make([]uint, 0, len(branches))
+		for _, branch := range branches {
+			ids = append(ids, branch.ID)
+		}
+
+		var orderCounts []struct {
+			BranchID uint
+			Orders   int64
+		}
+		if err := db.Model(&models.Order{}).Select("branch_id, COUNT(*) as orders").
+			Where("branch_id IN ? AND DATE(created_at) >= ? AND DATE(created_at) <= ?", ids, startDate, endDate).
+			Group("branch_id").Scan(&orderCounts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count orders", "details": err.Error()})
+			return
+		}
+
+		var complaintRows []struct {
+			BranchID     uint
+			Category     string
+			Status       string
+			Complaints   int64
+			Compensation float64
+		}
+		if err := db.Model(&models.Complaint{}).
+			Select("branch_id, category, status, COUNT(*) as complaints, COALESCE(SUM(compensation_amount), 0) as compensation").
+			Where("branch_id IN ? AND DATE(created_at) >= ? AND DATE(created_at) <= ?", ids, startDate, endDate).
+			Group("branch_id, category, status").Scan(&complaintRows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count complaints", "details": err.Error()})
+			return
+		}
+
+		orders := map[uint]int64{}
+		for _, row := range orderCounts {
+			orders[row.BranchID] = row.Orders
+		}
+
+		type branchStats struct {
+			complaints   int64
+			open         int64
+			compensation float64
+			categories   map[string]int64
+		}
+		stats := map[uint]*branchStats{}
+		for _, branch := range branches {
+			stats[branch.ID] = &branchStats{categories: map[string]int64{}}
+		}
+		for _, row := range complaintRows {
+			s := stats[row.BranchID]
+			s.complaints += row.Complaints
+			s.compensation += row.Compensation
+			s.categories[row.Category] += row.Complaints
+			if row.Status == "open" || row.Status == "in_progress" {
+				s.open += row.Complaints
+			}
+		}
+
+		rows := make([]gin.H, 0, len(branches))
+		for _, branch := range branches {
+			s := stats[branch.ID]
+			var rate float64
+			if orders[branch.ID] > 0 {
+				rate = roundAmount(float64(s.complaints) / float64(orders[branch.ID]) * 100)
+			}
+			rows = append(rows, gin.H{
+				"branch_id":          branch.ID,
+				"branch_name":        branch.Name,
+				"orders":             orders[branch.ID],
+				"complaints":         s.complaints,
+				"open":               s.open,
+				"complaint_rate":     rate,
+				"compensation_total": roundAmount(s.compensation),
+				"by_category":        s.categories,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"start_date": startDate, "end_date": endDate, "data": rows})
+	}
+}
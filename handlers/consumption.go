@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"laundre/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ConsumptionRecipeRequest struct {
+	Service          string  `json:"service" binding:"required,max=50"`
+	InventoryID      uint    `json:"inventory_id" binding:"required"`
+	QuantityPerKg    float64 `json:"quantity_per_kg" binding:"min=0"`
+	QuantityPerOrder float64 `json:"quantity_per_order" binding:"min=0"`
+}
+
+func CreateConsumptionRecipe(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ConsumptionRecipeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.QuantityPerKg == 0 && req.QuantityPerOrder == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity_per_kg or quantity_per_order is required"})
+			return
+		}
+
+		var inventory models.Inventory
+		if err := db.First(&inventory, req.InventoryID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
+			return
+		}
+
+		recipe := models.ConsumptionRecipe{
+			Service:          req.Service,
+			InventoryID:      req.InventoryID,
+			QuantityPerKg:    req.QuantityPerKg,
+			QuantityPerOrder: req.QuantityPerOrder,
+		}
+
+		if err := db.Create(&recipe).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create consumption recipe", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Consumption recipe created successfully", "data": recipe})
+	}
+}
+
+func GetConsumptionRecipes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var recipes []models.ConsumptionRecipe
+
+		query := db.Preload("Inventory").Joins("JOIN inventories ON inventories.id = consumption_recipes.inventory_id")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("inventories.branch_id = ?", branchID)
+		}
+		if service := c.Query("service"); service != "" {
+			query = query.Where("consumption_recipes.service = ?", service)
+		}
+
+		if err := query.Order("consumption_recipes.service asc").Find(&recipes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve consumption recipes", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": recipes})
+	}
+}
+
+func UpdateConsumptionRecipe(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var recipe models.ConsumptionRecipe
+		if err := db.First(&recipe, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Consumption recipe not found"})
+			return
+		}
+
+		var req ConsumptionRecipeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.QuantityPerKg == 0 && req.QuantityPerOrder == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity_per_kg or quantity_per_order is required"})
+			return
+		}
+
+		var inventory models.Inventory
+		if err := db.First(&inventory, req.InventoryID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
+			return
+		}
+
+		recipe.Service = req.Service
+		recipe.InventoryID = req.InventoryID
+		recipe.QuantityPerKg = req.QuantityPerKg
+		recipe.QuantityPerOrder = req.QuantityPerOrder
+
+		if err := db.Save(&recipe).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update consumption recipe", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Consumption recipe updated successfully", "data": recipe})
+	}
+}
+
+func DeleteConsumptionRecipe(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.ConsumptionRecipe{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete consumption recipe", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Consumption recipe not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Consumption recipe deleted successfully"})
+	}
+}
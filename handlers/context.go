@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func currentUserID(c *gin.Context) uint {
+	userID, _ := c.Get("user_id")
+	id, _ := userID.(uint)
+	return id
+}
+
+func isAdmin(c *gin.Context) bool {
+	role, _ := c.Get("role")
+	return role == "admin"
+}
+
+// currentBranchID returns the branch assigned to the caller, or nil for
+// users without one such as admins.
+func currentBranchID(c *gin.Context) *uint {
+	branchID, _ := c.Get("branch_id")
+	id, _ := branchID.(*uint)
+	return id
+}
+
+// canAccessBranch reports whether the caller may work on records of the
+// given branch. Admins can access every branch.
+func canAccessBranch(c *gin.Context, branchID uint) bool {
+	if isAdmin(c) {
+		return true
+	}
+	userBranchID := currentBranchID(c)
+	return userBranchID != nil && *userBranchID == branchID
+}
+
+// branchQueryAllowed checks the branch_id query parameter of a listing or
+// report against the caller's branch, and answers the request itself when
+// the branch is invalid or off limits.
+func branchQueryAllowed(c *gin.Context) bool {
+	value := c.Query("branch_id")
+	if value == "" {
+		return true
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch_id"})
+		return false
+	}
+	if !canAccessBranch(c, uint(id)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+		return false
+	}
+	return true
+}
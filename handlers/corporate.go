@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"fmt"
+	"laundre/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CorporateAccountRequest struct {
+	CustomerID       uint    `json:"customer_id" binding:"required"`
+	CompanyName      string  `json:"company_name" binding:"required,max=150"`
+	ContactName      string  `json:"contact_name" binding:"max=100"`
+	Email            string  `json:"email" binding:"omitempty,email,max=100"`
+	BillingAddress   string  `json:"billing_address"`
+	TaxID            string  `json:"tax_id" binding:"max=30"`
+	CreditLimit      float64 `json:"credit_limit" binding:"min=0"`
+	PaymentTermsDays *int    `json:"payment_terms_days" binding:"omitempty,min=0,max=365"`
+	Active           *bool   `json:"active"`
+}
+
+func (req CorporateAccountRequest) apply(account *models.CorporateAccount) {
+	account.CustomerID = req.CustomerID
+	account.CompanyName = req.CompanyName
+	account.ContactName = req.ContactName
+	account.Email = req.Email
+	account.BillingAddress = req.BillingAddress
+	account.TaxID = req.TaxID
+	account.CreditLimit = req.CreditLimit
+	if req.PaymentTermsDays != nil {
+		account.PaymentTermsDays = *req.PaymentTermsDays
+	}
+	if req.Active != nil {
+		account.Active = *req.Active
+	}
+}
+
+// creditLimitError builds the response for an order that would take a
+// corporate account over its credit limit.
+func creditLimitError(account *models.CorporateAccount, balance, amount float64) gin.H {
+	return gin.H{
+		"error":        fmt.Sprintf("Order would exceed the credit limit of %s", account.CompanyName),
+		"credit_limit": account.CreditLimit,
+		"outstanding":  roundAmount(balance),
+		"order_amount": roundAmount(amount),
+	}
+}
+
+func CreateCorporateAccount(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CorporateAccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var customer models.Customer
+		if err := db.First(&customer, req.CustomerID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+
+		var existing int64
+		db.Model(&models.CorporateAccount{}).Where("customer_id = ?", req.CustomerID).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Customer already has a corporate account"})
+			return
+		}
+
+		account := models.CorporateAccount{Active: true, PaymentTermsDays: models.DefaultPaymentTermsDays}
+		req.apply(&account)
+
+		if err := db.Create(&account).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create corporate account", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Corporate account created successfully", "data": account})
+	}
+}
+
+func GetCorporateAccounts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Customer").Preload("Prices")
+		if active := c.Query("active"); active != "" {
+			query = query.Where("active = ?", active == "true")
+		}
+
+		var accounts []models.CorporateAccount
+		if err := query.Order("company_name asc").Find(&accounts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve corporate accounts", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": accounts})
+	}
+}
+
+func GetCorporateAccount(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var account models.CorporateAccount
+		if err := db.Preload("Customer").Preload("Prices").First(&account, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Corporate account not found"})
+			return
+		}
+
+		balance, err := models.OutstandingBalance(db, account.CustomerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate outstanding balance", "details": err.Error()})
+			return
+		}
+
+		var available *float64
+		if account.CreditLimit > 0 {
+			remaining := roundAmount(account.CreditLimit - balance)
+			available = &remaining
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": account, "outstanding": roundAmount(balance), "available_credit": available})
+	}
+}
+
+func UpdateCorporateAccount(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var account models.CorporateAccount
+		if err := db.First(&account, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Corporate account not found"})
+			return
+		}
+
+		var req CorporateAccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		if req.CustomerID != account.CustomerID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The customer of a corporate account cannot be changed"})
+			return
+		}
+		req.apply(&account)
+
+		if err := db.Omit(clause.Associations).Save(&account).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update corporate account", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Corporate account updated successfully", "data": account})
+	}
+}
+
+func DeleteCorporateAccount(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var count int64
+		db.Model(&models.Invoice{}).Where("corporate_account_id = ?", c.Param("id")).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Corporate account has invoices, deactivate it instead"})
+			return
+		}
+
+		result := db.Delete(&models.CorporateAccount{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete corporate account", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Corporate account not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Corporate account deleted successfully"})
+	}
+}
+
+// SetContractPrices replaces the account's price list.
+func SetContractPrices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var account models.CorporateAccount
+		if err := db.First(&account, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Corporate account not found"})
+			return
+		}
+
+		var req struct {
+			Prices []struct {
+				Service    string  `json:"service" binding:"required,max=50"`
+				PricePerKg float64 `json:"price_per_kg" binding:"required,gt=0"`
+			} `json:"prices" binding:"dive"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		prices := make([]models.ContractPrice, 0, len(req.Prices))
+		seen := map[string]bool{}
+		for _, price := range req.Prices {
+			if seen[price.Service] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate price for service " + price.Service})
+				return
+			}
+			seen[price.Service] = true
+			prices = append(prices, models.ContractPrice{
+				CorporateAccountID: account.ID,
+				Service:            price.Service,
+				PricePerKg:         price.PricePerKg,
+			})
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("corporate_account_id = ?", account.ID).Delete(&models.ContractPrice{}).Error; err != nil {
+				return err
+			}
+			if len(prices) == 0 {
+				return nil
+			}
+			return tx.Create(&prices).Error
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save contract prices", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Contract prices saved successfully", "data": prices})
+	}
+}
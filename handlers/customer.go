@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"laundre/models"
+	"laundre/scheduler"
+	"laundre/utils"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -16,6 +22,18 @@ type CustomerRequest struct {
 	Category string `json:"category" binding:"omitempty,oneof=setia reguler"`
 }
 
+// validate tidies the name and stores the phone number in E.164 so the same
+// customer is recognised however the number was typed.
+func (req *CustomerRequest) validate() error {
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	phone, err := utils.NormalizePhone(req.Phone)
+	if err != nil {
+		return err
+	}
+	req.Phone = phone
+	return nil
+}
+
 func CreateCustomer(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CustomerRequest
@@ -23,6 +41,10 @@ func CreateCustomer(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		customer := models.Customer{
 			Name:     req.Name,
@@ -31,8 +53,14 @@ func CreateCustomer(db *gorm.DB) gin.HandlerFunc {
 			Category: req.Category,
 		}
 
-		if result := db.Create(&customer); result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&customer).Error; err != nil {
+				return err
+			}
+			return models.SyncDefaultAddress(tx, customer)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
@@ -52,6 +80,13 @@ func GetCustomers(db *gorm.DB) gin.HandlerFunc {
 		var customers []models.Customer
 
 		query := db.Model(&models.Customer{})
+		if search := strings.TrimSpace(c.Query("search")); search != "" {
+			like := "%" + search + "%"
+			query = query.Where("name LIKE ? OR phone LIKE ? OR address LIKE ?", like, like, like)
+			if digits := phoneDigits(search); len(digits) >= 4 {
+				query = query.Or("phone LIKE ?", "%"+digits+"%")
+			}
+		}
 		if err := query.Order("id asc").Offset(offset).Limit(limit).Find(&customers).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -72,6 +107,9 @@ func GetCustomers(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// GetCustomer returns the customer's profile: contact details, stored
+// preferences, how often and how much they order, where, what they still owe
+// and their most recent orders (history, default 20) with payment status.
 func GetCustomer(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
@@ -82,23 +120,183 @@ func GetCustomer(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var totalSpent float64
+		history, _ := strconv.Atoi(c.DefaultQuery("history", "20"))
+		if history <= 0 || history > 100 {
+			history = 20
+		}
+
+		var visits struct {
+			Orders        int
+			FirstVisit    *time.Time
+			LastVisit     *time.Time
+			AverageWeight float64
+		}
+		if err := db.Model(&models.Order{}).
+			Select("COUNT(*) as orders, MIN(created_at) as first_visit, MAX(created_at) as last_visit, COALESCE(AVG(weight), 0) as average_weight").
+			Where("customer_id = ? AND status <> ?", customer.ID, "cancelled").
+			Scan(&visits).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate visits", "details": err.Error()})
+			return
+		}
+
+		var spending struct {
+			Transactions  int
+			LifetimeValue float64
+			TotalPaid     float64
+		}
+		if err := db.Model(&models.Transaction{}).
+			Joins("JOIN orders ON orders.id = transactions.order_id").
+			Select("COUNT(*) as transactions, COALESCE(SUM(transactions.total_price), 0) as lifetime_value, "+
+				"COALESCE(SUM(CASE WHEN transactions.payment_status = 'paid' THEN transactions.total_price END), 0) as total_paid").
+			Where("orders.customer_id = ? AND orders.status <> ?", customer.ID, "cancelled").
+			Scan(&spending).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate total spending", "details": err.Error()})
+			return
+		}
+
+		outstanding, err := models.OutstandingBalance(db, customer.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate outstanding balance", "details": err.Error()})
+			return
+		}
+
+		var statuses []struct {
+			Status string
+			Orders int
+		}
+		if err := db.Model(&models.Order{}).Select("status, COUNT(*) as orders").
+			Where("customer_id = ?", customer.ID).Group("status").Scan(&statuses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count orders", "details": err.Error()})
+			return
+		}
+		ordersByStatus := gin.H{}
+		for _, row := range statuses {
+			ordersByStatus[row.Status] = row.Orders
+		}
+
+		var preferredBranch gin.H
+		var branches []struct {
+			BranchID   uint
+			BranchName string
+			OrderCount int
+		}
 		if err := db.Model(&models.Order{}).
-			Where("customer_id = ?", id).
-			Select("SUM(price)").
-			Scan(&totalSpent).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate total spending"})
+			Joins("JOIN branches ON branches.id = orders.branch_id").
+			Select("orders.branch_id, branches.name as branch_name, COUNT(*) as order_count").
+			Where("orders.customer_id = ? AND orders.status <> ?", customer.ID, "cancelled").
+			Group("orders.branch_id, branches.name").Order("order_count desc, MAX(orders.created_at) desc").Limit(1).
+			Scan(&branches).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find preferred branch", "details": err.Error()})
+			return
+		}
+		if len(branches) > 0 {
+			preferredBranch = gin.H{"id": branches[0].BranchID, "name": branches[0].BranchName, "orders": branches[0].OrderCount}
+		}
+
+		var addresses []models.CustomerAddress
+		if err := db.Where("customer_id = ?", customer.ID).Order("is_default desc, label asc").Find(&addresses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve addresses", "details": err.Error()})
+			return
+		}
+		var contacts []models.CustomerContact
+		if err := db.Where("customer_id = ?", customer.ID).Order("name asc").Find(&contacts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve contacts", "details": err.Error()})
+			return
+		}
+
+		var orders []models.Order
+		if err := db.Preload("Branch").Where("customer_id = ?", customer.ID).
+			Order("created_at desc, id desc").Limit(history).Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order history", "details": err.Error()})
+			return
+		}
+		orderIDs := make([]uint, 0, len(orders))
+		for _, order := range orders {
+			orderIDs = append(orderIDs, order.ID)
+		}
+		var transactions []models.Transaction
+		if err := db.Where("order_id IN ?", orderIDs).Find(&transactions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order history", "details": err.Error()})
 			return
 		}
+		byOrder := map[uint]models.Transaction{}
+		for _, transaction := range transactions {
+			byOrder[transaction.OrderID] = transaction
+		}
+		recentOrders := []gin.H{}
+		for _, order := range orders {
+			row := gin.H{
+				"id":                  order.ID,
+				"tracking_code":       order.TrackingCode,
+				"branch_id":           order.BranchID,
+				"branch_name":         order.Branch.Name,
+				"status":              order.Status,
+				"service":             order.Service,
+				"weight":              order.Weight,
+				"instructions":        order.Instructions,
+				"pickup_address_id":   order.PickupAddressID,
+				"delivery_address_id": order.DeliveryAddressID,
+				"created_at":          order.CreatedAt,
+				"completed_at":        order.CompletedAt,
+				"picked_up_at":        order.PickedUpAt,
+			}
+			if transaction, found := byOrder[order.ID]; found {
+				row["total_price"] = transaction.TotalPrice
+				row["payment_status"] = transaction.PaymentStatus
+			}
+			recentOrders = append(recentOrders, row)
+		}
+
+		// Frequency is measured over the span between the first and last
+		// visit; a single visit has none yet.
+		var daysBetweenVisits, daysSinceLastVisit *float64
+		if visits.Orders > 1 {
+			days := roundAmount(visits.LastVisit.Sub(*visits.FirstVisit).Hours() / 24 / float64(visits.Orders-1))
+			daysBetweenVisits = &days
+		}
+		if visits.LastVisit != nil {
+			days := float64(scheduler.DaysSince(*visits.LastVisit, time.Now()))
+			daysSinceLastVisit = &days
+		}
+		averageBasket := 0.0
+		if spending.Transactions > 0 {
+			averageBasket = roundAmount(spending.LifetimeValue / float64(spending.Transactions))
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"data": gin.H{
-				"id":          customer.ID,
-				"name":        customer.Name,
-				"phone":       customer.Phone,
-				"address":     customer.Address,
-				"category":    customer.Category,
-				"total_spent": totalSpent,
+				"id":                    customer.ID,
+				"name":                  customer.Name,
+				"phone":                 customer.Phone,
+				"address":               customer.Address,
+				"category":              customer.Category,
+				"total_spent":           roundAmount(spending.TotalPaid),
+				"language":              customer.Language,
+				"notification_channel":  customer.NotificationChannel,
+				"notifications_opt_out": customer.NotificationsOptOut,
+				"preferences": gin.H{
+					"fragrance":     customer.Fragrance,
+					"folding_style": customer.FoldingStyle,
+					"no_bleach":     customer.NoBleach,
+					"notes":         customer.PreferenceNotes,
+					"summary":       customer.Instructions(),
+				},
+				"stats": gin.H{
+					"orders":                visits.Orders,
+					"orders_by_status":      ordersByStatus,
+					"first_visit":           visits.FirstVisit,
+					"last_visit":            visits.LastVisit,
+					"days_since_last_visit": daysSinceLastVisit,
+					"average_days_between":  daysBetweenVisits,
+					"average_basket":        averageBasket,
+					"average_weight":        roundAmount(visits.AverageWeight),
+					"lifetime_value":        roundAmount(spending.LifetimeValue),
+					"outstanding_balance":   roundAmount(outstanding),
+					"preferred_branch":      preferredBranch,
+				},
+				"addresses":     addresses,
+				"contacts":      contacts,
+				"recent_orders": recentOrders,
 			},
 		})
 	}
@@ -119,6 +317,10 @@ func UpdateCustomer(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		updates := map[string]interface{}{
 			"name":     req.Name,
@@ -127,7 +329,13 @@ func UpdateCustomer(db *gorm.DB) gin.HandlerFunc {
 			"category": req.Category,
 		}
 
-		if err := db.Model(&customer).Updates(updates).Error; err != nil {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&customer).Updates(updates).Error; err != nil {
+				return err
+			}
+			return models.SyncDefaultAddress(tx, customer)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -139,21 +347,82 @@ func UpdateCustomer(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func DeleteCustomer(db *gorm.DB) gin.HandlerFunc {
+// UpdateCustomerPreferences stores the customer's standing instructions,
+// which are copied onto each of their new orders.
+func UpdateCustomerPreferences(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
+		var customer models.Customer
+		if err := db.First(&customer, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+
+		var req struct {
+			Fragrance    *string `json:"fragrance" binding:"omitempty,max=50"`
+			FoldingStyle *string `json:"folding_style" binding:"omitempty,oneof='' folded hanger rolled"`
+			NoBleach     *bool   `json:"no_bleach"`
+			Notes        *string `json:"notes" binding:"omitempty,max=255"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		updates := map[string]interface{}{}
+		if req.Fragrance != nil {
+			updates["fragrance"] = strings.TrimSpace(*req.Fragrance)
+		}
+		if req.FoldingStyle != nil {
+			updates["folding_style"] = *req.FoldingStyle
+		}
+		if req.NoBleach != nil {
+			updates["no_bleach"] = *req.NoBleach
+		}
+		if req.Notes != nil {
+			updates["preference_notes"] = strings.TrimSpace(*req.Notes)
+		}
 
-		result := db.Delete(&models.Customer{}, id)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		if err := db.Model(&customer).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences", "details": err.Error()})
 			return
 		}
 
-		if result.RowsAffected == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Preferences updated successfully", "data": customer})
+	}
+}
+
+func DeleteCustomer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var customer models.Customer
+		if err := db.First(&customer, c.Param("id")).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
 			return
 		}
 
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			var orderIDs []uint
+			if err := tx.Model(&models.Order{}).Where("customer_id = ?", customer.ID).
+				Pluck("id", &orderIDs).Error; err != nil {
+				return err
+			}
+			description := fmt.Sprintf("Reversal: customer #%d deleted", customer.ID)
+			for _, orderID := range orderIDs {
+				if err := deleteOrder(tx, orderID, currentUserID(c), description); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&customer).Error
+		})
+
+		if err != nil {
+			if errors.Is(err, errOrderInvoiced) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Customer has orders on an invoice, void the invoice first"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete customer", "details": err.Error()})
+			}
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{"message": "Customer deleted successfully"})
 	}
 }
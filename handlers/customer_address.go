@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"errors"
+	"laundre/models"
+	"laundre/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errAddress = errors.New("invalid address")
+
+type CustomerAddressRequest struct {
+	Label     string   `json:"label" binding:"required,max=50"`
+	Address   string   `json:"address" binding:"required"`
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	Notes     string   `json:"notes" binding:"max=255"`
+	IsDefault bool     `json:"is_default"`
+}
+
+func (req CustomerAddressRequest) validate() error {
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		return errors.New("latitude and longitude must be given together")
+	}
+	return nil
+}
+
+func (req CustomerAddressRequest) apply(address *models.CustomerAddress) {
+	address.Label = strings.TrimSpace(req.Label)
+	address.Address = strings.TrimSpace(req.Address)
+	address.Latitude = req.Latitude
+	address.Longitude = req.Longitude
+	address.Notes = req.Notes
+}
+
+// makeDefaultAddress marks address as the customer's default and copies it
+// to Customer.Address, which receipts and messages use.
+func makeDefaultAddress(tx *gorm.DB, address models.CustomerAddress) error {
+	if err := tx.Model(&models.CustomerAddress{}).Where("customer_id = ? AND id <> ?", address.CustomerID, address.ID).
+		Update("is_default", false).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&address).Update("is_default", true).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Customer{}).Where("id = ?", address.CustomerID).Update("address", address.Address).Error
+}
+
+// findCustomerAddress loads an address of the customer in the URL.
+func findCustomerAddress(db *gorm.DB, c *gin.Context) (models.CustomerAddress, bool) {
+	var address models.CustomerAddress
+	if err := db.Where("id = ? AND customer_id = ?", c.Param("address_id"), c.Param("id")).First(&address).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return address, false
+	}
+	return address, true
+}
+
+// checkOrderAddress makes sure an address picked for an order belongs to the
+// order's customer.
+func checkOrderAddress(tx *gorm.DB, customerID uint, addressID *uint) error {
+	if addressID == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.CustomerAddress{}).Where("id = ? AND customer_id = ?", *addressID, customerID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errAddress
+	}
+	return nil
+}
+
+func GetCustomerAddresses(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var addresses []models.CustomerAddress
+		if err := db.Where("customer_id = ?", c.Param("id")).Order("is_default desc, label asc").
+			Find(&addresses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve addresses", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": addresses})
+	}
+}
+
+func CreateCustomerAddress(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var customer models.Customer
+		if err := db.First(&customer, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+
+		var req CustomerAddressRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		address := models.CustomerAddress{CustomerID: customer.ID}
+		req.apply(&address)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&models.CustomerAddress{}).Where("customer_id = ?", customer.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&address).Error; err != nil {
+				return err
+			}
+			// The first address is the default one.
+			if req.IsDefault || count == 0 {
+				address.IsDefault = true
+				return makeDefaultAddress(tx, address)
+			}
+			return nil
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create address", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Address created successfully", "data": address})
+	}
+}
+
+func UpdateCustomerAddress(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address, found := findCustomerAddress(db, c)
+		if !found {
+			return
+		}
+
+		var req CustomerAddressRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.apply(&address)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit("Customer").Save(&address).Error; err != nil {
+				return err
+			}
+			if req.IsDefault || address.IsDefault {
+				address.IsDefault = true
+				return makeDefaultAddress(tx, address)
+			}
+			return nil
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update address", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Address updated successfully", "data": address})
+	}
+}
+
+// DeleteCustomerAddress removes an address; orders that used it keep no
+// address. The default address can only go once another one is the default.
+func DeleteCustomerAddress(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address, found := findCustomerAddress(db, c)
+		if !found {
+			return
+		}
+
+		if address.IsDefault {
+			var others int64
+			db.Model(&models.CustomerAddress{}).Where("customer_id = ? AND id <> ?", address.CustomerID, address.ID).Count(&others)
+			if others > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Choose another default address before deleting this one"})
+				return
+			}
+		}
+
+		if err := db.Delete(&address).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete address", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+	}
+}
+
+// UpdateOrderAddresses sets where an order is picked up from and delivered
+// to. A null ID clears the choice.
+func UpdateOrderAddresses(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var order models.Order
+		if err := db.First(&order, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if !canAccessBranch(c, order.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var req struct {
+			PickupAddressID   *uint `json:"pickup_address_id"`
+			DeliveryAddressID *uint `json:"delivery_address_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		for _, addressID := range []*uint{req.PickupAddressID, req.DeliveryAddressID} {
+			if err := checkOrderAddress(db, order.CustomerID, addressID); err != nil {
+				if err == errAddress {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Address does not belong to the order's customer"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check address", "details": err.Error()})
+				}
+				return
+			}
+		}
+
+		order.PickupAddressID = req.PickupAddressID
+		order.DeliveryAddressID = req.DeliveryAddressID
+		if err := db.Model(&order).Updates(map[string]interface{}{
+			"pickup_address_id":   order.PickupAddressID,
+			"delivery_address_id": order.DeliveryAddressID,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order addresses", "details": err.Error()})
+			return
+		}
+
+		db.Preload("PickupAddress").Preload("DeliveryAddress").First(&order, order.ID)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order addresses updated successfully", "data": order})
+	}
+}
+
+type CustomerContactRequest struct {
+	Name         string `json:"name" binding:"required,max=100"`
+	Phone        string `json:"phone" binding:"required"`
+	Relationship string `json:"relationship" binding:"max=50"`
+	Notes        string `json:"notes" binding:"max=255"`
+}
+
+func (req *CustomerContactRequest) validate() error {
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	phone, err := utils.NormalizePhone(req.Phone)
+	if err != nil {
+		return err
+	}
+	req.Phone = phone
+	return nil
+}
+
+func (req CustomerContactRequest) apply(contact *models.CustomerContact) {
+	contact.Name = req.Name
+	contact.Phone = req.Phone
+	contact.Relationship = req.Relationship
+	contact.Notes = req.Notes
+}
+
+func GetCustomerContacts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var contacts []models.CustomerContact
+		if err := db.Where("customer_id = ?", c.Param("id")).Order("name asc").Find(&contacts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve contacts", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": contacts})
+	}
+}
+
+func CreateCustomerContact(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var customer models.Customer
+		if err := db.First(&customer, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+
+		var req CustomerContactRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		contact := models.CustomerContact{CustomerID: customer.ID}
+		req.apply(&contact)
+		if err := db.Create(&contact).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create contact", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Contact created successfully", "data": contact})
+	}
+}
+
+func UpdateCustomerContact(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var contact models.CustomerContact
+		if err := db.Where("id = ? AND customer_id = ?", c.Param("contact_id"), c.Param("id")).First(&contact).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
+			return
+		}
+
+		var req CustomerContactRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.apply(&contact)
+		if err := db.Omit("Customer").Save(&contact).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update contact", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Contact updated successfully", "data": contact})
+	}
+}
+
+func DeleteCustomerContact(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Where("id = ? AND customer_id = ?", c.Param("contact_id"), c.Param("id")).Delete(&models.CustomerContact{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete contact", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Contact deleted successfully"})
+	}
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"laundre/models"
+	"laundre/utils"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errMerge = errors.New("customers cannot be merged")
+
+type duplicatePair struct {
+	Customer  models.Customer `json:"customer"`
+	Duplicate models.Customer `json:"duplicate"`
+	Score     float64         `json:"score"`
+	Reasons   []string        `json:"reasons"`
+}
+
+// matchCustomers scores how likely a and b are the same person. The same
+// phone number counts most; otherwise the names must be close and the phone
+// numbers or addresses must be too.
+func matchCustomers(a, b models.Customer) (float64, []string) {
+	nameScore := utils.Similarity(utils.NormalizeName(a.Name), utils.NormalizeName(b.Name))
+	phoneScore := utils.Similarity(a.Phone, b.Phone)
+	addressScore := utils.Similarity(utils.NormalizeName(a.Address), utils.NormalizeName(b.Address))
+
+	var reasons []string
+	score := 0.0
+	if a.Phone == b.Phone {
+		reasons = append(reasons, "same_phone")
+		score = 0.6 + 0.4*nameScore
+	}
+	if nameScore >= 0.8 {
+		reasons = append(reasons, "similar_name")
+		if phoneScore >= 0.85 && a.Phone != b.Phone {
+			reasons = append(reasons, "similar_phone")
+			score = max(score, 0.5*nameScore+0.5*phoneScore)
+		}
+		if addressScore >= 0.8 {
+			reasons = append(reasons, "similar_address")
+			score = max(score, 0.6*nameScore+0.4*addressScore)
+		}
+	}
+	return score, reasons
+}
+
+// GetDuplicateCustomers lists pairs of customers that are probably the same
+// person, best matches first. Only pairs scoring at least min_score
+// (default 0.8) are returned.
+func GetDuplicateCustomers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		minScore := 0.8
+		if value := c.Query("min_score"); value != "" {
+			score, err := strconv.ParseFloat(value, 64)
+			if err != nil || score <= 0 || score > 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "min_score must be between 0 and 1"})
+				return
+			}
+			minScore = score
+		}
+
+		var customers []models.Customer
+		if err := db.Order("id asc").Find(&customers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve customers", "details": err.Error()})
+			return
+		}
+
+		// Only customers sharing a phone number or the first letter of their
+		// name are compared. Each group is still compared pair by pair, so
+		// this divides the work by about the number of groups rather than
+		// making it linear.
+		groups := map[string][]int{}
+		for i, customer := range customers {
+			groups["phone:"+customer.Phone] = append(groups["phone:"+customer.Phone], i)
+			if name := []rune(utils.NormalizeName(customer.Name)); len(name) > 0 {
+				key := "name:" + string(name[0])
+				groups[key] = append(groups[key], i)
+			}
+		}
+
+		pairs := []duplicatePair{}
+		seen := map[[2]int]bool{}
+		for _, members := range groups {
+			for x := 0; x < len(members); x++ {
+				for y := x + 1; y < len(members); y++ {
+					key := [2]int{members[x], members[y]}
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+
+					a, b := customers[members[x]], customers[members[y]]
+					score, reasons := matchCustomers(a, b)
+					if score < minScore {
+						continue
+					}
+					pairs = append(pairs, duplicatePair{Customer: a, Duplicate: b, Score: roundAmount(score), Reasons: reasons})
+				}
+			}
+		}
+
+		sort.Slice(pairs, func(i, j int) bool {
+			if pairs[i].Score != pairs[j].Score {
+				return pairs[i].Score > pairs[j].Score
+			}
+			return pairs[i].Customer.ID < pairs[j].Customer.ID
+		})
+
+		c.JSON(http.StatusOK, gin.H{"data": pairs})
+	}
+}
+
+// MergeCustomers folds duplicate records into the customer in the URL. Their
+// orders, and through them transactions and invoices, complaints, messages,
+// reminders, addresses and contacts move to the surviving customer, and the
+// duplicates are removed, all in one database transaction.
+func MergeCustomers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			DuplicateIDs []uint `json:"duplicate_ids" binding:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var customer models.Customer
+		message := ""
+		var merges []models.CustomerMerge
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&customer, c.Param("id")).Error; err != nil {
+				return err
+			}
+
+			var duplicates []models.Customer
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", req.DuplicateIDs).
+				Find(&duplicates).Error; err != nil {
+				return err
+			}
+			if len(duplicates) != len(req.DuplicateIDs) {
+				message = "Some duplicate customers were not found"
+				return errMerge
+			}
+
+			ids := make([]uint, 0, len(duplicates))
+			for _, duplicate := range duplicates {
+				if duplicate.ID == customer.ID {
+					message = "A customer cannot be merged into itself"
+					return errMerge
+				}
+				ids = append(ids, duplicate.ID)
+			}
+
+			// A customer can hold only one corporate account, so an account
+			// on a duplicate moves over only if the survivor has none.
+			var accounts []models.CorporateAccount
+			if err := tx.Where("customer_id IN ?", append([]uint{customer.ID}, ids...)).Find(&accounts).Error; err != nil {
+				return err
+			}
+			if len(accounts) > 1 {
+				message = "More than one of these customers has a corporate account"
+				return errMerge
+			}
+			if len(accounts) == 1 && accounts[0].CustomerID != customer.ID {
+				if err := tx.Model(&accounts[0]).Update("customer_id", customer.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			orderCounts := map[uint]int{}
+			var rows []struct {
+				CustomerID uint
+				Orders     int
+			}
+			if err := tx.Model(&models.Order{}).Select("customer_id, COUNT(*) as orders").
+				Where("customer_id IN ?", ids).Group("customer_id").Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				orderCounts[row.CustomerID] = row.Orders
+			}
+
+			// The survivor keeps its own default address.
+			if err := tx.Model(&models.CustomerAddress{}).Where("customer_id IN ?", ids).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+
+			for _, model := range []interface{}{
+				&models.Order{},
+				&models.Complaint{},
+				&models.Notification{},
+				&models.PaymentReminder{},
+				&models.CustomerMerge{},
+				&models.CustomerAddress{},
+				&models.CustomerContact{},
+			} {
+				if err := tx.Model(model).Where("customer_id IN ?", ids).Update("customer_id", customer.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			// Keep the strongest standing and preferences of the records.
+			updates := map[string]interface{}{}
+			for _, duplicate := range duplicates {
+				if duplicate.Category == "setia" && customer.Category != "setia" {
+					customer.Category = "setia"
+					updates["category"] = customer.Category
+				}
+				if duplicate.NotificationsOptOut && !customer.NotificationsOptOut {
+					customer.NotificationsOptOut = true
+					updates["notifications_opt_out"] = true
+				}
+				if customer.Address == "" && duplicate.Address != "" {
+					customer.Address = duplicate.Address
+					updates["address"] = customer.Address
+				}
+				if customer.Instructions() == "" && duplicate.Instructions() != "" {
+					customer.Fragrance = duplicate.Fragrance
+					customer.FoldingStyle = duplicate.FoldingStyle
+					customer.NoBleach = duplicate.NoBleach
+					customer.PreferenceNotes = duplicate.PreferenceNotes
+					updates["fragrance"] = customer.Fragrance
+					updates["folding_style"] = customer.FoldingStyle
+					updates["no_bleach"] = customer.NoBleach
+					updates["preference_notes"] = customer.PreferenceNotes
+				}
+
+				merges = append(merges, models.CustomerMerge{
+					CustomerID:       customer.ID,
+					MergedCustomerID: duplicate.ID,
+					MergedName:       duplicate.Name,
+					MergedPhone:      duplicate.Phone,
+					MergedAddress:    duplicate.Address,
+					OrdersMoved:      orderCounts[duplicate.ID],
+					MergedBy:         currentUserID(c),
+				})
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(&customer).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Create(&merges).Error; err != nil {
+				return err
+			}
+
+			return tx.Delete(&models.Customer{}, ids).Error
+		})
+
+		if err != nil {
+			switch {
+			case err == errMerge:
+				c.JSON(http.StatusConflict, gin.H{"error": message})
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge customers", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("%d customer(s) merged", len(merges)),
+			"data":    customer,
+			"merges":  merges,
+		})
+	}
+}
+
+func GetCustomerMerges(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var merges []models.CustomerMerge
+		if err := db.Where("customer_id = ?", c.Param("id")).Order("created_at desc").Find(&merges).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve merges", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": merges})
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"laundre/models"
+	"laundre/scheduler"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RunPaymentReminders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reminded, escalated, err := scheduler.RunPaymentReminders(db, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run payment reminders", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Payment reminders processed", "reminded": reminded, "escalated": escalated})
+	}
+}
+
+func GetPaymentReminders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.PaymentReminder{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+		for _, field := range []string{"transaction_id", "customer_id"} {
+			if value := c.Query(field); value != "" {
+				query = query.Where(field+" = ?", value)
+			}
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var reminders []models.PaymentReminder
+		if err := query.Preload("Customer").Preload("Notification").Order("created_at desc, id desc").
+			Offset(offset).Limit(limit).Find(&reminders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment reminders", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": reminders,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+// GetOverdueReport lists, per branch, the unpaid transactions that were
+// escalated after going unpaid past the branch's limit, with the reminders
+// already sent for each.
+func GetOverdueReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Order.Customer").Where("payment_status = ? AND escalated_at IS NOT NULL", "unpaid")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+
+		var transactions []models.Transaction
+		if err := query.Order("branch_id asc, created_at asc").Find(&transactions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve overdue transactions", "details": err.Error()})
+			return
+		}
+
+		ids := make([]uint, 0, len(transactions))
+		for _, transaction := range transactions {
+			ids = append(ids, transaction.ID)
+		}
+
+		var reminderRows []struct {
+			TransactionID uint
+			Reminders     int
+			LastSent      time.Time
+		}
+		if err := db.Model(&models.PaymentReminder{}).
+			Select("transaction_id, COUNT(*) as reminders, MAX(created_at) as last_sent").
+			Where("transaction_id IN ?", ids).Group("transaction_id").Scan(&reminderRows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment reminders", "details": err.Error()})
+			return
+		}
+		reminders := map[uint]int{}
+		lastSent := map[uint]*time.Time{}
+		for _, row := range reminderRows {
+			reminders[row.TransactionID] = row.Reminders
+			sent := row.LastSent
+			lastSent[row.TransactionID] = &sent
+		}
+
+		now := time.Now()
+		type branchOverdue struct {
+			BranchID     uint    `json:"branch_id"`
+			Count        int     `json:"count"`
+			Outstanding  float64 `json:"outstanding"`
+			Transactions []gin.H `json:"transactions"`
+		}
+		branches := []*branchOverdue{}
+		byBranch := map[uint]*branchOverdue{}
+		for _, transaction := range transactions {
+			row, found := byBranch[transaction.BranchID]
+			if !found {
+				row = &branchOverdue{BranchID: transaction.BranchID, Transactions: []gin.H{}}
+				byBranch[transaction.BranchID] = row
+				branches = append(branches, row)
+			}
+
+			row.Count++
+			row.Outstanding = roundAmount(row.Outstanding + transaction.TotalPrice)
+			row.Transactions = append(row.Transactions, gin.H{
+				"transaction_id": transaction.ID,
+				"order_id":       transaction.OrderID,
+				"customer_id":    transaction.Order.CustomerID,
+				"customer_name":  transaction.Order.Customer.Name,
+				"customer_phone": transaction.Order.Customer.Phone,
+				"amount":         transaction.TotalPrice,
+				"days_unpaid":    scheduler.DaysSince(transaction.CreatedAt, now),
+				"escalated_at":   transaction.EscalatedAt,
+				"reminders_sent": reminders[transaction.ID],
+				"last_reminder":  lastSent[transaction.ID],
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": branches})
+	}
+}
@@ -1,19 +1,59 @@
 package handlers
 
 import (
+	"fmt"
 	"laundre/models"
+	"laundre/storage"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// approvalStatus decides whether an expense can be booked right away. Staff
+// expenses above the branch threshold wait for an admin.
+func approvalStatus(c *gin.Context, tx *gorm.DB, branchID uint, amount float64) (string, error) {
+	if isAdmin(c) {
+		return "approved", nil
+	}
+
+	var branch models.Branch
+	if err := tx.First(&branch, branchID).Error; err != nil {
+		return "", err
+	}
+
+	if branch.ExpenseApprovalThreshold > 0 && amount > branch.ExpenseApprovalThreshold {
+		return "pending", nil
+	}
+	return "approved", nil
+}
+
+// canModifyExpense reports whether the caller may change an expense. Staff
+// can only touch unapproved expenses of their own branch; booked expenses are
+// left to admins.
+func canModifyExpense(c *gin.Context, expense models.Expense) bool {
+	if isAdmin(c) {
+		return true
+	}
+	return canAccessBranch(c, expense.BranchID) && expense.Status != "approved"
+}
+
+func validExpenseCategory(db *gorm.DB, categoryID *uint) bool {
+	if categoryID == nil {
+		return true
+	}
+	var category models.ExpenseCategory
+	return db.First(&category, *categoryID).Error == nil
+}
+
 func CreateExpense(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			BranchID    uint    `json:"branch_id" binding:"required"`
+			CategoryID  *uint   `json:"category_id"`
 			Description string  `json:"description" binding:"required"`
-			Amount      float64 `json:"amount" binding:"required"`
+			Amount      float64 `json:"amount" binding:"required,gt=0"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -21,18 +61,65 @@ func CreateExpense(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if !validExpenseCategory(db, req.CategoryID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expense category"})
+			return
+		}
+
+		userID := currentUserID(c)
 		expense := models.Expense{
 			BranchID:    req.BranchID,
+			CategoryID:  req.CategoryID,
 			Description: req.Description,
 			Amount:      req.Amount,
+			SubmittedBy: &userID,
 		}
 
-		if err := db.Create(&expense).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create expense", "details": err.Error()})
+		var warnings []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			status, err := approvalStatus(c, tx, req.BranchID, req.Amount)
+			if err != nil {
+				return err
+			}
+			expense.Status = status
+			if status == "approved" && isAdmin(c) {
+				now := time.Now()
+				expense.ApprovedBy = &userID
+				expense.ApprovedAt = &now
+			}
+
+			if warnings, err = budgetWarnings(tx, expense); err != nil {
+				return err
+			}
+			if err := tx.Create(&expense).Error; err != nil {
+				return err
+			}
+			if expense.Status != "approved" {
+				return nil
+			}
+			return models.PostExpense(tx, expense)
+		})
+
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create expense", "details": err.Error()})
+			}
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "Expense created successfully", "data": expense})
+		message := "Expense created successfully"
+		if expense.Status == "pending" {
+			message = "Expense submitted for admin approval"
+		}
+
+		response := gin.H{"message": message, "data": expense}
+		if len(warnings) > 0 {
+			response["warnings"] = warnings
+		}
+
+		c.JSON(http.StatusCreated, response)
 	}
 }
 
@@ -40,7 +127,15 @@ func GetAllExpenses(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var expenses []models.Expense
 
-		if err := db.Preload("Branch").Find(&expenses).Error; err != nil {
+		query := db.Preload("Branch").Preload("Category")
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if categoryID := c.Query("category_id"); categoryID != "" {
+			query = query.Where("category_id = ?", categoryID)
+		}
+
+		if err := query.Find(&expenses).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve expenses", "details": err.Error()})
 			return
 		}
@@ -59,7 +154,7 @@ func GetExpenseByID(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		var expense models.Expense
-		if err := db.Preload("Branch").First(&expense, id).Error; err != nil {
+		if err := db.Preload("Branch").Preload("Category").Preload("Attachments").First(&expense, id).Error; err != nil {
 			if gorm.ErrRecordNotFound == err {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
 			} else {
@@ -77,7 +172,7 @@ func GetExpensesByBranch(db *gorm.DB) gin.HandlerFunc {
 		branchID := c.Param("branch_id")
 
 		var expenses []models.Expense
-		if err := db.Preload("Branch").Where("branch_id = ?", branchID).Find(&expenses).Error; err != nil {
+		if err := db.Preload("Branch").Preload("Category").Where("branch_id = ?", branchID).Find(&expenses).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve expenses for the branch", "details": err.Error()})
 			return
 		}
@@ -101,9 +196,15 @@ func UpdateExpense(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if !canModifyExpense(c, expense) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Approved expenses can only be changed by an admin"})
+			return
+		}
+
 		var req struct {
-			Description string  `json:"description"`
-			Amount      float64 `json:"amount"`
+			CategoryID  *uint    `json:"category_id"`
+			Description string   `json:"description"`
+			Amount      *float64 `json:"amount" binding:"omitempty,gt=0"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -111,33 +212,269 @@ func UpdateExpense(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		expense.Description = req.Description
-		expense.Amount = req.Amount
+		if req.CategoryID != nil {
+			if !validExpenseCategory(db, req.CategoryID) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expense category"})
+				return
+			}
+			expense.CategoryID = req.CategoryID
+		}
+		if req.Description != "" {
+			expense.Description = req.Description
+		}
+		if req.Amount != nil {
+			expense.Amount = *req.Amount
+		}
+
+		var warnings []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if !isAdmin(c) {
+				status, err := approvalStatus(c, tx, expense.BranchID, expense.Amount)
+				if err != nil {
+					return err
+				}
+				expense.Status = status
+				expense.RejectionReason = ""
+			}
 
-		if err := db.Save(&expense).Error; err != nil {
+			var err error
+			if warnings, err = budgetWarnings(tx, expense); err != nil {
+				return err
+			}
+			if err := tx.Save(&expense).Error; err != nil {
+				return err
+			}
+
+			description := fmt.Sprintf("Reversal: expense #%d updated", expense.ID)
+			if err := models.ReverseJournalEntries(tx, expense.ID, description, models.JournalExpense); err != nil {
+				return err
+			}
+			if expense.Status != "approved" {
+				return nil
+			}
+			return models.PostExpense(tx, expense)
+		})
+
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense", "details": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Expense updated successfully", "data": expense})
+		response := gin.H{"message": "Expense updated successfully", "data": expense}
+		if len(warnings) > 0 {
+			response["warnings"] = warnings
+		}
+
+		c.JSON(http.StatusOK, response)
 	}
 }
 
-func DeleteExpense(db *gorm.DB) gin.HandlerFunc {
+func DeleteExpense(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 
-		result := db.Delete(&models.Expense{}, id)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense", "details": result.Error.Error()})
+		var expense models.Expense
+		if err := db.Preload("Attachments").First(&expense, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
 			return
 		}
 
-		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+		if !canModifyExpense(c, expense) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Approved expenses can only be deleted by an admin"})
 			return
 		}
 
+		err := db.Transaction(func(tx *gorm.DB) error {
+			description := fmt.Sprintf("Reversal: expense #%d deleted", expense.ID)
+			if err := models.ReverseJournalEntries(tx, expense.ID, description, models.JournalExpense); err != nil {
+				return err
+			}
+			if err := tx.Where("owner_type = ? AND owner_id = ?", "expense", expense.ID).Delete(&models.Attachment{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.Expense{}, expense.ID).Error
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense", "details": err.Error()})
+			return
+		}
+
+		for _, attachment := range expense.Attachments {
+			removeStoredFiles(store, attachment)
+		}
+
 		c.JSON(http.StatusOK, gin.H{"message": "Expense deleted successfully"})
 	}
 }
+
+func GetPendingExpenses(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var expenses []models.Expense
+
+		query := db.Preload("Branch").Preload("Category").Preload("Attachments").Where("status = ?", "pending")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		}
+
+		if err := query.Order("created_at asc").Find(&expenses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pending expenses", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": expenses})
+	}
+}
+
+func ApproveExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var expense models.Expense
+		if err := db.First(&expense, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+
+		if expense.Status != "pending" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending expenses can be approved"})
+			return
+		}
+
+		userID := currentUserID(c)
+		now := time.Now()
+		expense.Status = "approved"
+		expense.ApprovedBy = &userID
+		expense.ApprovedAt = &now
+
+		var warnings []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if warnings, err = budgetWarnings(tx, expense); err != nil {
+				return err
+			}
+			if err := tx.Save(&expense).Error; err != nil {
+				return err
+			}
+			return models.PostExpense(tx, expense)
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve expense", "details": err.Error()})
+			return
+		}
+
+		response := gin.H{"message": "Expense approved successfully", "data": expense}
+		if len(warnings) > 0 {
+			response["warnings"] = warnings
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func RejectExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var expense models.Expense
+		if err := db.First(&expense, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+
+		if expense.Status != "pending" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending expenses can be rejected"})
+			return
+		}
+
+		var req struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		expense.Status = "rejected"
+		expense.RejectionReason = req.Reason
+
+		if err := db.Save(&expense).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject expense", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Expense rejected", "data": expense})
+	}
+}
+
+func UploadExpenseReceipt(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var expense models.Expense
+		if err := db.First(&expense, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+
+		if !canAccessBranch(c, expense.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		attachment, ok := saveUpload(c, db, store, "expense", expense.ID)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Receipt uploaded successfully", "data": attachment})
+	}
+}
+
+func GetExpenseReceipt(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var expense models.Expense
+		if err := db.First(&expense, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+
+		if !canAccessBranch(c, expense.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var attachment models.Attachment
+		if err := db.Where("owner_type = ? AND owner_id = ?", "expense", expense.ID).
+			First(&attachment, c.Param("receipt_id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+			return
+		}
+
+		serveAttachment(c, store, attachment)
+	}
+}
+
+func DeleteExpenseReceipt(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var expense models.Expense
+		if err := db.First(&expense, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+
+		if !canModifyExpense(c, expense) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Receipts of approved expenses can only be removed by an admin"})
+			return
+		}
+
+		var attachment models.Attachment
+		if err := db.Where("owner_type = ? AND owner_id = ?", "expense", expense.ID).
+			First(&attachment, c.Param("receipt_id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+			return
+		}
+
+		if err := deleteAttachment(db, store, attachment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete receipt", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Receipt deleted successfully"})
+	}
+}
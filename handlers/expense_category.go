@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"laundre/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ExpenseCategoryRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	AccountID *uint  `json:"account_id"`
+}
+
+// validExpenseAccount checks that an optional ledger account exists and is
+// an expense account.
+func validExpenseAccount(db *gorm.DB, accountID *uint) bool {
+	if accountID == nil {
+		return true
+	}
+	var account models.Account
+	if err := db.First(&account, *accountID).Error; err != nil {
+		return false
+	}
+	return account.Type == "expense"
+}
+
+func CreateExpenseCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ExpenseCategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if !validExpenseAccount(db, req.AccountID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Account must be an existing expense account"})
+			return
+		}
+
+		category := models.ExpenseCategory{
+			Name:      req.Name,
+			AccountID: req.AccountID,
+		}
+
+		if err := db.Create(&category).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create expense category", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Expense category created successfully", "data": category})
+	}
+}
+
+func GetExpenseCategories(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var categories []models.ExpenseCategory
+		if err := db.Preload("Account").Order("name asc").Find(&categories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve expense categories", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": categories})
+	}
+}
+
+func UpdateExpenseCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var category models.ExpenseCategory
+		if err := db.First(&category, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense category not found"})
+			return
+		}
+
+		var req ExpenseCategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if !validExpenseAccount(db, req.AccountID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Account must be an existing expense account"})
+			return
+		}
+
+		category.Name = req.Name
+		category.AccountID = req.AccountID
+
+		if err := db.Save(&category).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense category", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Expense category updated successfully", "data": category})
+	}
+}
+
+func DeleteExpenseCategory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.ExpenseCategory{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense category", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense category not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Expense category deleted successfully"})
+	}
+}
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"laundre/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ExportMappingRequest struct {
+	Type      string `json:"type" binding:"required,oneof=account branch payment_method expense_category"`
+	SourceKey string `json:"source_key" binding:"required,max=50"`
+	Code      string `json:"code" binding:"required,max=50"`
+}
+
+type ExportColumn struct {
+	Header string `json:"header" binding:"required"`
+	Field  string `json:"field" binding:"required"`
+}
+
+type ExportProfileRequest struct {
+	Name       string         `json:"name" binding:"required,max=100"`
+	Delimiter  string         `json:"delimiter"`
+	DateFormat string         `json:"date_format" binding:"omitempty,max=30"`
+	Columns    []ExportColumn `json:"columns" binding:"required,min=1,dive"`
+}
+
+var exportFields = map[string]bool{
+	"date":                true,
+	"journal_number":      true,
+	"account_code":        true,
+	"account_name":        true,
+	"ledger_account_code": true,
+	"cost_center":         true,
+	"branch_id":           true,
+	"branch_name":         true,
+	"description":         true,
+	"debit":               true,
+	"credit":              true,
+	"amount":              true,
+}
+
+// exportDelimiter reads a profile's delimiter, which must be a single
+// character the CSV writer can separate fields with.
+func exportDelimiter(value string) (rune, error) {
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, errors.New("delimiter must be a single character")
+	}
+	delimiter, _ := utf8.DecodeRuneInString(value)
+	switch delimiter {
+	case '"', '\r', '\n', utf8.RuneError:
+		return 0, fmt.Errorf("%q cannot be used as a delimiter", value)
+	}
+	return delimiter, nil
+}
+
+var genericExportColumns = []ExportColumn{
+	{Header: "Date", Field: "date"},
+	{Header: "Journal", Field: "journal_number"},
+	{Header: "Account", Field: "account_code"},
+	{Header: "Account Name", Field: "account_name"},
+	{Header: "Cost Center", Field: "cost_center"},
+	{Header: "Description", Field: "description"},
+	{Header: "Debit", Field: "debit"},
+	{Header: "Credit", Field: "credit"},
+}
+
+type journalSummaryLine struct {
+	Date          time.Time
+	BranchID      uint
+	BranchName    string
+	AccountCode   string
+	AccountName   string
+	AccountType   string
+	PaymentMethod string
+	CategoryID    *uint
+	Debit         float64
+	Credit        float64
+	ExternalCode  string `gorm:"-"`
+	CostCenter    string `gorm:"-"`
+}
+
+// exportMappings indexes ExportMapping codes by type and source key.
+type exportMappings map[string]map[string]string
+
+func loadExportMappings(db *gorm.DB) (exportMappings, error) {
+	var rows []models.ExportMapping
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	mappings := exportMappings{}
+	for _, row := range rows {
+		if mappings[row.Type] == nil {
+			mappings[row.Type] = map[string]string{}
+		}
+		mappings[row.Type][row.SourceKey] = row.Code
+	}
+	return mappings, nil
+}
+
+// accountCode picks the external account for a summary line. Cash lines are
+// split per payment method and expense lines per expense category when a
+// mapping exists, so each can land on its own account.
+func (m exportMappings) accountCode(line journalSummaryLine) string {
+	if line.AccountCode == models.AccountCash && line.PaymentMethod != "" {
+		if code, ok := m["payment_method"][line.PaymentMethod]; ok {
+			return code
+		}
+	}
+	if line.AccountType == "expense" && line.CategoryID != nil {
+		if code, ok := m["expense_category"][strconv.FormatUint(uint64(*line.CategoryID), 10)]; ok {
+			return code
+		}
+	}
+	if code, ok := m["account"][line.AccountCode]; ok {
+		return code
+	}
+	return line.AccountCode
+}
+
+func (m exportMappings) costCenter(branchID uint) string {
+	key := strconv.FormatUint(uint64(branchID), 10)
+	if code, ok := m["branch"][key]; ok {
+		return code
+	}
+	return key
+}
+
+// summarizeJournal aggregates journal lines per day, branch and external
+// account. Debits and credits are netted so each daily journal stays balanced.
+func summarizeJournal(db *gorm.DB, startDate, endDate, branchID string, mappings exportMappings) ([]journalSummaryLine, error) {
+	query := db.Table("journal_lines").
+		Select("DATE(journal_entries.date) as date, journal_entries.branch_id, branches.name as branch_name, "+
+			"accounts.code as account_code, accounts.name as account_name, accounts.type as account_type, "+
+			"journal_entries.payment_method, journal_entries.expense_category_id as category_id, "+
+			"SUM(journal_lines.debit) as debit, SUM(journal_lines.credit) as credit").
+		Joins("JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id").
+		Joins("JOIN accounts ON accounts.id = journal_lines.account_id").
+		Joins("LEFT JOIN branches ON branches.id = journal_entries.branch_id").
+		Where("DATE(journal_entries.date) BETWEEN ? AND ?", startDate, endDate)
+
+	if branchID != "" {
+		query = query.Where("journal_entries.branch_id = ?", branchID)
+	}
+
+	var raw []journalSummaryLine
+	if err := query.Group("DATE(journal_entries.date), journal_entries.branch_id, branches.name, " +
+		"accounts.code, accounts.name, accounts.type, journal_entries.payment_method, journal_entries.expense_category_id").
+		Scan(&raw).Error; err != nil {
+		return nil, err
+	}
+
+	grouped := map[string]*journalSummaryLine{}
+	var keys []string
+	for _, line := range raw {
+		line.ExternalCode = mappings.accountCode(line)
+		line.CostCenter = mappings.costCenter(line.BranchID)
+
+		key := fmt.Sprintf("%s|%d|%s", line.Date.Format("2006-01-02"), line.BranchID, line.ExternalCode)
+		if existing, ok := grouped[key]; ok {
+			existing.Debit += line.Debit
+			existing.Credit += line.Credit
+			continue
+		}
+
+		summary := line
+		grouped[key] = &summary
+		keys = append(keys, key)
+	}
+
+	var lines []journalSummaryLine
+	for _, key := range keys {
+		line := *grouped[key]
+		net := roundAmount(line.Debit - line.Credit)
+		if net == 0 {
+			continue
+		}
+		line.Debit, line.Credit = 0, 0
+		if net > 0 {
+			line.Debit = net
+		} else {
+			line.Credit = -net
+		}
+		lines = append(lines, line)
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.BranchID != b.BranchID {
+			return a.BranchID < b.BranchID
+		}
+		if (a.Debit > 0) != (b.Debit > 0) {
+			return a.Debit > 0
+		}
+		return a.ExternalCode < b.ExternalCode
+	})
+
+	return lines, nil
+}
+
+func exportFieldValue(line journalSummaryLine, field, dateLayout string) string {
+	switch field {
+	case "date":
+		return line.Date.Format(dateLayout)
+	case "journal_number":
+		return fmt.Sprintf("JV-%s-%d", line.Date.Format("20060102"), line.BranchID)
+	case "account_code":
+		return line.ExternalCode
+	case "account_name":
+		return line.AccountName
+	case "ledger_account_code":
+		return line.AccountCode
+	case "cost_center":
+		return line.CostCenter
+	case "branch_id":
+		return strconv.FormatUint(uint64(line.BranchID), 10)
+	case "branch_name":
+		return line.BranchName
+	case "description":
+		return fmt.Sprintf("Daily summary %s %s", line.BranchName, line.Date.Format("2006-01-02"))
+	case "debit":
+		return strconv.FormatFloat(line.Debit, 'f', 2, 64)
+	case "credit":
+		return strconv.FormatFloat(line.Credit, 'f', 2, 64)
+	case "amount":
+		return strconv.FormatFloat(line.Debit-line.Credit, 'f', 2, 64)
+	}
+	return ""
+}
+
+func ExportJournal(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startDate := c.Query("start_date")
+		endDate := c.Query("end_date")
+		if startDate == "" && endDate == "" {
+			today := time.Now().Format("2006-01-02")
+			startDate, endDate = today, today
+		}
+		if startDate == "" || endDate == "" || !validDate(startDate) || !validDate(endDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date must use the YYYY-MM-DD format"})
+			return
+		}
+
+		columns := genericExportColumns
+		delimiter := ','
+		dateLayout := "2006-01-02"
+
+		if profileID := c.Query("profile_id"); profileID != "" {
+			var profile models.ExportProfile
+			if err := db.First(&profile, profileID).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Export profile not found"})
+				return
+			}
+
+			if err := json.Unmarshal([]byte(profile.Columns), &columns); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Export profile has invalid columns", "details": err.Error()})
+				return
+			}
+			if profile.Delimiter != "" {
+				parsed, err := exportDelimiter(profile.Delimiter)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Export profile has an invalid delimiter", "details": err.Error()})
+					return
+				}
+				delimiter = parsed
+			}
+			if profile.DateFormat != "" {
+				dateLayout = strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02").Replace(profile.DateFormat)
+			}
+		}
+
+		mappings, err := loadExportMappings(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load export mappings", "details": err.Error()})
+			return
+		}
+
+		lines, err := summarizeJournal(db, startDate, endDate, c.Query("branch_id"), mappings)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize journal", "details": err.Error()})
+			return
+		}
+
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Comma = delimiter
+
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Header
+		}
+		if err := writer.Write(header); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write export", "details": err.Error()})
+			return
+		}
+
+		for _, line := range lines {
+			record := make([]string, len(columns))
+			for i, column := range columns {
+				record[i] = exportFieldValue(line, column.Field, dateLayout)
+			}
+			if err := writer.Write(record); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write export", "details": err.Error()})
+				return
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write export", "details": err.Error()})
+			return
+		}
+
+		filename := fmt.Sprintf("journal_%s_%s.csv", startDate, endDate)
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(http.StatusOK, "text/csv", buf.Bytes())
+	}
+}
+
+func GetExportMappings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var mappings []models.ExportMapping
+
+		query := db.Order("type asc, source_key asc")
+		if mappingType := c.Query("type"); mappingType != "" {
+			query = query.Where("type = ?", mappingType)
+		}
+
+		if err := query.Find(&mappings).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve export mappings", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": mappings})
+	}
+}
+
+func SaveExportMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ExportMappingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var mapping models.ExportMapping
+		err := db.Where("type = ? AND source_key = ?", req.Type, req.SourceKey).First(&mapping).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save export mapping", "details": err.Error()})
+			return
+		}
+
+		mapping.Type = req.Type
+		mapping.SourceKey = req.SourceKey
+		mapping.Code = req.Code
+
+		if err := db.Save(&mapping).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save export mapping", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Export mapping saved successfully", "data": mapping})
+	}
+}
+
+func DeleteExportMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.ExportMapping{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete export mapping", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Export mapping not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Export mapping deleted successfully"})
+	}
+}
+
+func GetExportProfiles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var profiles []models.ExportProfile
+		if err := db.Order("name asc").Find(&profiles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve export profiles", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": profiles})
+	}
+}
+
+func CreateExportProfile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ExportProfileRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.Delimiter != "" {
+			if _, err := exportDelimiter(req.Delimiter); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		for _, column := range req.Columns {
+			if !exportFields[column.Field] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown export field: " + column.Field})
+				return
+			}
+		}
+
+		columns, err := json.Marshal(req.Columns)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode columns", "details": err.Error()})
+			return
+		}
+
+		profile := models.ExportProfile{
+			Name:       req.Name,
+			Delimiter:  req.Delimiter,
+			DateFormat: req.DateFormat,
+			Columns:    string(columns),
+		}
+
+		if err := db.Create(&profile).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create export profile", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Export profile created successfully", "data": profile})
+	}
+}
+
+func DeleteExportProfile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.ExportProfile{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete export profile", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Export profile not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Export profile deleted successfully"})
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import "testing"
+
+func TestExportDelimiter(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    rune
+		wantErr bool
+	}{
+		{value: ",", want: ','},
+		{value: ";", want: ';'},
+		{value: "\t", want: '\t'},
+		{value: "|", want: '|'},
+		{value: "§", want: '§'},
+		{value: "", wantErr: true},
+		{value: ",;", wantErr: true},
+		{value: `"`, wantErr: true},
+		{value: "\r", wantErr: true},
+		{value: "\n", wantErr: true},
+		{value: "\xa7", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := exportDelimiter(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("exportDelimiter(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("exportDelimiter(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
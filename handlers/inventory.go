@@ -2,18 +2,41 @@ package handlers
 
 import (
 	"laundre/models"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+type InventoryUnitRequest struct {
+	Name   string  `json:"name" binding:"required,max=20"`
+	Factor float64 `json:"factor" binding:"required,gt=0"`
+}
+
+// optionalCode turns a blank SKU or barcode into NULL so it does not clash
+// with the unique index.
+func optionalCode(code string) *string {
+	if code == "" {
+		return nil
+	}
+	return &code
+}
+
 func CreateInventory(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			BranchID uint   `json:"branch_id" binding:"required"`
-			Name     string `json:"name" binding:"required"`
-			Stock    int    `json:"stock" binding:"required,min=0"`
+			BranchID        uint                   `json:"branch_id" binding:"required"`
+			Name            string                 `json:"name" binding:"required"`
+			SKU             string                 `json:"sku" binding:"max=50"`
+			Barcode         string                 `json:"barcode" binding:"max=50"`
+			BaseUnit        string                 `json:"base_unit" binding:"max=20"`
+			Stock           float64                `json:"stock" binding:"min=0"`
+			UnitCost        float64                `json:"unit_cost" binding:"min=0"`
+			ReorderPoint    float64                `json:"reorder_point" binding:"min=0"`
+			ReorderQuantity float64                `json:"reorder_quantity" binding:"min=0"`
+			Units           []InventoryUnitRequest `json:"units" binding:"dive"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -27,13 +50,50 @@ func CreateInventory(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if req.BaseUnit == "" {
+			req.BaseUnit = "pcs"
+		}
+
 		inventory := models.Inventory{
-			BranchID: req.BranchID,
-			Name:     req.Name,
-			Stock:    req.Stock,
+			BranchID:        req.BranchID,
+			Name:            req.Name,
+			SKU:             optionalCode(req.SKU),
+			Barcode:         optionalCode(req.Barcode),
+			BaseUnit:        req.BaseUnit,
+			ReorderPoint:    req.ReorderPoint,
+			ReorderQuantity: req.ReorderQuantity,
+		}
+		for _, unit := range req.Units {
+			inventory.Units = append(inventory.Units, models.InventoryUnit{Name: unit.Name, Factor: unit.Factor})
 		}
 
-		if err := db.Create(&inventory).Error; err != nil {
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			if err := tx.Create(&inventory).Error; err != nil {
+				return err
+			}
+			if req.Stock == 0 {
+				return nil
+			}
+
+			userID := currentUserID(c)
+			movement := models.StockMovement{
+				InventoryID:   inventory.ID,
+				Type:          "adjustment",
+				Quantity:      req.Stock,
+				UnitCost:      req.UnitCost,
+				Reason:        "Opening balance",
+				UserID:        &userID,
+				ReferenceType: "opening_balance",
+			}
+			if err := models.RecordStockMovement(tx, &movement); err != nil {
+				return err
+			}
+			inventory.Stock = movement.BalanceAfter
+			inventory.AverageCost = req.UnitCost
+			return nil
+		})
+
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory", "details": err.Error()})
 			return
 		}
@@ -46,7 +106,15 @@ func GetAllInventories(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var inventories []models.Inventory
 
-		if err := db.Preload("Branch").Find(&inventories).Error; err != nil {
+		query := db.Preload("Branch").Preload("Units")
+		if sku := c.Query("sku"); sku != "" {
+			query = query.Where("sku = ?", sku)
+		}
+		if barcode := c.Query("barcode"); barcode != "" {
+			query = query.Where("barcode = ?", barcode)
+		}
+
+		if err := query.Find(&inventories).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inventories", "details": err.Error()})
 			return
 		}
@@ -60,7 +128,7 @@ func GetInventoryByID(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 
 		var inventory models.Inventory
-		if err := db.Preload("Branch").First(&inventory, id).Error; err != nil {
+		if err := db.Preload("Branch").Preload("Units").First(&inventory, id).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
 			return
 		}
@@ -81,8 +149,13 @@ func UpdateInventory(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		var req struct {
-			Name  string `json:"name" binding:"omitempty,max=100"`
-			Stock *int   `json:"stock" binding:"omitempty,min=0"`
+			Name            string   `json:"name" binding:"omitempty,max=100"`
+			SKU             *string  `json:"sku" binding:"omitempty,max=50"`
+			Barcode         *string  `json:"barcode" binding:"omitempty,max=50"`
+			BaseUnit        string   `json:"base_unit" binding:"omitempty,max=20"`
+			Stock           *float64 `json:"stock"`
+			ReorderPoint    *float64 `json:"reorder_point" binding:"omitempty,min=0"`
+			ReorderQuantity *float64 `json:"reorder_quantity" binding:"omitempty,min=0"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -90,11 +163,35 @@ func UpdateInventory(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if req.Stock != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Stock can no longer be edited directly, record a stock movement instead"})
+			return
+		}
+
+		if req.BaseUnit != "" && req.BaseUnit != inventory.BaseUnit {
+			var movements int64
+			db.Model(&models.StockMovement{}).Where("inventory_id = ?", inventory.ID).Count(&movements)
+			if movements > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "The base unit cannot change once stock has been recorded"})
+				return
+			}
+			inventory.BaseUnit = req.BaseUnit
+		}
+
 		if req.Name != "" {
 			inventory.Name = req.Name
 		}
-		if req.Stock != nil {
-			inventory.Stock = *req.Stock
+		if req.SKU != nil {
+			inventory.SKU = optionalCode(*req.SKU)
+		}
+		if req.Barcode != nil {
+			inventory.Barcode = optionalCode(*req.Barcode)
+		}
+		if req.ReorderPoint != nil {
+			inventory.ReorderPoint = *req.ReorderPoint
+		}
+		if req.ReorderQuantity != nil {
+			inventory.ReorderQuantity = *req.ReorderQuantity
 		}
 
 		if err := db.Save(&inventory).Error; err != nil {
@@ -152,3 +249,330 @@ func GetInventoryByBranch(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"data": inventories})
 	}
 }
+
+// GetLowStockInventories lists the items at or below their reorder point.
+// Staff only see their own branch.
+func GetLowStockInventories(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Branch").Where("reorder_point > 0 AND stock <= reorder_point")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+
+		var inventories []models.Inventory
+		if err := query.Order("branch_id asc, name asc").Find(&inventories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve low-stock inventories", "details": err.Error()})
+			return
+		}
+
+		rows := make([]gin.H, 0, len(inventories))
+		for _, inventory := range inventories {
+			rows = append(rows, gin.H{
+				"inventory":          inventory,
+				"shortfall":          math.Round((inventory.ReorderPoint-inventory.Stock)*1000) / 1000,
+				"suggested_quantity": inventory.SuggestedOrderQuantity(),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": rows})
+	}
+}
+
+// StockMovementRequest quantities and costs are per Unit, which defaults to
+// the item's base unit.
+type StockMovementRequest struct {
+	Type     string  `json:"type" binding:"required,oneof=purchase consumption adjustment transfer waste"`
+	Quantity float64 `json:"quantity" binding:"required,ne=0"`
+	Unit     string  `json:"unit" binding:"max=20"`
+	UnitCost float64 `json:"unit_cost" binding:"min=0"`
+	Reason   string  `json:"reason"`
+}
+
+// signedQuantity applies the direction implied by the movement type.
+// Purchases add stock and consumption or waste remove it, so those take a
+// positive quantity; adjustments and transfers carry their own sign.
+func (req StockMovementRequest) signedQuantity() (float64, string) {
+	switch req.Type {
+	case "purchase":
+		if req.Quantity < 0 {
+			return 0, "Purchase quantity must be positive"
+		}
+		return req.Quantity, ""
+	case "consumption", "waste":
+		if req.Quantity < 0 {
+			return 0, "Quantity must be positive, it is deducted from stock"
+		}
+		return -req.Quantity, ""
+	}
+	return req.Quantity, ""
+}
+
+func CreateStockMovement(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var inventory models.Inventory
+		if err := db.First(&inventory, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
+			return
+		}
+
+		if !canAccessBranch(c, inventory.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var req StockMovementRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		quantity, message := req.signedQuantity()
+		if message != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
+		if req.Type == "adjustment" && req.Reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required for adjustments"})
+			return
+		}
+
+		factor, err := models.UnitFactor(db, inventory, req.Unit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown unit for this item", "details": err.Error()})
+			return
+		}
+
+		userID := currentUserID(c)
+		movement := models.StockMovement{
+			InventoryID: inventory.ID,
+			Type:        req.Type,
+			Quantity:    quantity * factor,
+			UnitCost:    req.UnitCost / factor,
+			Reason:      req.Reason,
+			UserID:      &userID,
+		}
+
+		err = models.StockTransaction(db, func(tx *gorm.DB) error {
+			return models.RecordStockMovement(tx, &movement)
+		})
+
+		if err != nil {
+			if err == models.ErrInsufficientStock {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Insufficient stock", "stock": inventory.Stock})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Stock movement recorded successfully", "data": movement})
+	}
+}
+
+func GetStockMovements(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		var inventory models.Inventory
+		if err := db.First(&inventory, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
+			return
+		}
+
+		startDate := c.Query("start_date")
+		endDate := c.Query("end_date")
+		if !validDate(startDate) || !validDate(endDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must use the YYYY-MM-DD format"})
+			return
+		}
+
+		query := db.Model(&models.StockMovement{}).Where("inventory_id = ?", inventory.ID)
+		if movementType := c.Query("type"); movementType != "" {
+			query = query.Where("type = ?", movementType)
+		}
+		if startDate != "" {
+			query = query.Where("DATE(created_at) >= ?", startDate)
+		}
+		if endDate != "" {
+			query = query.Where("DATE(created_at) <= ?", endDate)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var movements []models.StockMovement
+		if err := query.Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, username, role, branch_id")
+		}).Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&movements).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock movements", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"inventory": inventory,
+			"data":      movements,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+// RecalculateStock rebuilds the cached stock of an item from its ledger.
+func RecalculateStock(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var inventory models.Inventory
+		if err := db.First(&inventory, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
+			return
+		}
+
+		balance, err := models.StockBalance(db, inventory.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate stock", "details": err.Error()})
+			return
+		}
+
+		previous := inventory.Stock
+		if err := db.Model(&inventory).Update("stock", balance).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Stock recalculated successfully",
+			"data":           inventory,
+			"previous_stock": previous,
+			"ledger_stock":   balance,
+		})
+	}
+}
+
+func AddInventoryUnit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var inventory models.Inventory
+		if err := db.First(&inventory, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
+			return
+		}
+
+		if !canAccessBranch(c, inventory.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var req InventoryUnitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.Name == inventory.BaseUnit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The base unit does not need a conversion"})
+			return
+		}
+
+		var count int64
+		db.Model(&models.InventoryUnit{}).Where("inventory_id = ? AND name = ?", inventory.ID, req.Name).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Unit already exists for this item"})
+			return
+		}
+
+		unit := models.InventoryUnit{InventoryID: inventory.ID, Name: req.Name, Factor: req.Factor}
+		if err := db.Create(&unit).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create unit", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Unit created successfully", "data": unit})
+	}
+}
+
+func DeleteInventoryUnit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var inventory models.Inventory
+		if err := db.First(&inventory, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
+			return
+		}
+
+		if !canAccessBranch(c, inventory.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		result := db.Where("inventory_id = ?", inventory.ID).Delete(&models.InventoryUnit{}, c.Param("unit_id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete unit", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unit not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Unit deleted successfully"})
+	}
+}
+
+// GetStockValuation values each item's stock at its moving-average cost and
+// totals it per branch.
+func GetStockValuation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Branch")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+
+		var inventories []models.Inventory
+		if err := query.Order("branch_id asc, name asc").Find(&inventories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inventories", "details": err.Error()})
+			return
+		}
+
+		type branchValuation struct {
+			BranchID   uint    `json:"branch_id"`
+			BranchName string  `json:"branch_name"`
+			Items      []gin.H `json:"items"`
+			TotalValue float64 `json:"total_value"`
+		}
+
+		var branches []*branchValuation
+		byBranch := map[uint]*branchValuation{}
+		var total float64
+		for _, inventory := range inventories {
+			valuation, found := byBranch[inventory.BranchID]
+			if !found {
+				valuation = &branchValuation{BranchID: inventory.BranchID, BranchName: inventory.Branch.Name}
+				byBranch[inventory.BranchID] = valuation
+				branches = append(branches, valuation)
+			}
+
+			value := inventory.Value()
+			valuation.Items = append(valuation.Items, gin.H{
+				"inventory_id": inventory.ID,
+				"name":         inventory.Name,
+				"sku":          inventory.SKU,
+				"base_unit":    inventory.BaseUnit,
+				"stock":        inventory.Stock,
+				"average_cost": inventory.AverageCost,
+				"value":        value,
+			})
+			valuation.TotalValue = roundAmount(valuation.TotalValue + value)
+			total += value
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": branches, "total_value": roundAmount(total)})
+	}
+}
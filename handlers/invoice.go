@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"laundre/models"
+	"laundre/pdf"
+	"laundre/scheduler"
+	"laundre/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvoice = errors.New("invalid invoice")
+
+// GenerateInvoices bills corporate accounts for a past month (period as
+// YYYY-MM, default last month), for one account or all of them.
+func GenerateInvoices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Period             string `json:"period"`
+			CorporateAccountID uint   `json:"corporate_account_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		period := scheduler.MonthStart(now).AddDate(0, -1, 0)
+		if req.Period != "" {
+			parsed, err := time.ParseInLocation("2006-01", req.Period, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "period must use the YYYY-MM format"})
+				return
+			}
+			period = parsed
+		}
+		if !now.After(period.AddDate(0, 1, 0)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only months that have ended can be invoiced"})
+			return
+		}
+
+		userID := currentUserID(c)
+		invoices, err := scheduler.GenerateInvoices(db, period, now, req.CorporateAccountID, &userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invoices", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invoices generated", "created": len(invoices), "data": invoices})
+	}
+}
+
+func GetInvoices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("CorporateAccount")
+		if accountID := c.Query("corporate_account_id"); accountID != "" {
+			query = query.Where("corporate_account_id = ?", accountID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if period := c.Query("period"); period != "" {
+			query = query.Where("DATE_FORMAT(period_start, '%Y-%m') = ?", period)
+		}
+
+		var invoices []models.Invoice
+		if err := query.Order("period_start desc, id desc").Find(&invoices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invoices", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": invoices})
+	}
+}
+
+func GetInvoice(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var invoice models.Invoice
+		if err := db.Preload("CorporateAccount.Customer").Preload("Lines", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date asc, id asc")
+		}).First(&invoice, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": invoice})
+	}
+}
+
+// PayInvoice records payment of an invoice in full and marks each of its
+// transactions paid, posting the payments to the ledger.
+func PayInvoice(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			PaymentMethod string `json:"payment_method" binding:"omitempty,oneof=cash transfer qris"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		if req.PaymentMethod == "" {
+			req.PaymentMethod = "transfer"
+		}
+
+		var invoice models.Invoice
+		message := ""
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invoice, c.Param("id")).Error; err != nil {
+				return err
+			}
+			if invoice.Status != "issued" {
+				message = fmt.Sprintf("Invoice is already %s", invoice.Status)
+				return errInvoice
+			}
+
+			var transactions []models.Transaction
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("invoice_id = ? AND payment_status = ?", invoice.ID, "unpaid").Find(&transactions).Error; err != nil {
+				return err
+			}
+			for _, transaction := range transactions {
+				transaction.PaymentStatus = "paid"
+				transaction.PaymentMethod = req.PaymentMethod
+				if err := tx.Model(&transaction).Updates(map[string]interface{}{
+					"payment_status": transaction.PaymentStatus,
+					"payment_method": transaction.PaymentMethod,
+				}).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).Where("id = ?", transaction.OrderID).
+					Update("price", transaction.TotalPrice).Error; err != nil {
+					return err
+				}
+				if err := models.PostPayment(tx, transaction); err != nil {
+					return err
+				}
+			}
+
+			now := time.Now()
+			invoice.Status = "paid"
+			invoice.PaymentMethod = req.PaymentMethod
+			invoice.PaidAt = &now
+			return tx.Model(&invoice).Updates(map[string]interface{}{
+				"status":         invoice.Status,
+				"payment_method": invoice.PaymentMethod,
+				"paid_at":        now,
+			}).Error
+		})
+
+		if err != nil {
+			switch {
+			case err == errInvoice:
+				c.JSON(http.StatusConflict, gin.H{"error": message})
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record invoice payment", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invoice paid", "data": invoice})
+	}
+}
+
+// VoidInvoice cancels an unpaid invoice and releases its transactions so
+// they can be billed again.
+func VoidInvoice(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var invoice models.Invoice
+		message := ""
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invoice, c.Param("id")).Error; err != nil {
+				return err
+			}
+			if invoice.Status != "issued" {
+				message = fmt.Sprintf("Invoice is already %s", invoice.Status)
+				return errInvoice
+			}
+
+			if err := tx.Model(&models.Transaction{}).Where("invoice_id = ?", invoice.ID).
+				Update("invoice_id", nil).Error; err != nil {
+				return err
+			}
+
+			// The number is freed for the replacement invoice. The ID keeps
+			// the voided number unique when a month is voided more than once.
+			invoice.Status = "void"
+			invoice.Number = fmt.Sprintf("%s-VOID-%d", invoice.Number, invoice.ID)
+			return tx.Model(&invoice).Updates(map[string]interface{}{
+				"status": invoice.Status,
+				"number": invoice.Number,
+			}).Error
+		})
+
+		if err != nil {
+			switch {
+			case err == errInvoice:
+				c.JSON(http.StatusConflict, gin.H{"error": message})
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to void invoice", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invoice voided", "data": invoice})
+	}
+}
+
+// GetInvoicePDF renders an invoice as a printable A4 document.
+func GetInvoicePDF(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var invoice models.Invoice
+		if err := db.Preload("CorporateAccount").Preload("Lines", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date asc, id asc")
+		}).First(&invoice, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+
+		const left, right = 50.0, pdf.PageWidth - 50
+		doc := pdf.New()
+		page := doc.AddPage()
+		page.Text(left, 70, 20, true, "INVOICE")
+		if invoice.Status != "issued" {
+			page.TextRight(right, 70, 14, true, strings.ToUpper(invoice.Status))
+		}
+
+		y := 100.0
+		for _, row := range [][2]string{
+			{"Invoice number", invoice.Number},
+			{"Period", invoice.PeriodStart.Format("02 Jan 2006") + " - " + invoice.PeriodEnd.Format("02 Jan 2006")},
+			{"Issue date", invoice.IssueDate.Format("02 Jan 2006")},
+			{"Due date", invoice.DueDate.Format("02 Jan 2006")},
+		} {
+			page.Text(left, y, 10, true, row[0])
+			page.Text(left+100, y, 10, false, row[1])
+			y += 15
+		}
+
+		account := invoice.CorporateAccount
+		y += 10
+		page.Text(left, y, 10, true, "Bill to")
+		y += 15
+		billTo := []string{account.CompanyName}
+		if account.ContactName != "" {
+			billTo = append(billTo, "Attn. "+account.ContactName)
+		}
+		billTo = append(billTo, strings.Split(account.BillingAddress, "\n")...)
+		if account.TaxID != "" {
+			billTo = append(billTo, "Tax ID: "+account.TaxID)
+		}
+		for _, line := range billTo {
+			if line = strings.TrimSpace(line); line != "" {
+				page.Text(left, y, 10, false, line)
+				y += 13
+			}
+		}
+
+		header := func(y float64) float64 {
+			page.Text(left, y, 10, true, "Date")
+			page.Text(left+80, y, 10, true, "Order")
+			page.Text(left+140, y, 10, true, "Description")
+			page.TextRight(right, y, 10, true, "Amount (Rp)")
+			page.Line(left, y+5, right, y+5)
+			return y + 20
+		}
+		y = header(y + 20)
+		for _, line := range invoice.Lines {
+			if y > pdf.PageHeight-100 {
+				page = doc.AddPage()
+				y = header(70)
+			}
+			page.Text(left, y, 10, false, line.Date.Format("02/01/2006"))
+			page.Text(left+80, y, 10, false, fmt.Sprintf("#%d", line.OrderID))
+			page.Text(left+140, y, 10, false, line.Description)
+			page.TextRight(right, y, 10, false, utils.Rupiah(line.Amount))
+			y += 15
+		}
+
+		page.Line(left, y-5, right, y-5)
+		page.Text(left+140, y+10, 11, true, "Total")
+		page.TextRight(right, y+10, 11, true, utils.Rupiah(invoice.Total))
+		page.Text(left, y+40, 9, false,
+			fmt.Sprintf("Payment is due within %d days, by %s.", account.PaymentTermsDays, invoice.DueDate.Format("02 Jan 2006")))
+
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", invoice.Number+".pdf"))
+		c.Data(http.StatusOK, "application/pdf", doc.Bytes())
+	}
+}
+
+// GetInvoiceAging buckets the unpaid invoices of each corporate account by
+// how long ago they were issued: 0-30, 31-60, 61-90 and over 90 days.
+func GetInvoiceAging(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		asOf := time.Now()
+		if value := c.Query("as_of"); value != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must use the YYYY-MM-DD format"})
+				return
+			}
+			asOf = parsed
+		}
+
+		var invoices []models.Invoice
+		if err := db.Preload("CorporateAccount").Where("status = ? AND issue_date <= ?", "issued", asOf).
+			Order("corporate_account_id asc, issue_date asc").Find(&invoices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invoices", "details": err.Error()})
+			return
+		}
+
+		type aging struct {
+			CorporateAccountID uint    `json:"corporate_account_id"`
+			CompanyName        string  `json:"company_name"`
+			Current            float64 `json:"days_0_30"`
+			Days31To60         float64 `json:"days_31_60"`
+			Days61To90         float64 `json:"days_61_90"`
+			Over90             float64 `json:"days_over_90"`
+			Total              float64 `json:"total"`
+			Overdue            float64 `json:"overdue"`
+		}
+		add := func(row *aging, days int, amount float64, overdue bool) {
+			switch {
+			case days <= 30:
+				row.Current = roundAmount(row.Current + amount)
+			case days <= 60:
+				row.Days31To60 = roundAmount(row.Days31To60 + amount)
+			case days <= 90:
+				row.Days61To90 = roundAmount(row.Days61To90 + amount)
+			default:
+				row.Over90 = roundAmount(row.Over90 + amount)
+			}
+			row.Total = roundAmount(row.Total + amount)
+			if overdue {
+				row.Overdue = roundAmount(row.Overdue + amount)
+			}
+		}
+
+		accounts := []*aging{}
+		byAccount := map[uint]*aging{}
+		totals := &aging{}
+		for _, invoice := range invoices {
+			row, found := byAccount[invoice.CorporateAccountID]
+			if !found {
+				row = &aging{CorporateAccountID: invoice.CorporateAccountID, CompanyName: invoice.CorporateAccount.CompanyName}
+				byAccount[invoice.CorporateAccountID] = row
+				accounts = append(accounts, row)
+			}
+
+			days := scheduler.DaysSince(invoice.IssueDate, asOf)
+			overdue := asOf.After(invoice.DueDate.AddDate(0, 0, 1))
+			add(row, days, invoice.Total, overdue)
+			add(totals, days, invoice.Total, overdue)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"as_of": asOf.Format("2006-01-02"), "data": accounts, "totals": totals})
+	}
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"laundre/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AccountRequest struct {
+	Code string `json:"code" binding:"required,max=20"`
+	Name string `json:"name" binding:"required,max=100"`
+	Type string `json:"type" binding:"required,oneof=asset liability equity revenue expense"`
+}
+
+type accountBalance struct {
+	AccountID uint    `json:"account_id"`
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Debit     float64 `json:"debit"`
+	Credit    float64 `json:"credit"`
+	Balance   float64 `json:"balance"`
+}
+
+// accountBalances sums journal lines per account, optionally limited to one
+// branch and to entries dated on or before endDate. Balance is expressed in
+// the account's normal direction.
+func accountBalances(db *gorm.DB, branchID, endDate string) ([]accountBalance, error) {
+	query := db.Table("accounts").
+		Select("accounts.id as account_id, accounts.code, accounts.name, accounts.type, " +
+			"COALESCE(SUM(journal_lines.debit), 0) as debit, COALESCE(SUM(journal_lines.credit), 0) as credit").
+		Joins("LEFT JOIN journal_lines ON journal_lines.account_id = accounts.id").
+		Joins("LEFT JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id")
+
+	if branchID != "" {
+		query = query.Where("journal_entries.id IS NULL OR journal_entries.branch_id = ?", branchID)
+	}
+	if endDate != "" {
+		query = query.Where("journal_entries.id IS NULL OR DATE(journal_entries.date) <= ?", endDate)
+	}
+
+	var balances []accountBalance
+	if err := query.Group("accounts.id, accounts.code, accounts.name, accounts.type").
+		Order("accounts.code asc").
+		Scan(&balances).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range balances {
+		account := models.Account{Type: balances[i].Type}
+		if account.DebitNormal() {
+			balances[i].Balance = roundAmount(balances[i].Debit - balances[i].Credit)
+		} else {
+			balances[i].Balance = roundAmount(balances[i].Credit - balances[i].Debit)
+		}
+	}
+
+	return balances, nil
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func validDate(value string) bool {
+	if value == "" {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+func GetAccounts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var accounts []models.Account
+		if err := db.Order("code asc").Find(&accounts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve accounts", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": accounts})
+	}
+}
+
+func CreateAccount(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req AccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var count int64
+		db.Model(&models.Account{}).Where("code = ?", req.Code).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account code already exists"})
+			return
+		}
+
+		account := models.Account{
+			Code: req.Code,
+			Name: req.Name,
+			Type: req.Type,
+		}
+
+		if err := db.Create(&account).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Account created successfully", "data": account})
+	}
+}
+
+func GetJournalEntries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		startDate := c.Query("start_date")
+		endDate := c.Query("end_date")
+		if !validDate(startDate) || !validDate(endDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must use the YYYY-MM-DD format"})
+			return
+		}
+
+		query := db.Model(&models.JournalEntry{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		}
+		if sourceType := c.Query("source_type"); sourceType != "" {
+			query = query.Where("source_type = ?", sourceType)
+		}
+		if sourceID := c.Query("source_id"); sourceID != "" {
+			query = query.Where("source_id = ?", sourceID)
+		}
+		if startDate != "" {
+			query = query.Where("DATE(date) >= ?", startDate)
+		}
+		if endDate != "" {
+			query = query.Where("DATE(date) <= ?", endDate)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var entries []models.JournalEntry
+		if err := query.Preload("Lines.Account").Order("date desc, id desc").
+			Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve journal entries", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": entries,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+func GetTrialBalance(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		endDate := c.Query("end_date")
+		if !validDate(endDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must use the YYYY-MM-DD format"})
+			return
+		}
+
+		balances, err := accountBalances(db, c.Query("branch_id"), endDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate trial balance", "details": err.Error()})
+			return
+		}
+
+		var rows []gin.H
+		var totalDebit, totalCredit float64
+		for _, b := range balances {
+			net := roundAmount(b.Debit - b.Credit)
+			if net == 0 {
+				continue
+			}
+
+			row := gin.H{"account_id": b.AccountID, "code": b.Code, "name": b.Name, "type": b.Type, "debit": 0.0, "credit": 0.0}
+			if net > 0 {
+				row["debit"] = net
+				totalDebit += net
+			} else {
+				row["credit"] = -net
+				totalCredit -= net
+			}
+			rows = append(rows, row)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"end_date":     endDate,
+			"accounts":     rows,
+			"total_debit":  roundAmount(totalDebit),
+			"total_credit": roundAmount(totalCredit),
+			"balanced":     roundAmount(totalDebit) == roundAmount(totalCredit),
+		})
+	}
+}
+
+func GetAccountLedger(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var account models.Account
+		if err := db.First(&account, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+			return
+		}
+
+		startDate := c.Query("start_date")
+		endDate := c.Query("end_date")
+		if !validDate(startDate) || !validDate(endDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must use the YYYY-MM-DD format"})
+			return
+		}
+		branchID := c.Query("branch_id")
+
+		base := func() *gorm.DB {
+			query := db.Table("journal_lines").
+				Joins("JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id").
+				Where("journal_lines.account_id = ?", account.ID)
+			if branchID != "" {
+				query = query.Where("journal_entries.branch_id = ?", branchID)
+			}
+			return query
+		}
+
+		var opening struct {
+			Debit  float64
+			Credit float64
+		}
+		if startDate != "" {
+			if err := base().Where("DATE(journal_entries.date) < ?", startDate).
+				Select("COALESCE(SUM(journal_lines.debit), 0) as debit, COALESCE(SUM(journal_lines.credit), 0) as credit").
+				Scan(&opening).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate opening balance", "details": err.Error()})
+				return
+			}
+		}
+
+		signed := func(debit, credit float64) float64 {
+			if account.DebitNormal() {
+				return debit - credit
+			}
+			return credit - debit
+		}
+
+		var lines []struct {
+			EntryID     uint      `json:"entry_id"`
+			Date        time.Time `json:"date"`
+			BranchID    uint      `json:"branch_id"`
+			Description string    `json:"description"`
+			SourceType  string    `json:"source_type"`
+			SourceID    uint      `json:"source_id"`
+			Debit       float64   `json:"debit"`
+			Credit      float64   `json:"credit"`
+			Balance     float64   `json:"balance" gorm:"-"`
+		}
+
+		query := base().Select("journal_entries.id as entry_id, journal_entries.date, journal_entries.branch_id, " +
+			"journal_entries.description, journal_entries.source_type, journal_entries.source_id, " +
+			"journal_lines.debit, journal_lines.credit")
+		if startDate != "" {
+			query = query.Where("DATE(journal_entries.date) >= ?", startDate)
+		}
+		if endDate != "" {
+			query = query.Where("DATE(journal_entries.date) <= ?", endDate)
+		}
+
+		if err := query.Order("journal_entries.date asc, journal_entries.id asc").Scan(&lines).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve account ledger", "details": err.Error()})
+			return
+		}
+
+		openingBalance := roundAmount(signed(opening.Debit, opening.Credit))
+		balance := openingBalance
+		for i := range lines {
+			balance += signed(lines[i].Debit, lines[i].Credit)
+			lines[i].Balance = roundAmount(balance)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"account":         account,
+			"opening_balance": openingBalance,
+			"closing_balance": roundAmount(balance),
+			"lines":           lines,
+		})
+	}
+}
+
+func GetBalanceSheet(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+		if !validDate(date) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must use the YYYY-MM-DD format"})
+			return
+		}
+
+		balances, err := accountBalances(db, c.Query("branch_id"), date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance sheet", "details": err.Error()})
+			return
+		}
+
+		sections := map[string][]accountBalance{}
+		totals := map[string]float64{}
+		for _, b := range balances {
+			totals[b.Type] += b.Balance
+			if b.Balance != 0 && (b.Type == "asset" || b.Type == "liability" || b.Type == "equity") {
+				sections[b.Type] = append(sections[b.Type], b)
+			}
+		}
+
+		currentEarnings := roundAmount(totals["revenue"] - totals["expense"])
+		totalAssets := roundAmount(totals["asset"])
+		totalLiabilities := roundAmount(totals["liability"])
+		totalEquity := roundAmount(totals["equity"] + currentEarnings)
+
+		c.JSON(http.StatusOK, gin.H{
+			"date":                         date,
+			"assets":                       sections["asset"],
+			"liabilities":                  sections["liability"],
+			"equity":                       sections["equity"],
+			"current_earnings":             currentEarnings,
+			"total_assets":                 totalAssets,
+			"total_liabilities":            totalLiabilities,
+			"total_equity":                 totalEquity,
+			"total_liabilities_and_equity": roundAmount(totalLiabilities + totalEquity),
+			"balanced":                     totalAssets == roundAmount(totalLiabilities+totalEquity),
+		})
+	}
+}
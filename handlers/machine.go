@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"laundre/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MachineRequest struct {
+	BranchID     uint    `json:"branch_id" binding:"required"`
+	Name         string  `json:"name" binding:"required,max=50"`
+	Type         string  `json:"type" binding:"required,oneof=washer dryer ironer other"`
+	CapacityKg   float64 `json:"capacity_kg" binding:"required,gt=0"`
+	SerialNumber string  `json:"serial_number" binding:"max=50"`
+	Status       string  `json:"status" binding:"omitempty,oneof=active retired"`
+	Notes        string  `json:"notes"`
+}
+
+func (req MachineRequest) apply(machine *models.Machine) {
+	machine.BranchID = req.BranchID
+	machine.Name = req.Name
+	machine.Type = req.Type
+	machine.CapacityKg = req.CapacityKg
+	machine.SerialNumber = req.SerialNumber
+	machine.Notes = req.Notes
+	if req.Status != "" {
+		machine.Status = req.Status
+	}
+}
+
+func CreateMachine(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MachineRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var branch models.Branch
+		if err := db.First(&branch, req.BranchID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+			return
+		}
+
+		machine := models.Machine{Status: "active"}
+		req.apply(&machine)
+
+		if err := db.Create(&machine).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create machine", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Machine created successfully", "data": machine})
+	}
+}
+
+func GetMachines(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !branchQueryAllowed(c) {
+			return
+		}
+
+		var machines []models.Machine
+
+		query := db.Preload("Branch")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+		if machineType := c.Query("type"); machineType != "" {
+			query = query.Where("type = ?", machineType)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		if err := query.Order("branch_id asc, name asc").Find(&machines).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve machines", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": machines})
+	}
+}
+
+func GetMachineByID(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var machine models.Machine
+		if err := db.Preload("Branch").First(&machine, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			return
+		}
+
+		if !canAccessBranch(c, machine.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var current *models.MachineLoad
+		var load models.MachineLoad
+		if err := db.Preload("Orders").Where("machine_id = ? AND status = ?", machine.ID, "running").
+			First(&load).Error; err == nil {
+			current = &load
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": machine, "current_load": current})
+	}
+}
+
+func UpdateMachine(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var machine models.Machine
+		if err := db.First(&machine, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			return
+		}
+
+		var req MachineRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.Status == "active" && (machine.Status == "maintenance" || machine.Status == "broken") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Machine is down, end its downtime to bring it back into service"})
+			return
+		}
+		req.apply(&machine)
+
+		if err := db.Save(&machine).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Machine updated successfully", "data": machine})
+	}
+}
+
+func DeleteMachine(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var count int64
+		db.Model(&models.MachineLoad{}).Where("machine_id = ?", c.Param("id")).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Machine has load history, retire it instead"})
+			return
+		}
+
+		result := db.Delete(&models.Machine{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete machine", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Machine deleted successfully"})
+	}
+}
+
+type machineUsage struct {
+	Loads       int     `json:"loads"`
+	WeightKg    float64 `json:"weight_kg"`
+	RunMinutes  float64 `json:"run_minutes"`
+	FillRate    float64 `json:"average_fill_rate"`
+	Utilization float64 `json:"utilization"`
+	fillTotal   float64
+}
+
+func (u *machineUsage) add(load models.MachineLoad, capacity float64) {
+	u.Loads++
+	u.WeightKg += load.Weight
+	u.RunMinutes += load.FinishedAt.Sub(*load.StartedAt).Minutes()
+	u.fillTotal += load.FillRate(capacity)
+}
+
+// finish rounds the totals and derives the averages. available is the
+// number of minutes the machine could have run.
+func (u *machineUsage) finish(available float64) {
+	u.WeightKg = roundAmount(u.WeightKg)
+	u.RunMinutes = roundAmount(u.RunMinutes)
+	if u.Loads > 0 {
+		u.FillRate = roundAmount(u.fillTotal / float64(u.Loads))
+	}
+	if available > 0 {
+		u.Utilization = roundAmount(u.RunMinutes / available * 100)
+	}
+}
+
+// GetMachineUtilization summarises finished loads per machine and per day:
+// number of loads, weight washed, average fill rate against capacity and
+// running time as a share of the operating hours (hours_per_day, default 24).
+// Loads are attributed to the day they started.
+func GetMachineUtilization(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !branchQueryAllowed(c) {
+			return
+		}
+
+		now := time.Now()
+		startDate := c.DefaultQuery("start_date", now.AddDate(0, 0, -6).Format("2006-01-02"))
+		endDate := c.DefaultQuery("end_date", now.Format("2006-01-02"))
+		start, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must use the YYYY-MM-DD format"})
+			return
+		}
+		end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil || end.Before(start) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be a YYYY-MM-DD date on or after start_date"})
+			return
+		}
+		hoursPerDay, err := strconv.ParseFloat(c.DefaultQuery("hours_per_day", "24"), 64)
+		if err != nil || hoursPerDay <= 0 || hoursPerDay > 24 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hours_per_day must be between 0 and 24"})
+			return
+		}
+
+		machineQuery := db.Model(&models.Machine{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			machineQuery = machineQuery.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			machineQuery = machineQuery.Where("branch_id = ?", currentBranchID(c))
+		}
+		if machineID := c.Query("machine_id"); machineID != "" {
+			machineQuery = machineQuery.Where("id = ?", machineID)
+		}
+
+		var machines []models.Machine
+		if err := machineQuery.Order("branch_id asc, name asc").Find(&machines).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve machines", "details": err.Error()})
+			return
+		}
+
+		ids := make([]uint, 0, len(machines))
+		for _, machine := range machines {
+			ids = append(ids, machine.ID)
+		}
+
+		var loads []models.MachineLoad
+		if err := db.Where("machine_id IN ? AND status = ? AND started_at >= ? AND started_at < ?",
+			ids, "finished", start, end.AddDate(0, 0, 1)).Find(&loads).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve machine loads", "details": err.Error()})
+			return
+		}
+
+		days := int(end.Sub(start).Hours()/24) + 1
+		dayMinutes := hoursPerDay * 60
+
+		totals := map[uint]*machineUsage{}
+		daily := map[uint]map[string]*machineUsage{}
+		capacity := map[uint]float64{}
+		for _, machine := range machines {
+			totals[machine.ID] = &machineUsage{}
+			daily[machine.ID] = map[string]*machineUsage{}
+			capacity[machine.ID] = machine.CapacityKg
+		}
+
+		for _, load := range loads {
+			if load.StartedAt == nil || load.FinishedAt == nil {
+				continue
+			}
+			totals[load.MachineID].add(load, capacity[load.MachineID])
+
+			day := load.StartedAt.In(time.Local).Format("2006-01-02")
+			usage, found := daily[load.MachineID][day]
+			if !found {
+				usage = &machineUsage{}
+				daily[load.MachineID][day] = usage
+			}
+			usage.add(load, capacity[load.MachineID])
+		}
+
+		rows := make([]gin.H, 0, len(machines))
+		for _, machine := range machines {
+			total := totals[machine.ID]
+			total.finish(dayMinutes * float64(days))
+
+			dates := make([]string, 0, len(daily[machine.ID]))
+			for day := range daily[machine.ID] {
+				dates = append(dates, day)
+			}
+			sort.Strings(dates)
+
+			perDay := make([]gin.H, 0, len(dates))
+			for _, day := range dates {
+				usage := daily[machine.ID][day]
+				usage.finish(dayMinutes)
+				perDay = append(perDay, gin.H{"date": day, "usage": usage})
+			}
+
+			rows = append(rows, gin.H{
+				"machine_id":  machine.ID,
+				"name":        machine.Name,
+				"type":        machine.Type,
+				"branch_id":   machine.BranchID,
+				"capacity_kg": machine.CapacityKg,
+				"total":       total,
+				"daily":       perDay,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"start_date":    startDate,
+			"end_date":      endDate,
+			"hours_per_day": hoursPerDay,
+			"data":          rows,
+		})
+	}
+}
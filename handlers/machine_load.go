@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"laundre/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MachineLoadRequest assigns orders to a load. Weight defaults to the sum of
+// the orders' weights; set it when only part of an order goes in.
+type MachineLoadRequest struct {
+	MachineID uint    `json:"machine_id" binding:"required"`
+	OrderIDs  []uint  `json:"order_ids" binding:"required,min=1"`
+	Weight    float64 `json:"weight" binding:"min=0"`
+}
+
+var errMachineLoad = errors.New("invalid machine load")
+
+// apply validates the request against the machine and copies it onto the
+// load. Loads heavier than the machine's capacity are refused.
+func (req MachineLoadRequest) apply(c *gin.Context, db *gorm.DB, load *models.MachineLoad) (int, string) {
+	var machine models.Machine
+	if err := db.First(&machine, req.MachineID).Error; err != nil {
+		return http.StatusNotFound, "Machine not found"
+	}
+	if !canAccessBranch(c, machine.BranchID) {
+		return http.StatusForbidden, "Access denied for this branch"
+	}
+	if machine.Status != "active" {
+		return http.StatusUnprocessableEntity, fmt.Sprintf("Machine is %s", machine.Status)
+	}
+
+	var orders []models.Order
+	if err := db.Where("id IN ?", req.OrderIDs).Find(&orders).Error; err != nil {
+		return http.StatusInternalServerError, "Failed to retrieve orders"
+	}
+	if len(orders) != len(req.OrderIDs) {
+		return http.StatusBadRequest, "One or more orders were not found"
+	}
+
+	var weight float64
+	for _, order := range orders {
+		if order.BranchID != machine.BranchID {
+			return http.StatusBadRequest, fmt.Sprintf("Order %d belongs to another branch", order.ID)
+		}
+		if order.Status == "done" || order.Status == "cancelled" {
+			return http.StatusBadRequest, fmt.Sprintf("Order %d is already %s", order.ID, order.Status)
+		}
+		weight += order.Weight
+	}
+	if req.Weight > 0 {
+		weight = req.Weight
+	}
+	if weight > machine.CapacityKg {
+		return http.StatusUnprocessableEntity, fmt.Sprintf("Load of %.2f kg exceeds the machine capacity of %.2f kg", weight, machine.CapacityKg)
+	}
+
+	load.MachineID = machine.ID
+	load.BranchID = machine.BranchID
+	load.Weight = roundAmount(weight)
+	load.Orders = orders
+	load.Machine = machine
+
+	return 0, ""
+}
+
+func findMachineLoad(c *gin.Context, db *gorm.DB) (models.MachineLoad, bool) {
+	var load models.MachineLoad
+	if err := db.Preload("Machine").Preload("Orders").First(&load, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Machine load not found"})
+		return load, false
+	}
+
+	if !canAccessBranch(c, load.BranchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+		return load, false
+	}
+
+	return load, true
+}
+
+func CreateMachineLoad(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MachineLoadRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		load := models.MachineLoad{Status: "planned", CreatedBy: currentUserID(c)}
+		if status, message := req.apply(c, db, &load); status != 0 {
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+
+		if err := db.Omit("Machine", "Orders.*").Create(&load).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create machine load", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":   "Machine load created successfully",
+			"data":      load,
+			"fill_rate": roundAmount(load.FillRate(load.Machine.CapacityKg)),
+		})
+	}
+}
+
+func GetMachineLoads(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !branchQueryAllowed(c) {
+			return
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		date := c.Query("date")
+		if !validDate(date) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must use the YYYY-MM-DD format"})
+			return
+		}
+
+		query := db.Model(&models.MachineLoad{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+		if machineID := c.Query("machine_id"); machineID != "" {
+			query = query.Where("machine_id = ?", machineID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if date != "" {
+			query = query.Where("DATE(COALESCE(started_at, created_at)) = ?", date)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var loads []models.MachineLoad
+		if err := query.Preload("Machine").Preload("Orders").Order("created_at desc, id desc").
+			Offset(offset).Limit(limit).Find(&loads).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve machine loads", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": loads,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+func GetMachineLoad(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		load, ok := findMachineLoad(c, db)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": load, "fill_rate": roundAmount(load.FillRate(load.Machine.CapacityKg))})
+	}
+}
+
+// UpdateMachineLoad changes the machine, orders or weight of a load that has
+// not started yet.
+func UpdateMachineLoad(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		load, ok := findMachineLoad(c, db)
+		if !ok {
+			return
+		}
+
+		if load.Status != "planned" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only planned loads can be changed"})
+			return
+		}
+
+		var req MachineLoadRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if status, message := req.apply(c, db, &load); status != 0 {
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit("Orders", "Machine").Save(&load).Error; err != nil {
+				return err
+			}
+			return tx.Model(&load).Association("Orders").Replace(load.Orders)
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine load", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Machine load updated successfully",
+			"data":      load,
+			"fill_rate": roundAmount(load.FillRate(load.Machine.CapacityKg)),
+		})
+	}
+}
+
+// StartMachineLoad starts the cycle. The machine must be active and idle.
+func StartMachineLoad(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		load, ok := findMachineLoad(c, db)
+		if !ok {
+			return
+		}
+
+		message := ""
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var machine models.Machine
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&machine, load.MachineID).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&load, load.ID).Error; err != nil {
+				return err
+			}
+			if load.Status != "planned" {
+				message = "Only planned loads can be started"
+				return errMachineLoad
+			}
+			if machine.Status != "active" {
+				message = fmt.Sprintf("Machine is %s", machine.Status)
+				return errMachineLoad
+			}
+
+			var running int64
+			tx.Model(&models.MachineLoad{}).Where("machine_id = ? AND status = ?", machine.ID, "running").Count(&running)
+			if running > 0 {
+				message = "Machine is already running another load"
+				return errMachineLoad
+			}
+
+			now := time.Now()
+			load.Status = "running"
+			load.StartedAt = &now
+			return tx.Model(&load).Updates(map[string]interface{}{"status": load.Status, "started_at": now}).Error
+		})
+
+		if err != nil {
+			if err == errMachineLoad {
+				c.JSON(http.StatusConflict, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start machine load", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Machine load started", "data": load})
+	}
+}
+
+func FinishMachineLoad(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		load, ok := findMachineLoad(c, db)
+		if !ok {
+			return
+		}
+
+		if load.Status != "running" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only running loads can be finished"})
+			return
+		}
+
+		// The status is checked again by the update, so a load cancelled or
+		// finished in the meantime is not finished twice.
+		now := time.Now()
+		result := db.Model(&models.MachineLoad{}).Where("id = ? AND status = ?", load.ID, "running").
+			Updates(map[string]interface{}{"status": "finished", "finished_at": now})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finish machine load", "details": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only running loads can be finished"})
+			return
+		}
+		load.Status = "finished"
+		load.FinishedAt = &now
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Machine load finished",
+			"data":        load,
+			"run_minutes": roundAmount(now.Sub(*load.StartedAt).Minutes()),
+		})
+	}
+}
+
+func CancelMachineLoad(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		load, ok := findMachineLoad(c, db)
+		if !ok {
+			return
+		}
+
+		if load.Status != "planned" && load.Status != "running" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Machine load is already closed"})
+			return
+		}
+
+		result := db.Model(&models.MachineLoad{}).Where("id = ? AND status IN ?", load.ID, []string{"planned", "running"}).
+			Update("status", "cancelled")
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel machine load", "details": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Machine load is already closed"})
+			return
+		}
+		load.Status = "cancelled"
+
+		c.JSON(http.StatusOK, gin.H{"message": "Machine load cancelled", "data": load})
+	}
+}
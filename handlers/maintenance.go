@@ -0,0 +1,649 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"laundre/models"
+	"laundre/scheduler"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MaintenancePlanRequest struct {
+	MachineID      uint   `json:"machine_id" binding:"required"`
+	Name           string `json:"name" binding:"required,max=100"`
+	Description    string `json:"description"`
+	IntervalCycles int    `json:"interval_cycles" binding:"min=0"`
+	IntervalDays   int    `json:"interval_days" binding:"min=0"`
+	Active         *bool  `json:"active"`
+}
+
+var errMaintenance = errors.New("invalid maintenance request")
+
+func CreateMaintenancePlan(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req MaintenancePlanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.IntervalCycles == 0 && req.IntervalDays == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "interval_cycles or interval_days is required"})
+			return
+		}
+
+		var machine models.Machine
+		if err := db.First(&machine, req.MachineID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			return
+		}
+
+		cycles, err := models.MachineCycles(db, machine.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count machine cycles", "details": err.Error()})
+			return
+		}
+
+		plan := models.MaintenancePlan{
+			MachineID:      machine.ID,
+			Name:           req.Name,
+			Description:    req.Description,
+			IntervalCycles: req.IntervalCycles,
+			IntervalDays:   req.IntervalDays,
+			Active:         true,
+			BaselineAt:     time.Now(),
+			BaselineCycles: cycles,
+		}
+		if req.Active != nil {
+			plan.Active = *req.Active
+		}
+
+		if err := db.Create(&plan).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create maintenance plan", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Maintenance plan created successfully", "data": plan})
+	}
+}
+
+// GetMaintenancePlans lists plans with how far each one is from coming due.
+func GetMaintenancePlans(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !branchQueryAllowed(c) {
+			return
+		}
+
+		query := db.Preload("Machine").Joins("JOIN machines ON machines.id = maintenance_plans.machine_id")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("machines.branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("machines.branch_id = ?", currentBranchID(c))
+		}
+		if machineID := c.Query("machine_id"); machineID != "" {
+			query = query.Where("maintenance_plans.machine_id = ?", machineID)
+		}
+
+		var plans []models.MaintenancePlan
+		if err := query.Order("maintenance_plans.machine_id asc, maintenance_plans.id asc").Find(&plans).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve maintenance plans", "details": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		rows := make([]gin.H, 0, len(plans))
+		for _, plan := range plans {
+			cycles, err := models.MachineCycles(db, plan.MachineID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count machine cycles", "details": err.Error()})
+				return
+			}
+
+			row := gin.H{"plan": plan, "cycles_since_service": cycles - plan.BaselineCycles}
+			if plan.IntervalDays > 0 {
+				row["next_due_date"] = plan.BaselineAt.AddDate(0, 0, plan.IntervalDays).Format("2006-01-02")
+			}
+			if plan.IntervalCycles > 0 {
+				row["cycles_remaining"] = int64(plan.IntervalCycles) - (cycles - plan.BaselineCycles)
+			}
+			row["due"], _ = plan.Due(now, cycles)
+			rows = append(rows, row)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": rows})
+	}
+}
+
+func UpdateMaintenancePlan(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var plan models.MaintenancePlan
+		if err := db.First(&plan, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance plan not found"})
+			return
+		}
+
+		var req MaintenancePlanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.IntervalCycles == 0 && req.IntervalDays == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "interval_cycles or interval_days is required"})
+			return
+		}
+		if req.MachineID != plan.MachineID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A plan cannot be moved to another machine"})
+			return
+		}
+
+		plan.Name = req.Name
+		plan.Description = req.Description
+		plan.IntervalCycles = req.IntervalCycles
+		plan.IntervalDays = req.IntervalDays
+		if req.Active != nil {
+			plan.Active = *req.Active
+		}
+
+		if err := db.Save(&plan).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update maintenance plan", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Maintenance plan updated successfully", "data": plan})
+	}
+}
+
+func DeleteMaintenancePlan(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.MaintenancePlan{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete maintenance plan", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance plan not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Maintenance plan deleted successfully"})
+	}
+}
+
+// RunMaintenancePlans generates due tasks without waiting for the scheduler.
+func RunMaintenancePlans(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		created, err := scheduler.RunMaintenancePlans(db, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run maintenance plans", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Maintenance plans processed", "created": created})
+	}
+}
+
+// CreateMaintenanceTask logs ad-hoc work such as a repair.
+func CreateMaintenanceTask(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			MachineID uint   `json:"machine_id" binding:"required"`
+			Title     string `json:"title" binding:"required,max=150"`
+			DueDate   string `json:"due_date"`
+			Notes     string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var machine models.Machine
+		if err := db.First(&machine, req.MachineID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			return
+		}
+		if !canAccessBranch(c, machine.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		dueDate := time.Now()
+		if req.DueDate != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", req.DueDate, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "due_date must use the YYYY-MM-DD format"})
+				return
+			}
+			dueDate = parsed
+		}
+
+		task := models.MaintenanceTask{
+			MachineID: machine.ID,
+			BranchID:  machine.BranchID,
+			Title:     req.Title,
+			Trigger:   "manual",
+			Status:    "open",
+			DueDate:   dueDate,
+			Notes:     req.Notes,
+		}
+
+		if err := db.Create(&task).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create maintenance task", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Maintenance task created successfully", "data": task})
+	}
+}
+
+func GetMaintenanceTasks(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !branchQueryAllowed(c) {
+			return
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.MaintenanceTask{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+		if machineID := c.Query("machine_id"); machineID != "" {
+			query = query.Where("machine_id = ?", machineID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if c.Query("overdue") == "true" {
+			query = query.Where("status = ? AND due_date < ?", "open", time.Now().Format("2006-01-02"))
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var tasks []models.MaintenanceTask
+		if err := query.Preload("Machine").Preload("Plan").Order("due_date asc, id asc").
+			Offset(offset).Limit(limit).Find(&tasks).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve maintenance tasks", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": tasks,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+// endDowntime closes the machine's open downtime, if any, and puts it back
+// into service.
+func endDowntime(tx *gorm.DB, machine *models.Machine) (*models.MachineDowntime, error) {
+	var downtime models.MachineDowntime
+	err := tx.Where("machine_id = ? AND ended_at IS NULL", machine.ID).First(&downtime).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	downtime.EndedAt = &now
+	if err := tx.Save(&downtime).Error; err != nil {
+		return nil, err
+	}
+
+	machine.Status = "active"
+	if err := tx.Model(machine).Update("status", "active").Error; err != nil {
+		return nil, err
+	}
+	return &downtime, nil
+}
+
+// CompleteMaintenanceTask records the work done. A cost can be booked as an
+// expense linked to the task, and a downtime opened for the task ends with it.
+// Completing a planned task restarts the plan's interval.
+func CompleteMaintenanceTask(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Notes         string  `json:"notes"`
+			Cost          float64 `json:"cost" binding:"min=0"`
+			CreateExpense bool    `json:"create_expense"`
+			CategoryID    *uint   `json:"category_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if req.CreateExpense && req.Cost == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A cost is required to create an expense"})
+			return
+		}
+		if !validExpenseCategory(db, req.CategoryID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expense category"})
+			return
+		}
+
+		var task models.MaintenanceTask
+		if err := db.First(&task, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance task not found"})
+			return
+		}
+		if !canAccessBranch(c, task.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		userID := currentUserID(c)
+		message := ""
+		var expense *models.Expense
+		var downtime *models.MachineDowntime
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Machine").First(&task, task.ID).Error; err != nil {
+				return err
+			}
+			if task.Status != "open" {
+				message = "Only open tasks can be completed"
+				return errMaintenance
+			}
+
+			now := time.Now()
+			task.Status = "completed"
+			task.CompletedBy = &userID
+			task.CompletedAt = &now
+			task.Cost = req.Cost
+			if req.Notes != "" {
+				task.Notes = req.Notes
+			}
+
+			if req.CreateExpense {
+				expense = &models.Expense{
+					BranchID:    task.BranchID,
+					CategoryID:  req.CategoryID,
+					Description: fmt.Sprintf("Maintenance: %s", task.Title),
+					Amount:      req.Cost,
+					SubmittedBy: &userID,
+				}
+				status, err := approvalStatus(c, tx, task.BranchID, req.Cost)
+				if err != nil {
+					return err
+				}
+				expense.Status = status
+				if status == "approved" && isAdmin(c) {
+					expense.ApprovedBy = &userID
+					expense.ApprovedAt = &now
+				}
+				if err := tx.Create(expense).Error; err != nil {
+					return err
+				}
+				if expense.Status == "approved" {
+					if err := models.PostExpense(tx, *expense); err != nil {
+						return err
+					}
+				}
+				task.ExpenseID = &expense.ID
+			}
+
+			if err := tx.Omit("Machine", "Plan", "Expense").Save(&task).Error; err != nil {
+				return err
+			}
+
+			var open int64
+			if err := tx.Model(&models.MachineDowntime{}).
+				Where("machine_id = ? AND task_id = ? AND ended_at IS NULL", task.MachineID, task.ID).
+				Count(&open).Error; err != nil {
+				return err
+			}
+			if open > 0 {
+				var err error
+				if downtime, err = endDowntime(tx, &task.Machine); err != nil {
+					return err
+				}
+			}
+
+			if task.PlanID == nil {
+				return nil
+			}
+			cycles, err := models.MachineCycles(tx, task.MachineID)
+			if err != nil {
+				return err
+			}
+			return tx.Model(&models.MaintenancePlan{}).Where("id = ?", *task.PlanID).Updates(map[string]interface{}{
+				"baseline_at":       now,
+				"baseline_cycles":   cycles,
+				"last_completed_at": now,
+			}).Error
+		})
+
+		if err != nil {
+			if err == errMaintenance {
+				c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete maintenance task", "details": err.Error()})
+			}
+			return
+		}
+
+		response := gin.H{"message": "Maintenance task completed", "data": task}
+		if expense != nil {
+			response["expense"] = expense
+		}
+		if downtime != nil {
+			response["downtime"] = downtime
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func CancelMaintenanceTask(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var task models.MaintenanceTask
+		if err := db.First(&task, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance task not found"})
+			return
+		}
+
+		if task.Status != "open" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only open tasks can be cancelled"})
+			return
+		}
+
+		if err := db.Model(&task).Update("status", "cancelled").Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel maintenance task", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Maintenance task cancelled", "data": task})
+	}
+}
+
+// StartMachineDowntime takes a machine out of service so no loads can be
+// assigned to it. A breakdown without a task gets a repair task opened and
+// cancels the load it interrupted; planned maintenance has to wait until the
+// running load is finished.
+func StartMachineDowntime(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Type   string `json:"type" binding:"required,oneof=breakdown maintenance"`
+			Reason string `json:"reason" binding:"required"`
+			TaskID *uint  `json:"task_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var machine models.Machine
+		if err := db.First(&machine, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			return
+		}
+		if !canAccessBranch(c, machine.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		userID := currentUserID(c)
+		message := ""
+		downtime := models.MachineDowntime{
+			MachineID:  machine.ID,
+			Type:       req.Type,
+			Reason:     req.Reason,
+			TaskID:     req.TaskID,
+			StartedAt:  time.Now(),
+			ReportedBy: userID,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&machine, machine.ID).Error; err != nil {
+				return err
+			}
+			if machine.Status != "active" {
+				message = fmt.Sprintf("Machine is already %s", machine.Status)
+				return errMaintenance
+			}
+
+			running := tx.Model(&models.MachineLoad{}).Where("machine_id = ? AND status = ?", machine.ID, "running")
+			if req.Type == "maintenance" {
+				var count int64
+				if err := running.Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					message = "Machine is running a load, finish or cancel it first"
+					return errMaintenance
+				}
+			} else if err := running.Update("status", "cancelled").Error; err != nil {
+				return err
+			}
+
+			if req.TaskID != nil {
+				var task models.MaintenanceTask
+				if err := tx.First(&task, *req.TaskID).Error; err != nil || task.MachineID != machine.ID {
+					message = "Maintenance task not found for this machine"
+					return errMaintenance
+				}
+			} else if req.Type == "breakdown" {
+				task := models.MaintenanceTask{
+					MachineID: machine.ID,
+					BranchID:  machine.BranchID,
+					Title:     fmt.Sprintf("Repair %s", machine.Name),
+					Trigger:   "manual",
+					Status:    "open",
+					DueDate:   downtime.StartedAt,
+					Notes:     req.Reason,
+				}
+				if err := tx.Create(&task).Error; err != nil {
+					return err
+				}
+				downtime.TaskID = &task.ID
+			}
+
+			if err := tx.Create(&downtime).Error; err != nil {
+				return err
+			}
+
+			machine.Status = "maintenance"
+			if req.Type == "breakdown" {
+				machine.Status = "broken"
+			}
+			return tx.Model(&machine).Update("status", machine.Status).Error
+		})
+
+		if err != nil {
+			if err == errMaintenance {
+				c.JSON(http.StatusConflict, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record downtime", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Machine taken out of service", "data": downtime, "machine": machine})
+	}
+}
+
+func EndMachineDowntime(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var machine models.Machine
+		if err := db.First(&machine, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			return
+		}
+		if !canAccessBranch(c, machine.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var downtime *models.MachineDowntime
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			downtime, err = endDowntime(tx, &machine)
+			return err
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end downtime", "details": err.Error()})
+			return
+		}
+		if downtime == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Machine has no open downtime"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Machine back in service", "data": downtime, "machine": machine})
+	}
+}
+
+// GetMachineDowntimes lists a machine's downtime history with durations in
+// minutes; open downtimes count up to now.
+func GetMachineDowntimes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var machine models.Machine
+		if err := db.First(&machine, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			return
+		}
+		if !canAccessBranch(c, machine.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var downtimes []models.MachineDowntime
+		if err := db.Where("machine_id = ?", machine.ID).Order("started_at desc").Find(&downtimes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve downtimes", "details": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		rows := make([]gin.H, 0, len(downtimes))
+		var total float64
+		for _, downtime := range downtimes {
+			end := now
+			if downtime.EndedAt != nil {
+				end = *downtime.EndedAt
+			}
+			minutes := roundAmount(end.Sub(downtime.StartedAt).Minutes())
+			total += minutes
+			rows = append(rows, gin.H{"downtime": downtime, "minutes": minutes})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"machine": machine, "data": rows, "total_minutes": roundAmount(total)})
+	}
+}
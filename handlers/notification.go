@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"laundre/config"
+	"laundre/models"
+	"laundre/notifications"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetNotifications(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.Notification{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+		for _, field := range []string{"status", "event", "customer_id", "order_id"} {
+			if value := c.Query(field); value != "" {
+				query = query.Where(field+" = ?", value)
+			}
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var items []models.Notification
+		if err := query.Preload("Customer").Order("created_at desc, id desc").
+			Offset(offset).Limit(limit).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": items,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+// RetryNotification puts a failed notification back in the queue with a
+// fresh set of attempts.
+func RetryNotification(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var notification models.Notification
+		if err := db.First(&notification, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+
+		if !canAccessBranch(c, notification.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		if notification.Status != "failed" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only failed notifications can be retried"})
+			return
+		}
+
+		notification.Status = "pending"
+		notification.Attempts = 0
+		notification.NextAttemptAt = nil
+		if err := db.Model(&notification).Updates(map[string]interface{}{
+			"status":          notification.Status,
+			"attempts":        0,
+			"next_attempt_at": nil,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry notification", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Notification queued for retry", "data": notification})
+	}
+}
+
+// SendOrderNotification queues a message about an order by hand, e.g. when
+// the courier leaves with a delivery.
+func SendOrderNotification(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var order models.Order
+		if err := db.First(&order, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+
+		if !canAccessBranch(c, order.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var req struct {
+			Event string `json:"event" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		if !notifications.ValidEvent(req.Event) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification event"})
+			return
+		}
+
+		notification, err := notifications.Queue(db, req.Event, order.ID, notifications.Data{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue notification", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Notification queued", "data": notification})
+	}
+}
+
+// UpdateNotificationPreferences sets how a customer is messaged, or opts
+// them out entirely.
+func UpdateNotificationPreferences(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var customer models.Customer
+		if err := db.First(&customer, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+
+		var req struct {
+			OptOut   *bool  `json:"opt_out"`
+			Language string `json:"language" binding:"omitempty,oneof=id en"`
+			Channel  string `json:"channel" binding:"omitempty,oneof=whatsapp sms"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		updates := map[string]interface{}{}
+		if req.OptOut != nil {
+			updates["notifications_opt_out"] = *req.OptOut
+		}
+		if req.Language != "" {
+			updates["language"] = req.Language
+		}
+		if req.Channel != "" {
+			updates["notification_channel"] = req.Channel
+		}
+
+		if err := db.Model(&customer).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Notification preferences updated successfully", "data": customer})
+	}
+}
+
+// NotificationWebhook receives delivery receipts from the messaging gateway.
+// The gateway authenticates with the shared token in the X-Webhook-Token
+// header.
+func NotificationWebhook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.NotificationWebhookToken()
+		if token == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Webhook-Token")), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook token"})
+			return
+		}
+
+		var req struct {
+			MessageID string `json:"message_id" binding:"required"`
+			Status    string `json:"status" binding:"required,oneof=delivered read failed"`
+			Error     string `json:"error"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var notification models.Notification
+		if err := db.Where("provider_message_id = ?", req.MessageID).First(&notification).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+
+		updates := map[string]interface{}{"status": req.Status}
+		switch req.Status {
+		case "delivered":
+			updates["delivered_at"] = time.Now()
+		case "failed":
+			updates["last_error"] = req.Error
+		}
+
+		if err := db.Model(&notification).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Delivery status recorded"})
+	}
+}
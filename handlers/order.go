@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"laundre/models"
+	"laundre/notifications"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,9 +14,28 @@ import (
 )
 
 type OrderRequest struct {
-	BranchID   uint   `json:"branch_id" binding:"required"`
-	CustomerID uint   `json:"customer_id" binding:"required"`
-	Status     string `json:"status" binding:"omitempty,oneof=masuk proses urgent done"`
+	BranchID   uint    `json:"branch_id" binding:"required"`
+	CustomerID uint    `json:"customer_id" binding:"required"`
+	Status     string  `json:"status" binding:"omitempty,oneof=masuk proses urgent done"`
+	Service    string  `json:"service" binding:"omitempty,max=50"`
+	Weight     float64 `json:"weight" binding:"omitempty,min=0"`
+	// PickupAddressID and DeliveryAddressID are optional entries of the
+	// customer's address book.
+	PickupAddressID   *uint `json:"pickup_address_id"`
+	DeliveryAddressID *uint `json:"delivery_address_id"`
+}
+
+// applyOrderStatus updates stock for an order that has just been given its
+// current status: consumables are deducted once processing starts and put
+// back when the order is cancelled.
+func applyOrderStatus(tx *gorm.DB, order *models.Order, userID uint) error {
+	switch order.Status {
+	case "proses", "done":
+		return models.DeductOrderConsumables(tx, order, &userID)
+	case "cancelled":
+		return models.RestoreOrderConsumables(tx, order, &userID)
+	}
+	return nil
 }
 
 func CreateOrder(db *gorm.DB) gin.HandlerFunc {
@@ -28,14 +50,71 @@ func CreateOrder(db *gorm.DB) gin.HandlerFunc {
 			BranchID:   req.BranchID,
 			CustomerID: req.CustomerID,
 			Status:     req.Status,
+			Service:    req.Service,
+			Weight:     req.Weight,
+
+			PickupAddressID:   req.PickupAddressID,
+			DeliveryAddressID: req.DeliveryAddressID,
+		}
+		if order.Status == "done" {
+			now := time.Now()
+			order.CompletedAt = &now
 		}
 
-		if err := db.Create(&order).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var account *models.CorporateAccount
+		var balance, estimate float64
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			var customer models.Customer
+			if err := tx.First(&customer, order.CustomerID).Error; err != nil {
+				return err
+			}
+			order.Instructions = customer.Instructions()
+			for _, addressID := range []*uint{order.PickupAddressID, order.DeliveryAddressID} {
+				if err := checkOrderAddress(tx, customer.ID, addressID); err != nil {
+					return err
+				}
+			}
+
+			// Orders from a corporate account that is already at its credit
+			// limit, or would go over it at the contract price, are refused.
+			var err error
+			if account, err = models.FindCorporateAccount(tx, order.CustomerID); err != nil {
+				return err
+			}
+			if account != nil {
+				if price, found := account.ContractPrice(order.Service); found {
+					estimate = roundAmount(price * order.Weight)
+				}
+				if balance, err = account.CheckCredit(tx, estimate); err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+			if err := applyOrderStatus(tx, &order, currentUserID(c)); err != nil {
+				return err
+			}
+			_, err = notifications.Queue(tx, notifications.EventOrderReceived, order.ID, notifications.Data{})
+			return err
+		})
+
+		if err != nil {
+			switch err {
+			case models.ErrCreditLimit:
+				c.JSON(http.StatusUnprocessableEntity, creditLimitError(account, balance, estimate))
+			case gorm.ErrRecordNotFound:
+				c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			case errAddress:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Address does not belong to the order's customer"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
 			return
 		}
 
-		db.Preload("Branch").Preload("Customer").First(&order, order.ID)
+		db.Preload("Branch").Preload("Customer").Preload("PickupAddress").Preload("DeliveryAddress").First(&order, order.ID)
 
 		c.JSON(http.StatusCreated, gin.H{
 			"message": "Order created successfully",
@@ -88,7 +167,8 @@ func GetOrder(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 
 		var order models.Order
-		if err := db.Preload("Branch").Preload("Customer").First(&order, id).Error; err != nil {
+		if err := db.Preload("Branch").Preload("Customer").Preload("PickupAddress").Preload("DeliveryAddress").
+			First(&order, id).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 			return
 		}
@@ -115,16 +195,41 @@ func UpdateOrder(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		validStatuses := map[string]bool{"masuk": true, "proses": true, "urgent": true, "done": true}
+		validStatuses := map[string]bool{"masuk": true, "proses": true, "urgent": true, "done": true, "cancelled": true}
 		if !validStatuses[req.Status] {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
 			return
 		}
 
+		if order.DisposedAt != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order was closed as abandoned"})
+			return
+		}
+
+		becameReady := req.Status == "done" && order.Status != "done"
 		order.Status = req.Status
 		order.UpdatedAt = time.Now()
+		if becameReady {
+			order.CompletedAt = &order.UpdatedAt
+		} else if order.Status != "done" {
+			order.CompletedAt = nil
+		}
+
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			if err := tx.Save(&order).Error; err != nil {
+				return err
+			}
+			if err := applyOrderStatus(tx, &order, currentUserID(c)); err != nil {
+				return err
+			}
+			if becameReady {
+				_, err := notifications.Queue(tx, notifications.EventOrderReady, order.ID, notifications.Data{})
+				return err
+			}
+			return nil
+		})
 
-		if err := db.Save(&order).Error; err != nil {
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -138,16 +243,22 @@ func UpdateOrder(db *gorm.DB) gin.HandlerFunc {
 
 func DeleteOrder(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-
-		result := db.Delete(&models.Order{}, id)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		var order models.Order
+		if err := db.First(&order, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 			return
 		}
 
-		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			return deleteOrder(tx, order.ID, currentUserID(c), fmt.Sprintf("Reversal: order #%d deleted", order.ID))
+		})
+
+		if err != nil {
+			if errors.Is(err, errOrderInvoiced) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Order is on an invoice, void the invoice first"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete order", "details": err.Error()})
+			}
 			return
 		}
 
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"laundre/models"
+	"laundre/storage"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OrderItemRequest struct {
+	Tag       string `json:"tag" binding:"max=30"`
+	Type      string `json:"type" binding:"required,max=50"`
+	Color     string `json:"color" binding:"max=30"`
+	Brand     string `json:"brand" binding:"max=50"`
+	Condition string `json:"condition"`
+}
+
+func (req OrderItemRequest) apply(item *models.OrderItem) {
+	item.Tag = req.Tag
+	item.Type = req.Type
+	item.Color = req.Color
+	item.Brand = req.Brand
+	item.Condition = req.Condition
+}
+
+func findOrderForItems(c *gin.Context, db *gorm.DB) (models.Order, bool) {
+	var order models.Order
+	if err := db.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return order, false
+	}
+
+	if !canAccessBranch(c, order.BranchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+		return order, false
+	}
+
+	return order, true
+}
+
+func findOrderItem(c *gin.Context, db *gorm.DB) (models.OrderItem, bool) {
+	order, ok := findOrderForItems(c, db)
+	if !ok {
+		return models.OrderItem{}, false
+	}
+
+	var item models.OrderItem
+	if err := db.Where("order_id = ?", order.ID).First(&item, c.Param("item_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
+		return item, false
+	}
+	item.Order = order
+
+	return item, true
+}
+
+func AddOrderItem(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findOrderForItems(c, db)
+		if !ok {
+			return
+		}
+
+		if order.PickedUpAt != nil || order.Status == "cancelled" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Items cannot be added to a closed order"})
+			return
+		}
+
+		var req OrderItemRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		item := models.OrderItem{OrderID: order.ID, Status: "received"}
+		req.apply(&item)
+
+		if err := db.Omit("Order").Create(&item).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add order item", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Order item added successfully", "data": item})
+	}
+}
+
+func GetOrderItems(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findOrderForItems(c, db)
+		if !ok {
+			return
+		}
+
+		var items []models.OrderItem
+		if err := db.Preload("Photos").Where("order_id = ?", order.ID).Order("id asc").Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order items", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": items})
+	}
+}
+
+func UpdateOrderItem(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		item, ok := findOrderItem(c, db)
+		if !ok {
+			return
+		}
+
+		var req OrderItemRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		req.apply(&item)
+
+		if err := db.Omit("Order").Save(&item).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order item", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order item updated successfully", "data": item})
+	}
+}
+
+// UpdateOrderItemStatus moves a garment through processing. Pickup is only
+// recorded through the pickup checklist.
+func UpdateOrderItemStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		item, ok := findOrderItem(c, db)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Status string `json:"status" binding:"required,oneof=received processing ready missing"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if item.Status == "picked_up" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Item has already been picked up"})
+			return
+		}
+
+		item.Status = req.Status
+		if err := db.Model(&item).Update("status", item.Status).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update item status", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Item status updated successfully", "data": item})
+	}
+}
+
+func DeleteOrderItem(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		item, ok := findOrderItem(c, db)
+		if !ok {
+			return
+		}
+
+		if item.Status != "received" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only items that have not been processed can be removed"})
+			return
+		}
+
+		var photos []models.Attachment
+		db.Where("owner_type = ? AND owner_id = ?", "order_item", item.ID).Find(&photos)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("owner_type = ? AND owner_id = ?", "order_item", item.ID).Delete(&models.Attachment{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.OrderItem{}, item.ID).Error
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete order item", "details": err.Error()})
+			return
+		}
+
+		for _, photo := range photos {
+			removeStoredFiles(store, photo)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order item deleted successfully"})
+	}
+}
+
+func UploadOrderItemPhoto(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		item, ok := findOrderItem(c, db)
+		if !ok {
+			return
+		}
+
+		attachment, ok := saveUpload(c, db, store, "order_item", item.ID)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Photo uploaded successfully", "data": attachment})
+	}
+}
+
+func GetOrderItemPhoto(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		item, ok := findOrderItem(c, db)
+		if !ok {
+			return
+		}
+
+		var attachment models.Attachment
+		if err := db.Where("owner_type = ? AND owner_id = ?", "order_item", item.ID).
+			First(&attachment, c.Param("photo_id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+			return
+		}
+
+		serveAttachment(c, store, attachment)
+	}
+}
+
+func DeleteOrderItemPhoto(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		item, ok := findOrderItem(c, db)
+		if !ok {
+			return
+		}
+
+		var attachment models.Attachment
+		if err := db.Where("owner_type = ? AND owner_id = ?", "order_item", item.ID).
+			First(&attachment, c.Param("photo_id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+			return
+		}
+
+		if err := deleteAttachment(db, store, attachment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Photo deleted successfully"})
+	}
+}
+
+// GetPickupChecklist lists the order's items for the handover and flags the
+// ones that are missing or not ready yet.
+func GetPickupChecklist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findOrderForItems(c, db)
+		if !ok {
+			return
+		}
+
+		var items []models.OrderItem
+		if err := db.Where("order_id = ?", order.ID).Order("id asc").Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order items", "details": err.Error()})
+			return
+		}
+
+		notReady := []models.OrderItem{}
+		for _, item := range items {
+			if item.Status != "ready" && item.Status != "picked_up" {
+				notReady = append(notReady, item)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"order_id":     order.ID,
+			"picked_up_at": order.PickedUpAt,
+			"items":        items,
+			"not_ready":    notReady,
+			"ready":        len(notReady) == 0,
+		})
+	}
+}
+
+// ConfirmPickup hands the order back. Staff tick off every item they are
+// handing over; items that cannot be found are marked missing and the pickup
+// is refused until they are resolved.
+func ConfirmPickup(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findOrderForItems(c, db)
+		if !ok {
+			return
+		}
+
+		if order.PickedUpAt != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order has already been picked up"})
+			return
+		}
+		if order.DisposedAt != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order was closed as abandoned"})
+			return
+		}
+		if order.Status != "done" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only finished orders can be picked up"})
+			return
+		}
+
+		var req struct {
+			ItemIDs []uint `json:"item_ids"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		var items []models.OrderItem
+		if err := db.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order items", "details": err.Error()})
+			return
+		}
+
+		confirmed := map[uint]bool{}
+		for _, id := range req.ItemIDs {
+			confirmed[id] = true
+		}
+
+		var missing, notReady []models.OrderItem
+		for _, item := range items {
+			switch {
+			case !confirmed[item.ID]:
+				missing = append(missing, item)
+			case item.Status != "ready":
+				notReady = append(notReady, item)
+			}
+		}
+
+		if len(missing) > 0 {
+			ids := make([]uint, 0, len(missing))
+			for i := range missing {
+				ids = append(ids, missing[i].ID)
+				missing[i].Status = "missing"
+			}
+			if err := db.Model(&models.OrderItem{}).Where("id IN ?", ids).Update("status", "missing").Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to flag missing items", "details": err.Error()})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Some items were not found, the order cannot be handed over", "missing": missing})
+			return
+		}
+		if len(notReady) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Some items are not ready yet", "not_ready": notReady})
+			return
+		}
+
+		userID := currentUserID(c)
+		now := time.Now()
+		order.PickedUpAt = &now
+		order.PickedUpBy = &userID
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.OrderItem{}).Where("order_id = ?", order.ID).Update("status", "picked_up").Error; err != nil {
+				return err
+			}
+			return tx.Model(&order).Updates(map[string]interface{}{"picked_up_at": now, "picked_up_by": userID}).Error
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm pickup", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order picked up", "data": order, "items": len(items)})
+	}
+}
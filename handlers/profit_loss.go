@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"laundre/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetProfitAndLoss reports revenue, cost of goods consumed and operating
+// expenses for a period, all read from the ledger. Purchase order deliveries
+// are booked to inventory, so they only show up as cost once the stock is
+// consumed, wasted or found short in a stock take or transfer.
+func GetProfitAndLoss(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		startDate := c.DefaultQuery("start_date", now.Format("2006-01")+"-01")
+		endDate := c.DefaultQuery("end_date", now.Format("2006-01-02"))
+		if !validDate(startDate) || !validDate(endDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must use the YYYY-MM-DD format"})
+			return
+		}
+		branchID := c.Query("branch_id")
+
+		var lines []struct {
+			Code      string
+			Name      string
+			Type      string
+			Debit     float64
+			Credit    float64
+			Losses    float64
+			Purchases float64
+		}
+		query := db.Table("journal_lines").
+			Select("accounts.code, accounts.name, accounts.type, "+
+				"SUM(journal_lines.debit) as debit, SUM(journal_lines.credit) as credit, "+
+				"SUM(CASE WHEN journal_entries.source_type IN ? THEN journal_lines.debit - journal_lines.credit ELSE 0 END) as losses, "+
+				"SUM(CASE WHEN journal_entries.source_type IN ? THEN journal_lines.debit - journal_lines.credit ELSE 0 END) as purchases",
+				[]string{models.JournalStockLoss, models.JournalTransferDiscrepancy}, []string{models.JournalExpense, models.JournalStockPurchase}).
+			Joins("JOIN journal_entries ON journal_entries.id = journal_lines.journal_entry_id").
+			Joins("JOIN accounts ON accounts.id = journal_lines.account_id").
+			Where("(accounts.type IN ? OR accounts.code = ?)", []string{"revenue", "expense"}, models.AccountInventory).
+			Where("DATE(journal_entries.date) >= ? AND DATE(journal_entries.date) <= ?", startDate, endDate)
+		if branchID != "" {
+			query = query.Where("journal_entries.branch_id = ?", branchID)
+		}
+		if err := query.Group("accounts.code, accounts.name, accounts.type").Order("accounts.code asc").
+			Scan(&lines).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate profit and loss", "details": err.Error()})
+			return
+		}
+
+		var revenue, cogs, losses, expenses, purchases float64
+		revenueLines := []gin.H{}
+		expenseLines := []gin.H{}
+		for _, line := range lines {
+			switch {
+			case line.Code == models.AccountInventory:
+				purchases += line.Purchases
+			case line.Type == "revenue":
+				amount := roundAmount(line.Credit - line.Debit)
+				revenue += amount
+				revenueLines = append(revenueLines, gin.H{"code": line.Code, "name": line.Name, "amount": amount})
+			case line.Code == models.AccountCostOfGoods:
+				losses += line.Losses
+				cogs += line.Debit - line.Credit - line.Losses
+			default:
+				amount := roundAmount(line.Debit - line.Credit)
+				if amount == 0 {
+					continue
+				}
+				expenses += amount
+				expenseLines = append(expenseLines, gin.H{"code": line.Code, "name": line.Name, "amount": amount})
+			}
+		}
+
+		revenue = roundAmount(revenue)
+		cogs = roundAmount(cogs)
+		losses = roundAmount(losses)
+		grossProfit := roundAmount(revenue - cogs)
+
+		c.JSON(http.StatusOK, gin.H{
+			"start_date":               startDate,
+			"end_date":                 endDate,
+			"revenue":                  revenueLines,
+			"total_revenue":            revenue,
+			"cost_of_goods_consumed":   cogs,
+			"gross_profit":             grossProfit,
+			"stock_losses":             losses,
+			"operating_expenses":       expenseLines,
+			"total_operating_expenses": roundAmount(expenses),
+			"inventory_purchases":      roundAmount(purchases),
+			"net_profit":               roundAmount(grossProfit - losses - expenses),
+		})
+	}
+}
@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"laundre/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PurchaseOrderItemRequest is priced per Unit, which defaults to the item's
+// base unit.
+type PurchaseOrderItemRequest struct {
+	InventoryID uint    `json:"inventory_id" binding:"required"`
+	Unit        string  `json:"unit" binding:"max=20"`
+	Quantity    float64 `json:"quantity" binding:"required,gt=0"`
+	UnitPrice   float64 `json:"unit_price" binding:"min=0"`
+}
+
+type PurchaseOrderRequest struct {
+	BranchID     uint                       `json:"branch_id" binding:"required"`
+	SupplierID   uint                       `json:"supplier_id" binding:"required"`
+	CategoryID   *uint                      `json:"category_id"`
+	Notes        string                     `json:"notes"`
+	ExpectedDate string                     `json:"expected_date"`
+	Items        []PurchaseOrderItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type ReceivePurchaseOrderRequest struct {
+	Items []struct {
+		ItemID   uint    `json:"item_id" binding:"required"`
+		Quantity float64 `json:"quantity" binding:"required,gt=0"`
+	} `json:"items" binding:"required,min=1,dive"`
+	Notes string `json:"notes"`
+}
+
+var errPurchaseOrderReceive = errors.New("invalid receipt")
+
+// apply validates the request and copies it onto the order, replacing its
+// line items.
+func (req PurchaseOrderRequest) apply(db *gorm.DB, order *models.PurchaseOrder) (string, bool) {
+	var supplier models.Supplier
+	if err := db.First(&supplier, req.SupplierID).Error; err != nil {
+		return "Supplier not found", false
+	}
+	if !supplier.Active {
+		return "Supplier is inactive", false
+	}
+	if !validExpenseCategory(db, req.CategoryID) {
+		return "Invalid expense category", false
+	}
+
+	var expectedDate *time.Time
+	if req.ExpectedDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", req.ExpectedDate, time.Local)
+		if err != nil {
+			return "expected_date must use the YYYY-MM-DD format", false
+		}
+		expectedDate = &parsed
+	}
+
+	items := make([]models.PurchaseOrderItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		var inventory models.Inventory
+		if err := db.First(&inventory, item.InventoryID).Error; err != nil {
+			return fmt.Sprintf("Inventory %d not found", item.InventoryID), false
+		}
+		if inventory.BranchID != req.BranchID {
+			return fmt.Sprintf("Inventory %d belongs to another branch", item.InventoryID), false
+		}
+		factor, err := models.UnitFactor(db, inventory, item.Unit)
+		if err != nil {
+			return fmt.Sprintf("Unit %q is not defined for %s", item.Unit, inventory.Name), false
+		}
+		unit := item.Unit
+		if unit == "" {
+			unit = inventory.BaseUnit
+		}
+		items = append(items, models.PurchaseOrderItem{
+			InventoryID: item.InventoryID,
+			Unit:        unit,
+			Factor:      factor,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+		})
+	}
+
+	order.BranchID = req.BranchID
+	order.SupplierID = req.SupplierID
+	order.CategoryID = req.CategoryID
+	order.Notes = req.Notes
+	order.ExpectedDate = expectedDate
+	order.Items = items
+
+	return "", true
+}
+
+func findPurchaseOrder(c *gin.Context, db *gorm.DB) (models.PurchaseOrder, bool) {
+	var order models.PurchaseOrder
+	if err := db.First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return order, false
+	}
+
+	if !canAccessBranch(c, order.BranchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+		return order, false
+	}
+
+	return order, true
+}
+
+func CreatePurchaseOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PurchaseOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if !canAccessBranch(c, req.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		order := models.PurchaseOrder{Status: "draft", CreatedBy: currentUserID(c)}
+		if message, ok := req.apply(db, &order); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
+
+		if err := db.Create(&order).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase order", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Purchase order created successfully", "data": order, "total": order.Total()})
+	}
+}
+
+func GetPurchaseOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.PurchaseOrder{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+		if supplierID := c.Query("supplier_id"); supplierID != "" {
+			query = query.Where("supplier_id = ?", supplierID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var orders []models.PurchaseOrder
+		if err := query.Preload("Branch").Preload("Supplier").Preload("Items").
+			Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve purchase orders", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": orders,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+func GetPurchaseOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findPurchaseOrder(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Preload("Branch").Preload("Supplier").Preload("Category").Preload("Items.Inventory").
+			Preload("Receipts.Items").Preload("Receipts.Expense").First(&order, order.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve purchase order", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": order, "total": order.Total()})
+	}
+}
+
+func UpdatePurchaseOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findPurchaseOrder(c, db)
+		if !ok {
+			return
+		}
+
+		if order.Status != "draft" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft purchase orders can be edited"})
+			return
+		}
+
+		var req PurchaseOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if !canAccessBranch(c, req.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		if message, ok := req.apply(db, &order); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("purchase_order_id = ?", order.ID).Delete(&models.PurchaseOrderItem{}).Error; err != nil {
+				return err
+			}
+			return tx.Save(&order).Error
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Purchase order updated successfully", "data": order, "total": order.Total()})
+	}
+}
+
+func DeletePurchaseOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findPurchaseOrder(c, db)
+		if !ok {
+			return
+		}
+
+		if order.Status != "draft" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft purchase orders can be deleted, cancel it instead"})
+			return
+		}
+
+		if err := db.Select("Items").Delete(&order).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete purchase order", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Purchase order deleted successfully"})
+	}
+}
+
+// SubmitPurchaseOrder marks a draft as sent to the supplier, after which it
+// can be received against.
+func SubmitPurchaseOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findPurchaseOrder(c, db)
+		if !ok {
+			return
+		}
+
+		if order.Status != "draft" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft purchase orders can be submitted"})
+			return
+		}
+
+		now := time.Now()
+		order.Status = "ordered"
+		order.OrderedAt = &now
+
+		if err := db.Save(&order).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit purchase order", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Purchase order submitted", "data": order})
+	}
+}
+
+// CancelPurchaseOrder closes an order that will not be (fully) delivered.
+// Deliveries already received stay booked.
+func CancelPurchaseOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findPurchaseOrder(c, db)
+		if !ok {
+			return
+		}
+
+		if order.Status == "received" || order.Status == "cancelled" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Purchase order is already closed"})
+			return
+		}
+
+		if err := db.Model(&order).Update("status", "cancelled").Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel purchase order", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Purchase order cancelled", "data": order})
+	}
+}
+
+// ReceivePurchaseOrder books a (partial) delivery: each received line is
+// added to stock as a purchase movement and the delivered value is recorded
+// as an expense, subject to the usual approval threshold.
+func ReceivePurchaseOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, ok := findPurchaseOrder(c, db)
+		if !ok {
+			return
+		}
+
+		var req ReceivePurchaseOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		userID := currentUserID(c)
+		var receipt models.PurchaseReceipt
+		var expense models.Expense
+		message := ""
+
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Supplier").Preload("Items").
+				First(&order, order.ID).Error; err != nil {
+				return err
+			}
+			if order.Status != "ordered" && order.Status != "partially_received" {
+				message = "Only submitted purchase orders can be received"
+				return errPurchaseOrderReceive
+			}
+
+			items := map[uint]*models.PurchaseOrderItem{}
+			for i := range order.Items {
+				items[order.Items[i].ID] = &order.Items[i]
+			}
+
+			receipt = models.PurchaseReceipt{PurchaseOrderID: order.ID, Notes: req.Notes, ReceivedBy: userID}
+			var amount float64
+			for _, line := range req.Items {
+				item, found := items[line.ItemID]
+				if !found {
+					message = fmt.Sprintf("Item %d is not part of this purchase order", line.ItemID)
+					return errPurchaseOrderReceive
+				}
+				if line.Quantity > item.Outstanding() {
+					message = fmt.Sprintf("Item %d only has %.3f outstanding", line.ItemID, item.Outstanding())
+					return errPurchaseOrderReceive
+				}
+
+				item.ReceivedQuantity = math.Round((item.ReceivedQuantity+line.Quantity)*1000) / 1000
+				amount += line.Quantity * item.UnitPrice
+				receipt.Items = append(receipt.Items, models.PurchaseReceiptItem{
+					PurchaseOrderItemID: item.ID,
+					Quantity:            line.Quantity,
+					UnitPrice:           item.UnitPrice,
+				})
+			}
+
+			if err := tx.Create(&receipt).Error; err != nil {
+				return err
+			}
+
+			for _, line := range receipt.Items {
+				item := items[line.PurchaseOrderItemID]
+				if err := tx.Model(item).Update("received_quantity", item.ReceivedQuantity).Error; err != nil {
+					return err
+				}
+
+				movement := models.StockMovement{
+					InventoryID:   item.InventoryID,
+					Type:          "purchase",
+					Quantity:      line.Quantity * item.Factor,
+					UnitCost:      item.UnitPrice / item.Factor,
+					Reason:        fmt.Sprintf("Purchase order #%d", order.ID),
+					UserID:        &userID,
+					ReferenceType: "purchase_receipt",
+					ReferenceID:   &receipt.ID,
+				}
+				if err := models.RecordStockMovement(tx, &movement); err != nil {
+					return err
+				}
+			}
+
+			status := "received"
+			for _, item := range order.Items {
+				if item.Outstanding() > 0 {
+					status = "partially_received"
+					break
+				}
+			}
+			if err := tx.Model(&order).Update("status", status).Error; err != nil {
+				return err
+			}
+			order.Status = status
+
+			amount = roundAmount(amount)
+			if amount == 0 {
+				return nil
+			}
+
+			expense = models.Expense{
+				BranchID:    order.BranchID,
+				CategoryID:  order.CategoryID,
+				Description: fmt.Sprintf("Purchase order #%d from %s", order.ID, order.Supplier.Name),
+				Amount:      amount,
+				SubmittedBy: &userID,
+			}
+			expenseStatus, err := approvalStatus(c, tx, order.BranchID, amount)
+			if err != nil {
+				return err
+			}
+			expense.Status = expenseStatus
+			if expenseStatus == "approved" && isAdmin(c) {
+				now := time.Now()
+				expense.ApprovedBy = &userID
+				expense.ApprovedAt = &now
+			}
+
+			if err := tx.Create(&expense).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&receipt).Update("expense_id", expense.ID).Error; err != nil {
+				return err
+			}
+			receipt.ExpenseID = &expense.ID
+			if expense.Status != "approved" {
+				return nil
+			}
+			return models.PostExpense(tx, expense)
+		})
+
+		if err != nil {
+			if err == errPurchaseOrderReceive {
+				c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to receive purchase order", "details": err.Error()})
+			}
+			return
+		}
+
+		response := gin.H{"message": "Delivery received successfully", "data": receipt, "status": order.Status}
+		if expense.ID != 0 {
+			response["expense"] = expense
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
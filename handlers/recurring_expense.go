@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"laundre/models"
+	"laundre/scheduler"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RecurringExpenseRequest struct {
+	BranchID    uint    `json:"branch_id" binding:"required"`
+	CategoryID  *uint   `json:"category_id"`
+	Description string  `json:"description" binding:"required"`
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	Frequency   string  `json:"frequency" binding:"required,oneof=monthly weekly"`
+	DayOfMonth  int     `json:"day_of_month" binding:"omitempty,min=1,max=31"`
+	DayOfWeek   *int    `json:"day_of_week" binding:"omitempty,min=0,max=6"`
+	StartDate   string  `json:"start_date" binding:"required"`
+	EndDate     string  `json:"end_date"`
+	Active      *bool   `json:"active"`
+}
+
+// apply validates the request and copies it onto the template.
+func (req RecurringExpenseRequest) apply(db *gorm.DB, template *models.RecurringExpense) (string, bool) {
+	if req.Frequency == "monthly" && req.DayOfMonth == 0 {
+		return "day_of_month is required for monthly expenses", false
+	}
+
+	startDate, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	if err != nil {
+		return "start_date must use the YYYY-MM-DD format", false
+	}
+
+	var endDate *time.Time
+	if req.EndDate != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+		if err != nil || parsed.Before(startDate) {
+			return "end_date must be a YYYY-MM-DD date on or after start_date", false
+		}
+		endDate = &parsed
+	}
+
+	var branch models.Branch
+	if err := db.First(&branch, req.BranchID).Error; err != nil {
+		return "Branch not found", false
+	}
+	if !validExpenseCategory(db, req.CategoryID) {
+		return "Invalid expense category", false
+	}
+
+	template.BranchID = req.BranchID
+	template.CategoryID = req.CategoryID
+	template.Description = req.Description
+	template.Amount = req.Amount
+	template.Frequency = req.Frequency
+	template.DayOfMonth = req.DayOfMonth
+	// Sunday is 0, so only a missing day falls back to Monday.
+	template.DayOfWeek = int(time.Monday)
+	if req.DayOfWeek != nil {
+		template.DayOfWeek = *req.DayOfWeek
+	}
+	template.StartDate = startDate
+	template.EndDate = endDate
+	if req.Active != nil {
+		template.Active = *req.Active
+	}
+
+	return "", true
+}
+
+func CreateRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RecurringExpenseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		template := models.RecurringExpense{Active: true, CreatedBy: currentUserID(c)}
+		if message, ok := req.apply(db, &template); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
+
+		if err := db.Create(&template).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recurring expense", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Recurring expense created successfully", "data": template})
+	}
+}
+
+func GetRecurringExpenses(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var templates []models.RecurringExpense
+
+		query := db.Preload("Branch").Preload("Category")
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		}
+
+		if err := query.Order("id asc").Find(&templates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recurring expenses", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": templates})
+	}
+}
+
+func GetRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var template models.RecurringExpense
+		if err := db.Preload("Branch").Preload("Category").First(&template, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recurring expense not found"})
+			return
+		}
+
+		var expenses []models.Expense
+		if err := db.Where("recurring_expense_id = ?", template.ID).Order("occurrence_date desc").
+			Find(&expenses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve generated expenses", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": template, "expenses": expenses})
+	}
+}
+
+func UpdateRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var template models.RecurringExpense
+		if err := db.First(&template, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recurring expense not found"})
+			return
+		}
+
+		var req RecurringExpenseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if message, ok := req.apply(db, &template); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
+
+		if err := db.Save(&template).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recurring expense", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recurring expense updated successfully", "data": template})
+	}
+}
+
+func DeleteRecurringExpense(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Delete(&models.RecurringExpense{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recurring expense", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recurring expense not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recurring expense deleted successfully"})
+	}
+}
+
+func RunRecurringExpenses(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		created, err := scheduler.RunRecurringExpenses(db, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run recurring expenses", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recurring expenses processed", "created": created})
+	}
+}
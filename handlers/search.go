@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"laundre/models"
+	"laundre/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Only candidates scoring at least minSearchScore are returned, and at most
+// searchCandidates rows of each kind are scored, closest matches first.
+const (
+	minSearchScore   = 0.5
+	searchCandidates = 300
+)
+
+type searchResult struct {
+	Type    string      `json:"type"`
+	ID      uint        `json:"id"`
+	Title   string      `json:"title"`
+	Detail  string      `json:"detail"`
+	Matched string      `json:"matched"`
+	Score   float64     `json:"score"`
+	Data    interface{} `json:"data"`
+}
+
+// textScore rates how well text matches the query: 1 for the same words, less
+// for a prefix or a substring, and a partial score when every query word is
+// within a typo or two of a word in the text.
+func textScore(query, text string) float64 {
+	query, text = utils.NormalizeName(query), utils.NormalizeName(text)
+	switch {
+	case query == "" || text == "":
+		return 0
+	case query == text:
+		return 1
+	case strings.HasPrefix(text, query):
+		return 0.9
+	case strings.Contains(text, query):
+		return 0.8
+	}
+
+	words := strings.Fields(text)
+	total := 0.0
+	for _, term := range strings.Fields(query) {
+		best := 0.0
+		for _, word := range words {
+			similarity := utils.Similarity(term, word)
+			// A word still being typed is compared with the start of the
+			// longer word.
+			if len(word) > len(term) && len(term) >= 3 {
+				similarity = max(similarity, utils.Similarity(term, word[:len(term)])-0.1)
+			}
+			best = max(best, similarity)
+		}
+		if best < 0.6 {
+			return 0
+		}
+		total += best
+	}
+	return 0.75 * total / float64(len(strings.Fields(query)))
+}
+
+// codeScore rates a tracking code or invoice number, allowing a single
+// mistyped character.
+func codeScore(query, code string) float64 {
+	query, code = strings.ToUpper(strings.TrimSpace(query)), strings.ToUpper(code)
+	switch {
+	case query == "" || code == "":
+		return 0
+	case query == code:
+		return 1
+	case strings.HasSuffix(code, query) && len(query) >= 4:
+		return 0.9
+	case strings.Contains(code, query) && len(query) >= 4:
+		return 0.8
+	}
+	if len(query) >= 6 {
+		distance := min(utils.Levenshtein(query, code), utils.Levenshtein(query, code[max(0, len(code)-len(query)):]))
+		if distance == 1 {
+			return 0.7
+		}
+	}
+	return 0
+}
+
+// phoneDigits strips a phone query down to the subscriber number, so "0812",
+// "62812" and "+62 812" all search for "812".
+func phoneDigits(query string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, query)
+	switch {
+	case strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	case strings.HasPrefix(digits, "62") && len(digits) > 5:
+		digits = digits[2:]
+	}
+	return digits
+}
+
+// codeHalves lets a code containing one typo still be found: whichever half
+// of it is typed correctly matches in SQL.
+func codeHalves(query string) []string {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	if len(query) < 6 {
+		return []string{query}
+	}
+	return []string{query[:len(query)/2], query[len(query)/2:]}
+}
+
+// likeEscaper makes wildcards typed into the search box match literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// nameCandidates matches rows whose column contains the query, or for typo
+// tolerance a word starting with the same two letters as a query word.
+func nameCandidates(query *gorm.DB, column, search string) *gorm.DB {
+	conditions := []string{column + " LIKE ?"}
+	args := []interface{}{"%" + likeEscaper.Replace(search) + "%"}
+	for _, term := range strings.Fields(utils.NormalizeName(search)) {
+		if len([]rune(term)) < 3 {
+			continue
+		}
+		prefix := likeEscaper.Replace(string([]rune(term)[:2]))
+		conditions = append(conditions, column+" LIKE ?", column+" LIKE ?")
+		args = append(args, prefix+"%", "% "+prefix+"%")
+	}
+	return query.Or(strings.Join(conditions, " OR "), args...)
+}
+
+// matchRank ranks a row 0 when the column equals the search, 1 when it
+// starts with it, 2 when it contains it and 3 otherwise.
+func matchRank(column, search string) clause.Expr {
+	pattern := likeEscaper.Replace(search)
+	return clause.Expr{
+		SQL:  "CASE WHEN " + column + " = ? THEN 0 WHEN " + column + " LIKE ? THEN 1 WHEN " + column + " LIKE ? THEN 2 ELSE 3 END",
+		Vars: []interface{}{search, pattern + "%", "%" + pattern + "%"},
+	}
+}
+
+// bestMatchFirst orders candidates by their best rank, so exact and
+// substring matches are never pushed past the candidate limit by the looser
+// typo candidates.
+func bestMatchFirst(query *gorm.DB, ranks ...clause.Expr) *gorm.DB {
+	expression := ranks[0]
+	if len(ranks) > 1 {
+		parts := make([]string, len(ranks))
+		var vars []interface{}
+		for i, rank := range ranks {
+			parts[i] = rank.SQL
+			vars = append(vars, rank.Vars...)
+		}
+		expression = clause.Expr{SQL: "LEAST(" + strings.Join(parts, ", ") + ")", Vars: vars}
+	}
+	return query.Order(clause.OrderBy{Expression: expression})
+}
+
+// Search looks a query up across customers (name, phone and address), orders
+// (tracking code or number), transactions (number) and invoices (number or
+// company), ranks the hits in one list and tolerates small typos. Staff only
+// see orders and transactions of their own branch and customers who have
+// ordered there or not at all; invoices are left to admins.
+func Search(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := strings.TrimSpace(c.Query("q"))
+		if len([]rune(q)) < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q must be at least 2 characters"})
+			return
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+
+		var branchID *uint
+		if value := c.Query("branch_id"); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch_id"})
+				return
+			}
+			if !canAccessBranch(c, uint(id)) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+				return
+			}
+			scoped := uint(id)
+			branchID = &scoped
+		} else if !isAdmin(c) {
+			// Staff without a branch match nothing.
+			scoped := uint(0)
+			if id := currentBranchID(c); id != nil {
+				scoped = *id
+			}
+			branchID = &scoped
+		}
+
+		number, numberErr := strconv.ParseUint(strings.TrimPrefix(q, "#"), 10, 64)
+		digits := phoneDigits(q)
+		results := []searchResult{}
+
+		// Customers
+		customerConditions := nameCandidates(nameCandidates(db, "name", q), "address", q)
+		customerRanks := []clause.Expr{matchRank("name", q), matchRank("address", q)}
+		if len(digits) >= 4 {
+			customerConditions = customerConditions.Or("phone LIKE ?", "%"+digits+"%")
+			customerRanks = append(customerRanks, clause.Expr{
+				SQL:  "CASE WHEN phone LIKE ? THEN 0 WHEN phone LIKE ? THEN 1 ELSE 3 END",
+				Vars: []interface{}{"%" + digits, "%" + digits + "%"},
+			})
+		}
+		customerQuery := db.Model(&models.Customer{}).Where(customerConditions)
+		if branchID != nil {
+			customerQuery = customerQuery.Where(
+				"EXISTS (SELECT 1 FROM orders WHERE orders.customer_id = customers.id AND orders.branch_id = ?)"+
+					" OR NOT EXISTS (SELECT 1 FROM orders WHERE orders.customer_id = customers.id)", *branchID)
+		}
+		var customers []models.Customer
+		if err := bestMatchFirst(customerQuery, customerRanks...).Limit(searchCandidates).Find(&customers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search customers", "details": err.Error()})
+			return
+		}
+		for _, customer := range customers {
+			score, matched := textScore(q, customer.Name), "name"
+			if len(digits) >= 4 && strings.Contains(customer.Phone, digits) {
+				phoneScore := 0.85
+				if strings.HasSuffix(customer.Phone, digits) && len(digits) >= 9 {
+					phoneScore = 1
+				}
+				if phoneScore > score {
+					score, matched = phoneScore, "phone"
+				}
+			}
+			if addressScore := 0.8 * textScore(q, customer.Address); addressScore > score {
+				score, matched = addressScore, "address"
+			}
+			if score < minSearchScore {
+				continue
+			}
+			results = append(results, searchResult{
+				Type:    "customer",
+				ID:      customer.ID,
+				Title:   customer.Name,
+				Detail:  customer.Phone,
+				Matched: matched,
+				Score:   score,
+				Data:    customer,
+			})
+		}
+
+		// Orders
+		orderQuery := db.Preload("Customer")
+		upperQ := strings.ToUpper(q)
+		conditions := db
+		for _, half := range codeHalves(q) {
+			conditions = conditions.Or("tracking_code LIKE ?", "%"+likeEscaper.Replace(half)+"%")
+		}
+		orderRanks := []clause.Expr{matchRank("tracking_code", upperQ)}
+		if numberErr == nil {
+			conditions = conditions.Or("id = ?", number)
+			orderRanks = append(orderRanks, clause.Expr{SQL: "CASE WHEN id = ? THEN 0 ELSE 3 END", Vars: []interface{}{number}})
+		}
+		orderQuery = orderQuery.Where(conditions)
+		if branchID != nil {
+			orderQuery = orderQuery.Where("branch_id = ?", *branchID)
+		}
+		var orders []models.Order
+		if err := bestMatchFirst(orderQuery, orderRanks...).Limit(searchCandidates).Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search orders", "details": err.Error()})
+			return
+		}
+		for _, order := range orders {
+			score, matched := 0.0, "tracking_code"
+			if order.TrackingCode != nil {
+				score = codeScore(q, *order.TrackingCode)
+			}
+			if numberErr == nil && uint64(order.ID) == number && score < 0.95 {
+				score, matched = 0.95, "id"
+			}
+			if score < minSearchScore {
+				continue
+			}
+			code := ""
+			if order.TrackingCode != nil {
+				code = *order.TrackingCode
+			}
+			results = append(results, searchResult{
+				Type:    "order",
+				ID:      order.ID,
+				Title:   code,
+				Detail:  order.Customer.Name + " - " + order.Status,
+				Matched: matched,
+				Score:   score,
+				Data:    order,
+			})
+		}
+
+		// Transactions
+		if numberErr == nil {
+			transactionQuery := db.Preload("Order.Customer").Where("id = ?", number)
+			if branchID != nil {
+				transactionQuery = transactionQuery.Where("branch_id = ?", *branchID)
+			}
+			var transactions []models.Transaction
+			if err := transactionQuery.Find(&transactions).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search transactions", "details": err.Error()})
+				return
+			}
+			for _, transaction := range transactions {
+				results = append(results, searchResult{
+					Type:    "transaction",
+					ID:      transaction.ID,
+					Title:   "#" + strconv.Itoa(int(transaction.ID)),
+					Detail:  transaction.Order.Customer.Name + " - " + transaction.PaymentStatus,
+					Matched: "id",
+					Score:   0.9,
+					Data:    transaction,
+				})
+			}
+		}
+
+		// Invoices
+		if isAdmin(c) {
+			invoiceQuery := db.Preload("CorporateAccount").Joins("JOIN corporate_accounts ON corporate_accounts.id = invoices.corporate_account_id")
+			conditions = nameCandidates(db, "corporate_accounts.company_name", q)
+			for _, half := range codeHalves(q) {
+				conditions = conditions.Or("invoices.number LIKE ?", "%"+likeEscaper.Replace(half)+"%")
+			}
+			invoiceQuery = invoiceQuery.Where(conditions)
+			if branchID != nil {
+				invoiceQuery = invoiceQuery.Where("EXISTS (SELECT 1 FROM transactions WHERE transactions.invoice_id = invoices.id AND transactions.branch_id = ?)", *branchID)
+			}
+			var invoices []models.Invoice
+			invoiceQuery = bestMatchFirst(invoiceQuery, matchRank("invoices.number", upperQ), matchRank("corporate_accounts.company_name", q))
+			if err := invoiceQuery.Limit(searchCandidates).Find(&invoices).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search invoices", "details": err.Error()})
+				return
+			}
+			for _, invoice := range invoices {
+				score, matched := codeScore(q, invoice.Number), "number"
+				if companyScore := 0.8 * textScore(q, invoice.CorporateAccount.CompanyName); companyScore > score {
+					score, matched = companyScore, "company_name"
+				}
+				if score < minSearchScore {
+					continue
+				}
+				results = append(results, searchResult{
+					Type:    "invoice",
+					ID:      invoice.ID,
+					Title:   invoice.Number,
+					Detail:  invoice.CorporateAccount.CompanyName + " - " + invoice.Status,
+					Matched: matched,
+					Score:   score,
+					Data:    invoice,
+				})
+			}
+		}
+
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+		if len(results) > limit {
+			results = results[:limit]
+		}
+		for i := range results {
+			results[i].Score = roundAmount(results[i].Score)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"query": q, "data": results})
+	}
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"laundre/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StockTakeCountRequest struct {
+	Items []struct {
+		InventoryID     uint     `json:"inventory_id" binding:"required"`
+		CountedQuantity *float64 `json:"counted_quantity" binding:"required,min=0"`
+	} `json:"items" binding:"required,min=1,dive"`
+}
+
+var errStockTake = errors.New("invalid stock take")
+
+func findStockTake(c *gin.Context, db *gorm.DB) (models.StockTake, bool) {
+	var stockTake models.StockTake
+	if err := db.First(&stockTake, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock take not found"})
+		return stockTake, false
+	}
+
+	if !canAccessBranch(c, stockTake.BranchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+		return stockTake, false
+	}
+
+	return stockTake, true
+}
+
+// CreateStockTake opens a counting session with every item of the branch,
+// snapshotting the quantities the ledger expects.
+func CreateStockTake(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			BranchID uint   `json:"branch_id" binding:"required"`
+			Notes    string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if !canAccessBranch(c, req.BranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+			return
+		}
+
+		var open int64
+		db.Model(&models.StockTake{}).Where("branch_id = ? AND status IN ?", req.BranchID, []string{"open", "submitted"}).Count(&open)
+		if open > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "The branch already has a stock take in progress"})
+			return
+		}
+
+		var inventories []models.Inventory
+		if err := db.Where("branch_id = ?", req.BranchID).Order("name asc").Find(&inventories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inventories", "details": err.Error()})
+			return
+		}
+		if len(inventories) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The branch has no inventory to count"})
+			return
+		}
+
+		stockTake := models.StockTake{
+			BranchID:  req.BranchID,
+			Status:    "open",
+			Notes:     req.Notes,
+			CreatedBy: currentUserID(c),
+		}
+		for _, inventory := range inventories {
+			balance, err := models.StockBalance(db, inventory.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate stock", "details": err.Error()})
+				return
+			}
+			stockTake.Items = append(stockTake.Items, models.StockTakeItem{
+				InventoryID:    inventory.ID,
+				SystemQuantity: balance,
+			})
+		}
+
+		if err := db.Create(&stockTake).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock take", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Stock take started", "data": stockTake})
+	}
+}
+
+func GetStockTakes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.StockTake{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("branch_id = ?", branchID)
+		} else if !isAdmin(c) {
+			query = query.Where("branch_id = ?", currentBranchID(c))
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var stockTakes []models.StockTake
+		if err := query.Preload("Branch").Order("created_at desc, id desc").
+			Offset(offset).Limit(limit).Find(&stockTakes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock takes", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": stockTakes,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+func GetStockTake(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stockTake, ok := findStockTake(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Preload("Branch").Preload("Items.Inventory").First(&stockTake, stockTake.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock take", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": stockTake})
+	}
+}
+
+// RecordStockTakeCounts stores counted quantities. Counts can be entered in
+// several passes and corrected until the session is submitted.
+func RecordStockTakeCounts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stockTake, ok := findStockTake(c, db)
+		if !ok {
+			return
+		}
+
+		if stockTake.Status != "open" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Counts can only be entered while the stock take is open"})
+			return
+		}
+
+		var req StockTakeCountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		message := ""
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, line := range req.Items {
+				var item models.StockTakeItem
+				if err := tx.Where("stock_take_id = ? AND inventory_id = ?", stockTake.ID, line.InventoryID).
+					First(&item).Error; err != nil {
+					message = fmt.Sprintf("Inventory %d is not part of this stock take", line.InventoryID)
+					return errStockTake
+				}
+
+				item.CountedQuantity = line.CountedQuantity
+				item.Variance = math.Round((*line.CountedQuantity-item.SystemQuantity)*1000) / 1000
+				if err := tx.Save(&item).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		if err != nil {
+			if err == errStockTake {
+				c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record counts", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Counts recorded successfully"})
+	}
+}
+
+func SubmitStockTake(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stockTake, ok := findStockTake(c, db)
+		if !ok {
+			return
+		}
+
+		if stockTake.Status != "open" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only open stock takes can be submitted"})
+			return
+		}
+
+		var uncounted int64
+		db.Model(&models.StockTakeItem{}).Where("stock_take_id = ? AND counted_quantity IS NULL", stockTake.ID).Count(&uncounted)
+		if uncounted > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d item(s) have not been counted yet", uncounted)})
+			return
+		}
+
+		userID := currentUserID(c)
+		now := time.Now()
+		stockTake.Status = "submitted"
+		stockTake.SubmittedBy = &userID
+		stockTake.SubmittedAt = &now
+
+		if err := db.Save(&stockTake).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit stock take", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock take submitted for approval", "data": stockTake})
+	}
+}
+
+// ApproveStockTake brings each counted item to its counted quantity. The
+// variance is recomputed against the stock balance at approval, stored on
+// the item and posted as an adjustment movement.
+func ApproveStockTake(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var stockTake models.StockTake
+		if err := db.First(&stockTake, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock take not found"})
+			return
+		}
+
+		userID := currentUserID(c)
+		message := ""
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").
+				First(&stockTake, stockTake.ID).Error; err != nil {
+				return err
+			}
+			if stockTake.Status != "submitted" {
+				message = "Only submitted stock takes can be approved"
+				return errStockTake
+			}
+
+			for i := range stockTake.Items {
+				item := &stockTake.Items[i]
+				if item.CountedQuantity == nil {
+					continue
+				}
+
+				// Stock may have moved since the session opened, so the
+				// variance is taken against the balance as it is now.
+				var inventory models.Inventory
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inventory, item.InventoryID).Error; err != nil {
+					return err
+				}
+				cost, err := models.InventoryUnitCost(tx, item.InventoryID)
+				if err != nil {
+					return err
+				}
+				item.SystemQuantity = inventory.Stock
+				item.Variance = math.Round((*item.CountedQuantity-inventory.Stock)*1000) / 1000
+				item.UnitCost = cost
+				if err := tx.Model(item).Updates(map[string]interface{}{
+					"system_quantity": item.SystemQuantity,
+					"variance":        item.Variance,
+					"unit_cost":       item.UnitCost,
+				}).Error; err != nil {
+					return err
+				}
+				if item.Variance == 0 {
+					continue
+				}
+
+				movement := models.StockMovement{
+					InventoryID:   item.InventoryID,
+					Type:          "adjustment",
+					Quantity:      item.Variance,
+					UnitCost:      item.UnitCost,
+					Reason:        fmt.Sprintf("Stock take #%d variance", stockTake.ID),
+					UserID:        &userID,
+					ReferenceType: "stock_take",
+					ReferenceID:   &stockTake.ID,
+				}
+				if err := models.RecordStockMovement(tx, &movement); err != nil {
+					return err
+				}
+			}
+
+			now := time.Now()
+			stockTake.Status = "approved"
+			stockTake.ApprovedBy = &userID
+			stockTake.ApprovedAt = &now
+			return tx.Omit("Items").Save(&stockTake).Error
+		})
+
+		if err != nil {
+			if err == errStockTake {
+				c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve stock take", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock take approved and adjustments posted", "data": stockTake})
+	}
+}
+
+// ReopenStockTake sends a submitted session back for recounting.
+func ReopenStockTake(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var stockTake models.StockTake
+		if err := db.First(&stockTake, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock take not found"})
+			return
+		}
+
+		if stockTake.Status != "submitted" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only submitted stock takes can be reopened"})
+			return
+		}
+
+		stockTake.Status = "open"
+		stockTake.SubmittedBy = nil
+		stockTake.SubmittedAt = nil
+
+		if err := db.Save(&stockTake).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reopen stock take", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock take reopened", "data": stockTake})
+	}
+}
+
+func CancelStockTake(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stockTake, ok := findStockTake(c, db)
+		if !ok {
+			return
+		}
+
+		if stockTake.Status == "approved" || stockTake.Status == "cancelled" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Stock take is already closed"})
+			return
+		}
+
+		if err := db.Model(&stockTake).Update("status", "cancelled").Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel stock take", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock take cancelled", "data": stockTake})
+	}
+}
+
+// GetStockTakeVarianceReport values each variance at cost. Approved sessions
+// use the cost fixed at approval; others use the current cost.
+func GetStockTakeVarianceReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stockTake, ok := findStockTake(c, db)
+		if !ok {
+			return
+		}
+
+		var items []models.StockTakeItem
+		if err := db.Preload("Inventory").Where("stock_take_id = ?", stockTake.ID).
+			Order("inventory_id asc").Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock take items", "details": err.Error()})
+			return
+		}
+
+		rows := make([]gin.H, 0, len(items))
+		var gain, loss float64
+		for _, item := range items {
+			if item.CountedQuantity == nil || item.Variance == 0 {
+				continue
+			}
+
+			cost := item.UnitCost
+			if stockTake.Status != "approved" {
+				var err error
+				if cost, err = models.InventoryUnitCost(db, item.InventoryID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate cost", "details": err.Error()})
+					return
+				}
+			}
+
+			value := roundAmount(item.Variance * cost)
+			if value > 0 {
+				gain += value
+			} else {
+				loss -= value
+			}
+
+			rows = append(rows, gin.H{
+				"inventory_id":     item.InventoryID,
+				"name":             item.Inventory.Name,
+				"system_quantity":  item.SystemQuantity,
+				"counted_quantity": item.CountedQuantity,
+				"variance":         item.Variance,
+				"unit_cost":        cost,
+				"variance_value":   value,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"stock_take_id": stockTake.ID,
+			"branch_id":     stockTake.BranchID,
+			"status":        stockTake.Status,
+			"items":         rows,
+			"total_gain":    roundAmount(gain),
+			"total_loss":    roundAmount(loss),
+			"net_variance":  roundAmount(gain - loss),
+		})
+	}
+}
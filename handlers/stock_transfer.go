@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"laundre/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StockTransferRequest struct {
+	FromBranchID uint   `json:"from_branch_id" binding:"required"`
+	ToBranchID   uint   `json:"to_branch_id" binding:"required,nefield=FromBranchID"`
+	Notes        string `json:"notes"`
+	Items        []struct {
+		InventoryID uint    `json:"inventory_id" binding:"required"`
+		Quantity    float64 `json:"quantity" binding:"required,gt=0"`
+	} `json:"items" binding:"required,min=1,dive"`
+}
+
+type ReceiveStockTransferRequest struct {
+	// Items lists the lines whose received quantity or destination differs
+	// from what was sent; lines left out are taken as received in full.
+	Items []struct {
+		ItemID                 uint     `json:"item_id" binding:"required"`
+		ReceivedQuantity       *float64 `json:"received_quantity" binding:"omitempty,min=0"`
+		DestinationInventoryID *uint    `json:"destination_inventory_id"`
+		Reason                 string   `json:"reason"`
+	} `json:"items" binding:"dive"`
+	// AcceptExcess must be set to book more of an item than was sent, for
+	// example when the sender packed extra by mistake.
+	AcceptExcess bool `json:"accept_excess"`
+}
+
+var errStockTransfer = errors.New("invalid stock transfer")
+
+// destinationInventory resolves the item a transfer line is booked into at
+// the receiving branch: the one chosen explicitly, an item with the same SKU
+// or name, or a newly created one.
+func destinationInventory(tx *gorm.DB, branchID uint, source models.Inventory, chosen *uint) (models.Inventory, string, error) {
+	var inventory models.Inventory
+	if chosen != nil {
+		if err := tx.First(&inventory, *chosen).Error; err != nil || inventory.BranchID != branchID {
+			return inventory, fmt.Sprintf("Inventory %d is not stocked at the receiving branch", *chosen), errStockTransfer
+		}
+		return inventory, "", nil
+	}
+
+	query := tx.Where("branch_id = ?", branchID)
+	if source.SKU != nil {
+		query = query.Where("sku = ? OR (sku IS NULL AND name = ?)", *source.SKU, source.Name)
+	} else {
+		query = query.Where("name = ?", source.Name)
+	}
+	err := query.Order("sku IS NULL").First(&inventory).Error
+	if err == nil {
+		return inventory, "", nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return inventory, "", err
+	}
+
+	inventory = models.Inventory{
+		BranchID: branchID,
+		Name:     source.Name,
+		SKU:      source.SKU,
+		Barcode:  source.Barcode,
+		BaseUnit: source.BaseUnit,
+	}
+	return inventory, "", tx.Create(&inventory).Error
+}
+
+func findStockTransfer(c *gin.Context, db *gorm.DB) (models.StockTransfer, bool) {
+	var transfer models.StockTransfer
+	if err := db.First(&transfer, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock transfer not found"})
+		return transfer, false
+	}
+
+	if !canAccessBranch(c, transfer.FromBranchID) && !canAccessBranch(c, transfer.ToBranchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
+		return transfer, false
+	}
+
+	return transfer, true
+}
+
+func CreateStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req StockTransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		if !canAccessBranch(c, req.FromBranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Transfers can only be created by the sending branch"})
+			return
+		}
+
+		var branch models.Branch
+		if err := db.First(&branch, req.ToBranchID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Receiving branch not found"})
+			return
+		}
+
+		transfer := models.StockTransfer{
+			FromBranchID: req.FromBranchID,
+			ToBranchID:   req.ToBranchID,
+			Status:       "draft",
+			Notes:        req.Notes,
+			CreatedBy:    currentUserID(c),
+		}
+		for _, item := range req.Items {
+			var inventory models.Inventory
+			if err := db.First(&inventory, item.InventoryID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Inventory %d not found", item.InventoryID)})
+				return
+			}
+			if inventory.BranchID != req.FromBranchID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Inventory %d belongs to another branch", item.InventoryID)})
+				return
+			}
+			transfer.Items = append(transfer.Items, models.StockTransferItem{
+				InventoryID: item.InventoryID,
+				Quantity:    item.Quantity,
+			})
+		}
+
+		if err := db.Create(&transfer).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock transfer", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Stock transfer created successfully", "data": transfer})
+	}
+}
+
+func GetStockTransfers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset := (page - 1) * limit
+
+		query := db.Model(&models.StockTransfer{})
+		if branchID := c.Query("branch_id"); branchID != "" {
+			query = query.Where("from_branch_id = ? OR to_branch_id = ?", branchID, branchID)
+		} else if !isAdmin(c) {
+			branchID := currentBranchID(c)
+			query = query.Where("from_branch_id = ? OR to_branch_id = ?", branchID, branchID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var transfers []models.StockTransfer
+		if err := query.Preload("FromBranch").Preload("ToBranch").Preload("Items").
+			Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&transfers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock transfers", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": transfers,
+			"meta": gin.H{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		})
+	}
+}
+
+func GetStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transfer, ok := findStockTransfer(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Preload("FromBranch").Preload("ToBranch").Preload("Items.Inventory").
+			Preload("Items.DestinationInventory").First(&transfer, transfer.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock transfer", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": transfer})
+	}
+}
+
+// SendStockTransfer takes the items out of the sending branch's stock and
+// puts the transfer in transit.
+func SendStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transfer, ok := findStockTransfer(c, db)
+		if !ok {
+			return
+		}
+
+		if !canAccessBranch(c, transfer.FromBranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the sending branch can send a transfer"})
+			return
+		}
+
+		userID := currentUserID(c)
+		message := ""
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items.Inventory").
+				First(&transfer, transfer.ID).Error; err != nil {
+				return err
+			}
+			if transfer.Status != "draft" {
+				message = "Only draft transfers can be sent"
+				return errStockTransfer
+			}
+
+			for i := range transfer.Items {
+				item := &transfer.Items[i]
+				movement := models.StockMovement{
+					InventoryID:   item.InventoryID,
+					Type:          "transfer",
+					Quantity:      -item.Quantity,
+					Reason:        fmt.Sprintf("Transfer #%d to branch %d", transfer.ID, transfer.ToBranchID),
+					UserID:        &userID,
+					ReferenceType: "stock_transfer",
+					ReferenceID:   &transfer.ID,
+				}
+				if err := models.RecordStockMovement(tx, &movement); err != nil {
+					if err == models.ErrInsufficientStock {
+						message = fmt.Sprintf("Insufficient stock of %s", item.Inventory.Name)
+						return errStockTransfer
+					}
+					return err
+				}
+
+				item.UnitCost = movement.UnitCost
+				if err := tx.Model(item).Update("unit_cost", item.UnitCost).Error; err != nil {
+					return err
+				}
+			}
+
+			now := time.Now()
+			transfer.Status = "in_transit"
+			transfer.SentBy = &userID
+			transfer.SentAt = &now
+			return tx.Omit("Items").Save(&transfer).Error
+		})
+
+		if err != nil {
+			if err == errStockTransfer {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send stock transfer", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock transfer sent", "data": transfer})
+	}
+}
+
+// ReceiveStockTransfer books what arrived into the receiving branch and
+// records any difference against the sent quantity as a discrepancy. Each
+// item may be listed once, and receiving more than was sent is refused
+// unless accept_excess is set.
+func ReceiveStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transfer, ok := findStockTransfer(c, db)
+		if !ok {
+			return
+		}
+
+		if !canAccessBranch(c, transfer.ToBranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the receiving branch can receive a transfer"})
+			return
+		}
+
+		var req ReceiveStockTransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		userID := currentUserID(c)
+		message := ""
+		var discrepancies []models.StockTransferItem
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items.Inventory").
+				First(&transfer, transfer.ID).Error; err != nil {
+				return err
+			}
+			if transfer.Status != "in_transit" {
+				message = "Only transfers in transit can be received"
+				return errStockTransfer
+			}
+
+			items := map[uint]*models.StockTransferItem{}
+			for i := range transfer.Items {
+				items[transfer.Items[i].ID] = &transfer.Items[i]
+			}
+
+			chosen := map[uint]*uint{}
+			for _, line := range req.Items {
+				item, found := items[line.ItemID]
+				if !found {
+					message = fmt.Sprintf("Item %d is not part of this transfer", line.ItemID)
+					return errStockTransfer
+				}
+				if _, listed := chosen[item.ID]; listed {
+					message = fmt.Sprintf("Item %d is listed more than once", line.ItemID)
+					return errStockTransfer
+				}
+				if line.ReceivedQuantity != nil {
+					received := *line.ReceivedQuantity
+					if received > item.Quantity && !req.AcceptExcess {
+						message = fmt.Sprintf("%.3f of %s was received but only %.3f was sent, set accept_excess to book the excess",
+							received, item.Inventory.Name, item.Quantity)
+						return errStockTransfer
+					}
+					item.ReceivedQuantity = &received
+				}
+				item.DiscrepancyReason = line.Reason
+				chosen[item.ID] = line.DestinationInventoryID
+			}
+
+			for i := range transfer.Items {
+				item := &transfer.Items[i]
+				if item.ReceivedQuantity == nil {
+					sent := item.Quantity
+					item.ReceivedQuantity = &sent
+				}
+				item.Discrepancy = math.Round((*item.ReceivedQuantity-item.Quantity)*1000) / 1000
+				if item.Discrepancy != 0 && item.DiscrepancyReason == "" {
+					message = fmt.Sprintf("A reason is required for the discrepancy on %s", item.Inventory.Name)
+					return errStockTransfer
+				}
+				if item.Discrepancy == 0 {
+					item.DiscrepancyReason = ""
+				}
+
+				destination, msg, err := destinationInventory(tx, transfer.ToBranchID, item.Inventory, chosen[item.ID])
+				if err != nil {
+					message = msg
+					return err
+				}
+				item.DestinationInventoryID = &destination.ID
+
+				if *item.ReceivedQuantity > 0 {
+					movement := models.StockMovement{
+						InventoryID:   destination.ID,
+						Type:          "transfer",
+						Quantity:      *item.ReceivedQuantity,
+						UnitCost:      item.UnitCost,
+						Reason:        fmt.Sprintf("Transfer #%d from branch %d", transfer.ID, transfer.FromBranchID),
+						UserID:        &userID,
+						ReferenceType: "stock_transfer",
+						ReferenceID:   &transfer.ID,
+					}
+					if err := models.RecordStockMovement(tx, &movement); err != nil {
+						return err
+					}
+				}
+
+				if err := tx.Model(item).Select("destination_inventory_id", "received_quantity", "discrepancy", "discrepancy_reason").
+					Updates(item).Error; err != nil {
+					return err
+				}
+				if item.Discrepancy != 0 {
+					discrepancies = append(discrepancies, *item)
+				}
+			}
+
+			now := time.Now()
+			transfer.Status = "received"
+			transfer.ReceivedBy = &userID
+			transfer.ReceivedAt = &now
+			for _, item := range discrepancies {
+				if err := models.PostTransferDiscrepancy(tx, transfer, item); err != nil {
+					return err
+				}
+			}
+			return tx.Omit("Items").Save(&transfer).Error
+		})
+
+		if err != nil {
+			if err == errStockTransfer {
+				c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to receive stock transfer", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock transfer received", "data": transfer, "discrepancies": discrepancies})
+	}
+}
+
+// CancelStockTransfer drops a draft, or calls back a transfer in transit and
+// returns its items to the sending branch.
+func CancelStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transfer, ok := findStockTransfer(c, db)
+		if !ok {
+			return
+		}
+
+		if !canAccessBranch(c, transfer.FromBranchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the sending branch can cancel a transfer"})
+			return
+		}
+
+		userID := currentUserID(c)
+		message := ""
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").
+				First(&transfer, transfer.ID).Error; err != nil {
+				return err
+			}
+			if transfer.Status != "draft" && transfer.Status != "in_transit" {
+				message = "Only draft or in-transit transfers can be cancelled"
+				return errStockTransfer
+			}
+
+			if transfer.Status == "in_transit" {
+				for _, item := range transfer.Items {
+					movement := models.StockMovement{
+						InventoryID:   item.InventoryID,
+						Type:          "transfer",
+						Quantity:      item.Quantity,
+						UnitCost:      item.UnitCost,
+						Reason:        fmt.Sprintf("Transfer #%d cancelled", transfer.ID),
+						UserID:        &userID,
+						ReferenceType: "stock_transfer",
+						ReferenceID:   &transfer.ID,
+					}
+					if err := models.RecordStockMovement(tx, &movement); err != nil {
+						return err
+					}
+				}
+			}
+
+			transfer.Status = "cancelled"
+			return tx.Model(&transfer).Update("status", "cancelled").Error
+		})
+
+		if err != nil {
+			if err == errStockTransfer {
+				c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel stock transfer", "details": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock transfer cancelled", "data": transfer})
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"laundre/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SupplierRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	ContactName string `json:"contact_name" binding:"max=100"`
+	Phone       string `json:"phone" binding:"max=20"`
+	Email       string `json:"email" binding:"omitempty,email,max=100"`
+	Address     string `json:"address"`
+	Notes       string `json:"notes"`
+	Active      *bool  `json:"active"`
+}
+
+func (req SupplierRequest) apply(supplier *models.Supplier) {
+	supplier.Name = req.Name
+	supplier.ContactName = req.ContactName
+	supplier.Phone = req.Phone
+	supplier.Email = req.Email
+	supplier.Address = req.Address
+	supplier.Notes = req.Notes
+	if req.Active != nil {
+		supplier.Active = *req.Active
+	}
+}
+
+func CreateSupplier(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SupplierRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		supplier := models.Supplier{Active: true}
+		req.apply(&supplier)
+
+		if err := db.Create(&supplier).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create supplier", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Supplier created successfully", "data": supplier})
+	}
+}
+
+func GetSuppliers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var suppliers []models.Supplier
+
+		query := db.Model(&models.Supplier{})
+		if search := c.Query("search"); search != "" {
+			like := "%" + search + "%"
+			query = query.Where("name LIKE ? OR contact_name LIKE ? OR phone LIKE ?", like, like, like)
+		}
+		if c.Query("active") == "true" {
+			query = query.Where("active = ?", true)
+		}
+
+		if err := query.Order("name asc").Find(&suppliers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve suppliers", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": suppliers})
+	}
+}
+
+func GetSupplierByID(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var supplier models.Supplier
+		if err := db.First(&supplier, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": supplier})
+	}
+}
+
+func UpdateSupplier(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var supplier models.Supplier
+		if err := db.First(&supplier, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+			return
+		}
+
+		var req SupplierRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		req.apply(&supplier)
+
+		if err := db.Save(&supplier).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Supplier updated successfully", "data": supplier})
+	}
+}
+
+func DeleteSupplier(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var count int64
+		db.Model(&models.PurchaseOrder{}).Where("supplier_id = ?", c.Param("id")).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Supplier has purchase orders, deactivate it instead"})
+			return
+		}
+
+		result := db.Delete(&models.Supplier{}, c.Param("id"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete supplier", "details": result.Error.Error()})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Supplier deleted successfully"})
+	}
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"laundre/models"
+	"laundre/utils"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,8 +20,33 @@ type TransactionRequest struct {
 	CustomerAddress string  `json:"customer_address" binding:"required"`
 	OrderStatus     string  `json:"order_status" binding:"omitempty,oneof=masuk proses urgent done"`
 	BranchID        uint    `json:"branch_id" binding:"required"`
-	TotalPrice      float64 `json:"total_price" binding:"required"`
+	TotalPrice      float64 `json:"total_price" binding:"min=0"`
 	PaymentStatus   string  `json:"payment_status"`
+	PaymentMethod   string  `json:"payment_method" binding:"omitempty,oneof=cash transfer qris"`
+	Service         string  `json:"service" binding:"omitempty,max=50"`
+	Weight          float64 `json:"weight" binding:"omitempty,min=0"`
+}
+
+var errTransactionPrice = errors.New("total price is required")
+
+// findOrCreateCustomer returns the customer with this phone number whose name
+// matches regardless of case and spacing, creating one if there is none.
+func findOrCreateCustomer(tx *gorm.DB, name, phone, address string) (models.Customer, error) {
+	var candidates []models.Customer
+	if err := tx.Where("phone = ?", phone).Order("id asc").Find(&candidates).Error; err != nil {
+		return models.Customer{}, err
+	}
+	for _, candidate := range candidates {
+		if utils.NormalizeName(candidate.Name) == utils.NormalizeName(name) {
+			return candidate, nil
+		}
+	}
+
+	customer := models.Customer{Name: name, Phone: phone, Address: address}
+	if err := tx.Create(&customer).Error; err != nil {
+		return customer, err
+	}
+	return customer, models.SyncDefaultAddress(tx, customer)
 }
 
 func CreateTransaction(db *gorm.DB) gin.HandlerFunc {
@@ -28,29 +57,48 @@ func CreateTransaction(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		err := db.Transaction(func(tx *gorm.DB) error {
-			var customer models.Customer
-			if err := tx.Where("name = ? AND phone = ?", req.CustomerName, req.CustomerPhone).
-				First(&customer).Error; err != nil {
-				if err == gorm.ErrRecordNotFound {
-
-					customer = models.Customer{
-						Name:    req.CustomerName,
-						Phone:   req.CustomerPhone,
-						Address: req.CustomerAddress,
-					}
-					if err := tx.Create(&customer).Error; err != nil {
-						return err
-					}
-				} else {
+		phone, err := utils.NormalizePhone(req.CustomerPhone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.CustomerPhone = phone
+		req.CustomerName = strings.Join(strings.Fields(req.CustomerName), " ")
+
+		var account *models.CorporateAccount
+		var balance float64
+		err = models.StockTransaction(db, func(tx *gorm.DB) error {
+			customer, err := findOrCreateCustomer(tx, req.CustomerName, req.CustomerPhone, req.CustomerAddress)
+			if err != nil {
+				return err
+			}
+
+			// Corporate customers are charged their contract price and may
+			// not run up more unpaid orders than their credit limit allows.
+			if account, err = models.FindCorporateAccount(tx, customer.ID); err != nil {
+				return err
+			}
+			if account != nil {
+				if price, found := account.ContractPrice(req.Service); found && req.Weight > 0 {
+					req.TotalPrice = roundAmount(price * req.Weight)
+				}
+			}
+			if req.TotalPrice <= 0 {
+				return errTransactionPrice
+			}
+			if account != nil && req.PaymentStatus != "paid" {
+				if balance, err = account.CheckCredit(tx, req.TotalPrice); err != nil {
 					return err
 				}
 			}
 
 			order := models.Order{
-				BranchID:   req.BranchID,
-				CustomerID: customer.ID,
-				Status:     req.OrderStatus,
+				BranchID:     req.BranchID,
+				CustomerID:   customer.ID,
+				Status:       req.OrderStatus,
+				Service:      req.Service,
+				Weight:       req.Weight,
+				Instructions: customer.Instructions(),
 			}
 
 			if req.PaymentStatus == "paid" {
@@ -60,6 +108,14 @@ func CreateTransaction(db *gorm.DB) gin.HandlerFunc {
 			if err := tx.Create(&order).Error; err != nil {
 				return err
 			}
+			if err := applyOrderStatus(tx, &order, currentUserID(c)); err != nil {
+				return err
+			}
+
+			paymentMethod := req.PaymentMethod
+			if paymentMethod == "" {
+				paymentMethod = "cash"
+			}
 
 			userID, _ := c.Get("user_id")
 			transaction := models.Transaction{
@@ -68,16 +124,33 @@ func CreateTransaction(db *gorm.DB) gin.HandlerFunc {
 				UserID:        userID.(uint),
 				TotalPrice:    req.TotalPrice,
 				PaymentStatus: req.PaymentStatus,
+				PaymentMethod: paymentMethod,
 			}
 			if err := tx.Create(&transaction).Error; err != nil {
 				return err
 			}
 
+			if err := models.PostSale(tx, transaction); err != nil {
+				return err
+			}
+			if transaction.PaymentStatus == "paid" {
+				if err := models.PostPayment(tx, transaction); err != nil {
+					return err
+				}
+			}
+
 			return nil
 		})
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			switch err {
+			case errTransactionPrice:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "total_price is required"})
+			case models.ErrCreditLimit:
+				c.JSON(http.StatusUnprocessableEntity, creditLimitError(account, balance, req.TotalPrice))
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
 			return
 		}
 
@@ -148,9 +221,14 @@ func UpdateTransaction(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
 			return
 		}
+		if transaction.InvoiceID != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transaction is on an invoice, record the payment on the invoice instead"})
+			return
+		}
 
 		var req struct {
-			PaymentStatus string `json:"payment_status"`
+			PaymentStatus string `json:"payment_status" binding:"required,oneof=paid unpaid"`
+			PaymentMethod string `json:"payment_method" binding:"omitempty,oneof=cash transfer qris"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -158,24 +236,48 @@ func UpdateTransaction(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if req.PaymentStatus == "paid" {
-			var order models.Order
-			if err := db.First(&order, transaction.OrderID).Error; err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-				return
+		err := db.Transaction(func(tx *gorm.DB) error {
+			wasPaid := transaction.PaymentStatus == "paid"
+
+			if req.PaymentStatus == "paid" || wasPaid {
+				var order models.Order
+				if err := tx.First(&order, transaction.OrderID).Error; err != nil {
+					return err
+				}
+
+				if req.PaymentStatus == "paid" {
+					order.Price = transaction.TotalPrice
+				} else {
+					order.Price = 0
+				}
+				if err := tx.Save(&order).Error; err != nil {
+					return err
+				}
 			}
 
-			order.Price = transaction.TotalPrice
-			if err := db.Save(&order).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order price"})
-				return
+			transaction.PaymentStatus = req.PaymentStatus
+			if req.PaymentMethod != "" && !wasPaid {
+				transaction.PaymentMethod = req.PaymentMethod
+			}
+			if err := tx.Save(&transaction).Error; err != nil {
+				return err
 			}
-		}
 
-		transaction.PaymentStatus = req.PaymentStatus
+			switch {
+			case req.PaymentStatus == "paid" && !wasPaid:
+				return models.PostPayment(tx, transaction)
+			case req.PaymentStatus == "unpaid" && wasPaid:
+				return models.PostRefund(tx, transaction)
+			}
+			return nil
+		})
 
-		if err := db.Save(&transaction).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
 			return
 		}
 
@@ -192,20 +294,17 @@ func DeleteTransaction(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
 			return
 		}
-
-		result := db.Delete(&models.Transaction{}, id)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		if transaction.InvoiceID != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transaction is on an invoice, void the invoice first"})
 			return
 		}
 
-		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
-			return
-		}
+		err := models.StockTransaction(db, func(tx *gorm.DB) error {
+			return deleteOrder(tx, transaction.OrderID, currentUserID(c), fmt.Sprintf("Reversal: transaction #%d deleted", transaction.ID))
+		})
 
-		if err := db.Delete(&models.Order{}, transaction.OrderID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated order"})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transaction", "details": err.Error()})
 			return
 		}
 
@@ -213,12 +312,60 @@ func DeleteTransaction(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// errOrderInvoiced refuses to delete an order billed on a corporate invoice.
+var errOrderInvoiced = errors.New("order is on an invoice")
+
+// deleteOrder reverses the ledger entries of an order's transactions and
+// complaint settlements and returns the consumables it used to stock, then
+// deletes the order together with its transactions. Orders billed on an
+// invoice are refused with errOrderInvoiced, since the invoice would no
+// longer match the ledger.
+func deleteOrder(tx *gorm.DB, orderID, userID uint, description string) error {
+	var order models.Order
+	if err := tx.First(&order, orderID).Error; err != nil {
+		return err
+	}
+
+	var transactions []models.Transaction
+	if err := tx.Where("order_id = ?", orderID).Find(&transactions).Error; err != nil {
+		return err
+	}
+	for _, transaction := range transactions {
+		if transaction.InvoiceID != nil {
+			return errOrderInvoiced
+		}
+		if err := models.ReverseJournalEntries(tx, transaction.ID, description,
+			models.JournalSale, models.JournalPayment, models.JournalRefund); err != nil {
+			return err
+		}
+	}
+
+	var complaintIDs []uint
+	if err := tx.Model(&models.Complaint{}).Where("order_id = ?", orderID).
+		Pluck("id", &complaintIDs).Error; err != nil {
+		return err
+	}
+	for _, complaintID := range complaintIDs {
+		if err := models.ReverseJournalEntries(tx, complaintID, description, models.JournalComplaint); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("order_id = ?", orderID).Delete(&models.Transaction{}).Error; err != nil {
+		return err
+	}
+	if err := models.RestoreOrderConsumables(tx, &order, &userID); err != nil {
+		return err
+	}
+	return tx.Delete(&models.Order{}, orderID).Error
+}
+
 func GetTransactionsByOrderStatus(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		status := c.Param("status")
 
-		allowedStatuses := []string{"masuk", "proses", "urgent", "done"}
+		allowedStatuses := []string{"masuk", "proses", "urgent", "done", "cancelled"}
 
 		if !contains(allowedStatuses, status) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
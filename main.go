@@ -1,9 +1,13 @@
 package main
 
 import (
+	"laundre/alerts"
 	"laundre/config"
 	"laundre/migrations"
+	"laundre/notifications"
 	"laundre/routes"
+	"laundre/scheduler"
+	"laundre/storage"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -17,9 +21,28 @@ func main() {
 
 	migrations.RunMigrations(db)
 
+	if path := config.AlertLogFile(); path != "" {
+		alerts.SetNotifier(alerts.NewFileNotifier(path))
+	}
+	if path := config.NotificationOutboxFile(); path != "" {
+		notifications.SetProvider(notifications.NewOutboxProvider(path))
+	}
+
+	scheduler.Start(db)
+
 	r := gin.Default()
 
-	routes.RegisterRoutes(r, db)
+	var store storage.Storage
+	switch config.StorageBackend() {
+	case "s3":
+		store = storage.NewS3(config.S3())
+	case "local":
+		store = storage.NewLocal(config.StorageDir())
+	default:
+		log.Fatal("Unknown STORAGE_BACKEND: ", config.StorageBackend())
+	}
+
+	routes.RegisterRoutes(r, db, store)
 
 	log.Println("Server is running on http://localhost:8080")
 	if err := r.Run(":8080"); err != nil {
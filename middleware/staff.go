@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,7 +26,8 @@ func AdminOrStaffBranch() gin.HandlerFunc {
 
 		if role == "staf" {
 			userBranchID, exists := c.Get("branch_id")
-			if !exists || userBranchID == nil {
+			branchID, ok := userBranchID.(*uint)
+			if !exists || !ok || branchID == nil {
 				c.JSON(http.StatusForbidden, gin.H{"error": "No branch assigned"})
 				c.Abort()
 				return
@@ -33,25 +37,27 @@ func AdminOrStaffBranch() gin.HandlerFunc {
 			if requestedBranchID == "" {
 				requestedBranchID = c.Query("branch_id")
 				if requestedBranchID == "" {
-					if c.Request.Method != "GET" {
+					if c.Request.Method != "GET" && c.Request.Body != nil {
+						body, err := io.ReadAll(c.Request.Body)
+						if err != nil {
+							c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get request body"})
+							c.Abort()
+							return
+						}
+						c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
 						var bodyMap map[string]interface{}
 						if err := c.ShouldBindJSON(&bodyMap); err == nil {
-							if branchID, exists := bodyMap["branch_id"]; exists {
-								requestedBranchID = fmt.Sprintf("%.0f", branchID.(float64))
+							if bodyBranchID, ok := bodyMap["branch_id"].(float64); ok {
+								requestedBranchID = fmt.Sprintf("%.0f", bodyBranchID)
 							}
 						}
-						body, err := c.Request.GetBody()
-						if err == nil {
-							c.Request.Body = body
-						} else {
-							c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get request body"})
-							return
-						}
+						c.Request.Body = io.NopCloser(bytes.NewReader(body))
 					}
 				}
 			}
 
-			if requestedBranchID != "" && requestedBranchID != string(userBranchID.(uint)) {
+			if requestedBranchID != "" && requestedBranchID != strconv.FormatUint(uint64(*branchID), 10) {
 				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied for this branch"})
 				c.Abort()
 				return
@@ -2,6 +2,7 @@ package migrations
 
 import (
 	"laundre/models"
+	"laundre/utils"
 	"log"
 
 	"golang.org/x/crypto/bcrypt"
@@ -9,6 +10,8 @@ import (
 )
 
 func RunMigrations(db *gorm.DB) {
+	branchDefaults := newBranchColumns(db)
+
 	err := db.AutoMigrate(
 		&models.User{},
 		&models.Branch{},
@@ -19,6 +22,45 @@ func RunMigrations(db *gorm.DB) {
 		&models.Expense{},
 		&models.Log{},
 		&models.TokenBlacklist{},
+		&models.Account{},
+		&models.JournalEntry{},
+		&models.JournalLine{},
+		&models.ExportMapping{},
+		&models.ExportProfile{},
+		&models.ExpenseCategory{},
+		&models.Attachment{},
+		&models.RecurringExpense{},
+		&models.Budget{},
+		&models.RevenueTarget{},
+		&models.StockMovement{},
+		&models.ConsumptionRecipe{},
+		&models.Supplier{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderItem{},
+		&models.PurchaseReceipt{},
+		&models.PurchaseReceiptItem{},
+		&models.StockTransfer{},
+		&models.StockTransferItem{},
+		&models.StockTake{},
+		&models.StockTakeItem{},
+		&models.InventoryUnit{},
+		&models.Machine{},
+		&models.MachineLoad{},
+		&models.MaintenancePlan{},
+		&models.MaintenanceTask{},
+		&models.MachineDowntime{},
+		&models.OrderItem{},
+		&models.Complaint{},
+		&models.Notification{},
+		&models.PaymentReminder{},
+		&models.OrderDisposition{},
+		&models.CorporateAccount{},
+		&models.ContractPrice{},
+		&models.Invoice{},
+		&models.InvoiceLine{},
+		&models.CustomerMerge{},
+		&models.CustomerAddress{},
+		&models.CustomerContact{},
 	)
 	if err != nil {
 		log.Fatal("Failed to run migrations:", err)
@@ -26,6 +68,19 @@ func RunMigrations(db *gorm.DB) {
 		log.Println("Database migrated successfully!")
 	}
 
+	for _, account := range models.DefaultAccounts {
+		if err := db.Where(models.Account{Code: account.Code}).FirstOrCreate(&account).Error; err != nil {
+			log.Println("Failed to seed account", account.Code, err)
+		}
+	}
+
+	backfillBranchSettings(db, branchDefaults)
+	backfillJournal(db)
+	backfillStockMovements(db)
+	normalizeCustomerPhones(db)
+	backfillTrackingCodes(db)
+	backfillCustomerAddresses(db)
+
 	var adminCount int64
 	db.Model(&models.User{}).Where("role = ?", "admin").Count(&adminCount)
 
@@ -53,3 +108,192 @@ func RunMigrations(db *gorm.DB) {
 		log.Println("Database migrated successfully!")
 	}
 }
+
+// newBranchColumns returns the branch settings, with their default values,
+// whose columns have not been created yet.
+func newBranchColumns(db *gorm.DB) map[string]interface{} {
+	settings := map[string]interface{}{
+		"reminder_days":        models.DefaultReminderDays,
+		"escalate_after_days":  models.DefaultEscalateAfterDays,
+		"pickup_reminder_days": models.DefaultPickupReminderDays,
+		"final_notice_days":    models.DefaultFinalNoticeDays,
+		"disposal_days":        models.DefaultDisposalDays,
+	}
+	missing := map[string]interface{}{}
+	if !db.Migrator().HasTable(&models.Branch{}) {
+		return missing
+	}
+	for column, value := range settings {
+		if !db.Migrator().HasColumn(&models.Branch{}, column) {
+			missing[column] = value
+		}
+	}
+	return missing
+}
+
+// backfillBranchSettings gives branches that existed before a setting was
+// added its default value rather than the zero that disables it.
+func backfillBranchSettings(db *gorm.DB, defaults map[string]interface{}) {
+	if len(defaults) == 0 {
+		return
+	}
+	if err := db.Model(&models.Branch{}).Where("1 = 1").Updates(defaults).Error; err != nil {
+		log.Println("Failed to backfill branch settings:", err)
+	}
+}
+
+// backfillJournal posts ledger entries for transactions, expenses and stock
+// movements recorded before the general ledger existed. It only runs against
+// an empty journal.
+func backfillJournal(db *gorm.DB) {
+	var entryCount int64
+	db.Model(&models.JournalEntry{}).Count(&entryCount)
+	if entryCount > 0 {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var transactions []models.Transaction
+		if err := tx.Find(&transactions).Error; err != nil {
+			return err
+		}
+		for _, transaction := range transactions {
+			if err := models.PostSale(tx, transaction); err != nil {
+				return err
+			}
+			if transaction.PaymentStatus == "paid" {
+				if err := models.PostPayment(tx, transaction); err != nil {
+					return err
+				}
+			}
+		}
+
+		var expenses []models.Expense
+		if err := tx.Where("status = ?", "approved").Find(&expenses).Error; err != nil {
+			return err
+		}
+		for _, expense := range expenses {
+			if err := models.PostExpense(tx, expense); err != nil {
+				return err
+			}
+		}
+
+		// Opening balances recorded before they were posted to equity carry no
+		// reference of their own.
+		if err := tx.Model(&models.StockMovement{}).
+			Where("type = ? AND reference_type = ? AND reason = ?", "adjustment", "", "Opening balance").
+			Update("reference_type", "opening_balance").Error; err != nil {
+			return err
+		}
+		var movements []models.StockMovement
+		if err := tx.Where("type IN ?", []string{"purchase", "consumption", "waste", "adjustment", "transfer"}).Find(&movements).Error; err != nil {
+			return err
+		}
+		for _, movement := range movements {
+			if err := models.PostStockMovement(tx, movement); err != nil {
+				return err
+			}
+		}
+
+		var transfers []models.StockTransfer
+		if err := tx.Preload("Items", "discrepancy <> 0").Where("status = ?", "received").Find(&transfers).Error; err != nil {
+			return err
+		}
+		for _, transfer := range transfers {
+			for _, item := range transfer.Items {
+				if err := models.PostTransferDiscrepancy(tx, transfer, item); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println("Failed to backfill journal:", err)
+	}
+}
+
+// backfillStockMovements records an opening balance for items whose stock
+// was set before stock movements were tracked.
+func backfillStockMovements(db *gorm.DB) {
+	var inventories []models.Inventory
+	if err := db.Where("stock <> 0 AND NOT EXISTS (SELECT 1 FROM stock_movements WHERE stock_movements.inventory_id = inventories.id)").
+		Find(&inventories).Error; err != nil {
+		log.Println("Failed to backfill stock movements:", err)
+		return
+	}
+
+	for _, inventory := range inventories {
+		movement := models.StockMovement{
+			InventoryID:   inventory.ID,
+			BranchID:      inventory.BranchID,
+			Type:          "adjustment",
+			Quantity:      inventory.Stock,
+			BalanceAfter:  inventory.Stock,
+			Reason:        "Opening balance",
+			ReferenceType: "opening_balance",
+		}
+		if err := db.Create(&movement).Error; err != nil {
+			log.Println("Failed to backfill stock movements:", err)
+			return
+		}
+	}
+}
+
+// normalizeCustomerPhones rewrites phone numbers saved before they were
+// stored in E.164. Numbers that cannot be read are left for staff to fix.
+func normalizeCustomerPhones(db *gorm.DB) {
+	var customers []models.Customer
+	if err := db.Select("id, phone").Where("phone NOT LIKE ?", "+%").Find(&customers).Error; err != nil {
+		log.Println("Failed to normalize customer phones:", err)
+		return
+	}
+
+	for _, customer := range customers {
+		phone, err := utils.NormalizePhone(customer.Phone)
+		if err != nil {
+			log.Printf("Customer %d has an invalid phone number %q", customer.ID, customer.Phone)
+			continue
+		}
+		if err := db.Model(&models.Customer{}).Where("id = ?", customer.ID).Update("phone", phone).Error; err != nil {
+			log.Println("Failed to normalize customer phones:", err)
+			return
+		}
+	}
+}
+
+// backfillTrackingCodes gives orders created before tracking codes existed a
+// code of their own.
+func backfillTrackingCodes(db *gorm.DB) {
+	var ids []uint
+	if err := db.Model(&models.Order{}).Where("tracking_code IS NULL").Pluck("id", &ids).Error; err != nil {
+		log.Println("Failed to backfill tracking codes:", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := db.Model(&models.Order{}).Where("id = ?", id).Update("tracking_code", models.NewTrackingCode()).Error; err != nil {
+			log.Println("Failed to backfill tracking codes:", err)
+			return
+		}
+	}
+}
+
+// backfillCustomerAddresses starts an address book with the existing address
+// of customers who do not have one yet.
+func backfillCustomerAddresses(db *gorm.DB) {
+	var customers []models.Customer
+	if err := db.Where("address <> '' AND NOT EXISTS (SELECT 1 FROM customer_addresses WHERE customer_addresses.customer_id = customers.id)").
+		Find(&customers).Error; err != nil {
+		log.Println("Failed to backfill customer addresses:", err)
+		return
+	}
+
+	for _, customer := range customers {
+		if err := models.SyncDefaultAddress(db, customer); err != nil {
+			log.Println("Failed to backfill customer addresses:", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Attachment is a file kept in the configured storage backend and linked to
+// any record through OwnerType and OwnerID.
+type Attachment struct {
+	ID          uint   `gorm:"primaryKey"`
+	OwnerType   string `gorm:"size:30;not null;index:idx_attachment_owner"`
+	OwnerID     uint   `gorm:"not null;index:idx_attachment_owner"`
+	FileName    string `gorm:"size:255;not null"`
+	ContentType string `gorm:"size:100;not null"`
+	Size        int64  `gorm:"not null"`
+	StorageKey  string `gorm:"size:255;not null"`
+	// ThumbnailKey holds a small JPEG preview for image uploads.
+	ThumbnailKey string    `gorm:"size:255"`
+	UploadedBy   uint      `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
@@ -1,8 +1,79 @@
 package models
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 type Branch struct {
 	ID      uint   `gorm:"primaryKey"`
 	Name    string `gorm:"size:100;not null"`
 	Address string `gorm:"type:text;not null"`
 	Phone   string `gorm:"size:20;not null"`
+	// ExpenseApprovalThreshold is the largest expense staff may record
+	// without admin approval. Zero disables the approval flow.
+	ExpenseApprovalThreshold float64 `gorm:"type:decimal(10,2);default:0"`
+	// ReminderDays lists the days after an unpaid transaction was created on
+	// which the customer is reminded, e.g. "1,3,7". Empty disables reminders.
+	ReminderDays string `gorm:"size:50;not null"`
+	// EscalateAfterDays is how long a transaction may stay unpaid before it
+	// is escalated to the branch's overdue report. Zero disables escalation.
+	EscalateAfterDays int `gorm:"not null"`
+	// Finished orders that are not collected get a pickup reminder after
+	// PickupReminderDays, a final notice after FinalNoticeDays and may be
+	// disposed of or donated after DisposalDays. Zero disables a step.
+	PickupReminderDays int `gorm:"not null"`
+	FinalNoticeDays    int `gorm:"not null"`
+	DisposalDays       int `gorm:"not null"`
+}
+
+// Settings given to new branches unless the request says otherwise. The
+// columns have no database default, since GORM would then store the default
+// in place of an explicit zero or empty value.
+const (
+	DefaultReminderDays       = "1,3,7"
+	DefaultEscalateAfterDays  = 14
+	DefaultPickupReminderDays = 14
+	DefaultFinalNoticeDays    = 30
+	DefaultDisposalDays       = 90
+)
+
+// ParseReminderDays reads a comma separated list of positive day counts and
+// returns it sorted without duplicates.
+func ParseReminderDays(value string) ([]int, error) {
+	var days []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		day, err := strconv.Atoi(part)
+		if err != nil || day <= 0 {
+			return nil, fmt.Errorf("invalid reminder day %q", part)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Ints(days)
+	return days, nil
+}
+
+// AbandonmentStage says how far a finished order that has waited days for
+// pickup is along the branch's abandoned laundry policy: "", "reminder",
+// "final_notice" or "disposal".
+func (b Branch) AbandonmentStage(days int) string {
+	switch {
+	case b.DisposalDays > 0 && days >= b.DisposalDays:
+		return "disposal"
+	case b.FinalNoticeDays > 0 && days >= b.FinalNoticeDays:
+		return "final_notice"
+	case b.PickupReminderDays > 0 && days >= b.PickupReminderDays:
+		return "reminder"
+	}
+	return ""
 }
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReminderDays(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{value: DefaultReminderDays, want: []int{1, 3, 7}},
+		{value: "7, 1,3", want: []int{1, 3, 7}},
+		{value: "3,3,1,3", want: []int{1, 3}},
+		{value: "1,,3,", want: []int{1, 3}},
+		{value: "", want: nil},
+		{value: " , ", want: nil},
+		{value: "1,0", wantErr: true},
+		{value: "-2", wantErr: true},
+		{value: "1,two", wantErr: true},
+		{value: "1.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseReminderDays(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseReminderDays(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseReminderDays(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBranchAbandonmentStage(t *testing.T) {
+	standard := Branch{
+		PickupReminderDays: DefaultPickupReminderDays,
+		FinalNoticeDays:    DefaultFinalNoticeDays,
+		DisposalDays:       DefaultDisposalDays,
+	}
+
+	tests := []struct {
+		name   string
+		branch Branch
+		days   int
+		want   string
+	}{
+		{name: "just finished", branch: standard, days: 0, want: ""},
+		{name: "before the reminder", branch: standard, days: 13, want: ""},
+		{name: "reminder day", branch: standard, days: 14, want: "reminder"},
+		{name: "final notice day", branch: standard, days: 30, want: "final_notice"},
+		{name: "between notice and disposal", branch: standard, days: 89, want: "final_notice"},
+		{name: "disposal day", branch: standard, days: 90, want: "disposal"},
+		{name: "long past disposal", branch: standard, days: 400, want: "disposal"},
+		{name: "everything disabled", branch: Branch{}, days: 400, want: ""},
+		{name: "no reminder", branch: Branch{FinalNoticeDays: 30, DisposalDays: 90}, days: 20, want: ""},
+		{name: "no disposal", branch: Branch{PickupReminderDays: 14, FinalNoticeDays: 30}, days: 400, want: "final_notice"},
+		{name: "only disposal", branch: Branch{DisposalDays: 60}, days: 60, want: "disposal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.branch.AbandonmentStage(tt.days); got != tt.want {
+				t.Fatalf("AbandonmentStage(%d) = %q, want %q", tt.days, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+// Budget caps a branch's spending for one month (YYYY-MM). A nil CategoryID
+// is the branch-wide budget across all categories.
+type Budget struct {
+	ID         uint             `gorm:"primaryKey"`
+	BranchID   uint             `gorm:"not null;index:idx_budget_period"`
+	CategoryID *uint            `gorm:"default:null;index:idx_budget_period"`
+	Month      string           `gorm:"size:7;not null;index:idx_budget_period"`
+	Amount     float64          `gorm:"type:decimal(12,2);not null"`
+	Branch     Branch           `gorm:"constraint:OnDelete:CASCADE"`
+	Category   *ExpenseCategory `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type RevenueTarget struct {
+	ID       uint    `gorm:"primaryKey"`
+	BranchID uint    `gorm:"not null;uniqueIndex:idx_revenue_target"`
+	Month    string  `gorm:"size:7;not null;uniqueIndex:idx_revenue_target"`
+	Amount   float64 `gorm:"type:decimal(12,2);not null"`
+	Branch   Branch  `gorm:"constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Complaint is a customer ticket about an order: a stain that did not come
+// out, a damaged or lost garment, a late delivery. Resolving it records how
+// the customer was made whole.
+type Complaint struct {
+	ID          uint   `gorm:"primaryKey"`
+	BranchID    uint   `gorm:"not null;index"`
+	OrderID     uint   `gorm:"not null;index"`
+	CustomerID  uint   `gorm:"not null;index"`
+	OrderItemID *uint  `gorm:"default:null"`
+	Category    string `gorm:"type:enum('stain','damage','lost','shrinkage','color_bleed','late','service','other');not null"`
+	Severity    string `gorm:"type:enum('low','medium','high','critical');default:'medium'"`
+	Description string `gorm:"type:text;not null"`
+	Status      string `gorm:"type:enum('open','in_progress','resolved','rejected');default:'open'"`
+	// Resolution is how the complaint was settled. Compensation is paid out
+	// either as an expense or as a refund against revenue, a discount lowers
+	// what is still owed on the order and a rewash opens a free follow-up
+	// order.
+	Resolution         *string    `gorm:"type:enum('rewash','compensation','discount');default:null"`
+	CompensationAmount float64    `gorm:"type:decimal(10,2);default:0"`
+	CompensationMethod *string    `gorm:"type:enum('expense','refund');default:null"`
+	ExpenseID          *uint      `gorm:"default:null"`
+	RewashOrderID      *uint      `gorm:"default:null"`
+	ResolutionNotes    string     `gorm:"type:text"`
+	ReportedBy         uint       `gorm:"not null"`
+	ResolvedBy         *uint      `gorm:"default:null"`
+	ResolvedAt         *time.Time `gorm:"default:null"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt          time.Time  `gorm:"autoUpdateTime"`
+	Branch             Branch     `gorm:"constraint:OnDelete:CASCADE"`
+	Order              Order      `gorm:"constraint:OnDelete:CASCADE"`
+	Customer           Customer   `gorm:"constraint:OnDelete:CASCADE"`
+	OrderItem          *OrderItem `gorm:"constraint:OnDelete:SET NULL"`
+	Expense            *Expense   `gorm:"constraint:OnDelete:SET NULL"`
+	RewashOrder        *Order     `gorm:"constraint:OnDelete:SET NULL"`
+}
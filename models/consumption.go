@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ConsumptionRecipe states how much of an inventory item a service uses,
+// per kilogram of laundry and per order. Since inventory rows belong to a
+// branch, recipes are branch specific as well.
+type ConsumptionRecipe struct {
+	ID               uint      `gorm:"primaryKey"`
+	Service          string    `gorm:"size:50;not null;index"`
+	InventoryID      uint      `gorm:"not null"`
+	QuantityPerKg    float64   `gorm:"type:decimal(12,3);default:0"`
+	QuantityPerOrder float64   `gorm:"type:decimal(12,3);default:0"`
+	Inventory        Inventory `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// DeductOrderConsumables takes the order's recipe quantities out of the
+// branch stock once. Stock may go negative here: the laundry has already been
+// processed, so the shortfall is better surfaced in the ledger than by
+// blocking the order.
+func DeductOrderConsumables(tx *gorm.DB, order *Order, userID *uint) error {
+	if order.ConsumablesDeducted || order.Service == "" {
+		return nil
+	}
+
+	var recipes []ConsumptionRecipe
+	if err := tx.Joins("JOIN inventories ON inventories.id = consumption_recipes.inventory_id").
+		Where("consumption_recipes.service = ? AND inventories.branch_id = ?", order.Service, order.BranchID).
+		Find(&recipes).Error; err != nil {
+		return err
+	}
+
+	for _, recipe := range recipes {
+		quantity := recipe.QuantityPerOrder + recipe.QuantityPerKg*order.Weight
+		if quantity <= 0 {
+			continue
+		}
+
+		movement := StockMovement{
+			InventoryID:   recipe.InventoryID,
+			Type:          "consumption",
+			Quantity:      -quantity,
+			Reason:        fmt.Sprintf("Order #%d (%s)", order.ID, order.Service),
+			UserID:        userID,
+			ReferenceType: "order",
+			ReferenceID:   &order.ID,
+		}
+		if err := recordStockMovement(tx, &movement, true); err != nil {
+			return err
+		}
+	}
+
+	order.ConsumablesDeducted = true
+	return tx.Model(order).Update("consumables_deducted", true).Error
+}
+
+// RestoreOrderConsumables puts back whatever DeductOrderConsumables took for
+// the order, for example when it is cancelled or deleted.
+func RestoreOrderConsumables(tx *gorm.DB, order *Order, userID *uint) error {
+	if !order.ConsumablesDeducted {
+		return nil
+	}
+
+	var used []struct {
+		InventoryID uint
+		Quantity    float64
+	}
+	if err := tx.Model(&StockMovement{}).
+		Select("inventory_id, SUM(quantity) as quantity").
+		Where("reference_type = ? AND reference_id = ? AND type = ?", "order", order.ID, "consumption").
+		Group("inventory_id").
+		Scan(&used).Error; err != nil {
+		return err
+	}
+
+	for _, item := range used {
+		if item.Quantity >= 0 {
+			continue
+		}
+
+		movement := StockMovement{
+			InventoryID:   item.InventoryID,
+			Type:          "consumption",
+			Quantity:      -item.Quantity,
+			Reason:        fmt.Sprintf("Reversal: consumables returned from order #%d", order.ID),
+			UserID:        userID,
+			ReferenceType: "order",
+			ReferenceID:   &order.ID,
+		}
+		if err := RecordStockMovement(tx, &movement); err != nil {
+			return err
+		}
+	}
+
+	order.ConsumablesDeducted = false
+	return tx.Model(order).Update("consumables_deducted", false).Error
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CorporateAccount turns a customer into a business client, such as a hotel
+// or a boarding house, that is billed monthly instead of per order.
+// CreditLimit caps the unpaid balance they may run up; zero means no limit.
+type CorporateAccount struct {
+	ID               uint            `gorm:"primaryKey"`
+	CustomerID       uint            `gorm:"not null;uniqueIndex"`
+	CompanyName      string          `gorm:"size:150;not null"`
+	ContactName      string          `gorm:"size:100"`
+	Email            string          `gorm:"size:100"`
+	BillingAddress   string          `gorm:"type:text"`
+	TaxID            string          `gorm:"size:30"`
+	CreditLimit      float64         `gorm:"type:decimal(12,2);default:0"`
+	PaymentTermsDays int             `gorm:"not null"`
+	Active           bool            `gorm:"not null"`
+	CreatedAt        time.Time       `gorm:"autoCreateTime"`
+	UpdatedAt        time.Time       `gorm:"autoUpdateTime"`
+	Customer         Customer        `gorm:"constraint:OnDelete:CASCADE"`
+	Prices           []ContractPrice `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// DefaultPaymentTermsDays applies to accounts opened without payment terms.
+// Zero is kept when given and makes invoices due on the day they are issued.
+const DefaultPaymentTermsDays = 30
+
+// ContractPrice is the agreed price per kilogram for a service.
+type ContractPrice struct {
+	ID                 uint    `gorm:"primaryKey"`
+	CorporateAccountID uint    `gorm:"not null;uniqueIndex:idx_contract_service"`
+	Service            string  `gorm:"size:50;not null;uniqueIndex:idx_contract_service"`
+	PricePerKg         float64 `gorm:"type:decimal(10,2);not null"`
+}
+
+// Invoice consolidates a corporate account's unpaid transactions for a
+// month. Paying the invoice settles all of its transactions; a voided
+// invoice releases them so the month can be billed again.
+type Invoice struct {
+	ID                 uint             `gorm:"primaryKey"`
+	Number             string           `gorm:"size:40;uniqueIndex"`
+	CorporateAccountID uint             `gorm:"not null;index:idx_invoice_period"`
+	PeriodStart        time.Time        `gorm:"type:date;not null;index:idx_invoice_period"`
+	PeriodEnd          time.Time        `gorm:"type:date;not null"`
+	IssueDate          time.Time        `gorm:"type:date;not null"`
+	DueDate            time.Time        `gorm:"type:date;not null"`
+	Total              float64          `gorm:"type:decimal(12,2);not null"`
+	Status             string           `gorm:"type:enum('issued','paid','void');default:'issued'"`
+	PaymentMethod      string           `gorm:"size:20"`
+	PaidAt             *time.Time       `gorm:"default:null"`
+	CreatedBy          *uint            `gorm:"default:null"`
+	CreatedAt          time.Time        `gorm:"autoCreateTime"`
+	CorporateAccount   CorporateAccount `gorm:"constraint:OnDelete:CASCADE"`
+	Lines              []InvoiceLine    `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type InvoiceLine struct {
+	ID            uint        `gorm:"primaryKey"`
+	InvoiceID     uint        `gorm:"not null;index"`
+	TransactionID uint        `gorm:"not null;index"`
+	OrderID       uint        `gorm:"not null"`
+	Date          time.Time   `gorm:"not null"`
+	Description   string      `gorm:"size:255;not null"`
+	Amount        float64     `gorm:"type:decimal(10,2);not null"`
+	Transaction   Transaction `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+var ErrCreditLimit = errors.New("credit limit exceeded")
+
+// FindCorporateAccount returns the active corporate account of a customer,
+// or nil for walk-in customers.
+func FindCorporateAccount(tx *gorm.DB, customerID uint) (*CorporateAccount, error) {
+	var account CorporateAccount
+	err := tx.Preload("Prices").Where("customer_id = ? AND active = ?", customerID, true).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ContractPrice returns the agreed price per kilogram for a service.
+func (a CorporateAccount) ContractPrice(service string) (float64, bool) {
+	for _, price := range a.Prices {
+		if price.Service == service {
+			return price.PricePerKg, true
+		}
+	}
+	return 0, false
+}
+
+// OutstandingBalance sums the customer's unpaid transactions.
+func OutstandingBalance(tx *gorm.DB, customerID uint) (float64, error) {
+	var balance float64
+	err := tx.Model(&Transaction{}).
+		Joins("JOIN orders ON orders.id = transactions.order_id").
+		Where("orders.customer_id = ? AND transactions.payment_status = ?", customerID, "unpaid").
+		Select("COALESCE(SUM(transactions.total_price), 0)").Scan(&balance).Error
+	return balance, err
+}
+
+// CheckCredit returns ErrCreditLimit when adding amount to the account's
+// unpaid balance would take it over its credit limit.
+func (a CorporateAccount) CheckCredit(tx *gorm.DB, amount float64) (float64, error) {
+	balance, err := OutstandingBalance(tx, a.CustomerID)
+	if err != nil {
+		return 0, err
+	}
+	if a.CreditLimit > 0 && balance+amount > a.CreditLimit {
+		return balance, ErrCreditLimit
+	}
+	return balance, nil
+}
@@ -1,9 +1,41 @@
 package models
 
+import "strings"
+
 type Customer struct {
 	ID       uint   `gorm:"primaryKey"`
 	Name     string `gorm:"size:100;not null"`
-	Phone    string `gorm:"size:20;not null"`
+	Phone    string `gorm:"size:20;not null;index"`
 	Address  string `gorm:"type:text;not null"`
 	Category string `gorm:"type:enum('setia','reguler');default:'reguler'"`
+	// Language and NotificationChannel decide how order updates reach the
+	// customer; NotificationsOptOut stops them altogether.
+	Language            string `gorm:"type:enum('id','en');default:'id'"`
+	NotificationChannel string `gorm:"type:enum('whatsapp','sms');default:'whatsapp'"`
+	NotificationsOptOut bool   `gorm:"default:false"`
+	// Fragrance, FoldingStyle, NoBleach and PreferenceNotes are the customer's
+	// standing instructions; they are copied onto every new order.
+	Fragrance       string `gorm:"size:50"`
+	FoldingStyle    string `gorm:"size:20"`
+	NoBleach        bool   `gorm:"default:false"`
+	PreferenceNotes string `gorm:"size:255"`
+}
+
+// Instructions summarises the customer's preferences for the order ticket,
+// e.g. "Fragrance: lavender; Folding: hanger; No bleach".
+func (c Customer) Instructions() string {
+	var parts []string
+	if c.Fragrance != "" {
+		parts = append(parts, "Fragrance: "+c.Fragrance)
+	}
+	if c.FoldingStyle != "" {
+		parts = append(parts, "Folding: "+c.FoldingStyle)
+	}
+	if c.NoBleach {
+		parts = append(parts, "No bleach")
+	}
+	if c.PreferenceNotes != "" {
+		parts = append(parts, c.PreferenceNotes)
+	}
+	return strings.Join(parts, "; ")
 }
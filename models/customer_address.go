@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CustomerAddress is an entry in a customer's address book. The default
+// address is mirrored in Customer.Address.
+type CustomerAddress struct {
+	ID         uint      `gorm:"primaryKey"`
+	CustomerID uint      `gorm:"not null;index"`
+	Label      string    `gorm:"size:50;not null"`
+	Address    string    `gorm:"type:text;not null"`
+	Latitude   *float64  `gorm:"type:decimal(10,7);default:null"`
+	Longitude  *float64  `gorm:"type:decimal(10,7);default:null"`
+	Notes      string    `gorm:"size:255"`
+	IsDefault  bool      `gorm:"default:false"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+	Customer   Customer  `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// CustomerContact is another person who may drop off or collect laundry
+// for the customer, such as a family member.
+type CustomerContact struct {
+	ID           uint      `gorm:"primaryKey"`
+	CustomerID   uint      `gorm:"not null;index"`
+	Name         string    `gorm:"size:100;not null"`
+	Phone        string    `gorm:"size:20;not null;index"`
+	Relationship string    `gorm:"size:50"`
+	Notes        string    `gorm:"size:255"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	Customer     Customer  `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// SyncDefaultAddress keeps the default address book entry in line with
+// Customer.Address, creating it for customers without an address book.
+func SyncDefaultAddress(tx *gorm.DB, customer Customer) error {
+	if customer.Address == "" {
+		return nil
+	}
+
+	var address CustomerAddress
+	err := tx.Where("customer_id = ? AND is_default = ?", customer.ID, true).First(&address).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		address = CustomerAddress{CustomerID: customer.ID, Label: "Home", Address: customer.Address, IsDefault: true}
+		return tx.Create(&address).Error
+	}
+	if err != nil || address.Address == customer.Address {
+		return err
+	}
+	return tx.Model(&address).Update("address", customer.Address).Error
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// CustomerMerge records a duplicate customer that was folded into another
+// one, keeping the details of the removed record.
+type CustomerMerge struct {
+	ID               uint      `gorm:"primaryKey"`
+	CustomerID       uint      `gorm:"not null;index"`
+	MergedCustomerID uint      `gorm:"not null"`
+	MergedName       string    `gorm:"size:100;not null"`
+	MergedPhone      string    `gorm:"size:20;not null"`
+	MergedAddress    string    `gorm:"type:text"`
+	OrdersMoved      int       `gorm:"not null;default:0"`
+	MergedBy         uint      `gorm:"not null"`
+	CreatedAt        time.Time `gorm:"autoCreateTime"`
+	Customer         Customer  `gorm:"constraint:OnDelete:CASCADE"`
+}
@@ -3,10 +3,31 @@ package models
 import "time"
 
 type Expense struct {
-	ID          uint      `gorm:"primaryKey"`
-	BranchID    uint      `gorm:"not null"`
-	Description string    `gorm:"type:text;not null"`
-	Amount      float64   `gorm:"type:decimal(10,2);not null"`
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	Branch      Branch    `gorm:"constraint:OnDelete:CASCADE"`
+	ID              uint       `gorm:"primaryKey"`
+	BranchID        uint       `gorm:"not null"`
+	CategoryID      *uint      `gorm:"default:null"`
+	Description     string     `gorm:"type:text;not null"`
+	Amount          float64    `gorm:"type:decimal(10,2);not null"`
+	Status          string     `gorm:"type:enum('pending','approved','rejected');default:'approved'"`
+	SubmittedBy     *uint      `gorm:"default:null"`
+	ApprovedBy      *uint      `gorm:"default:null"`
+	ApprovedAt      *time.Time `gorm:"default:null"`
+	RejectionReason string     `gorm:"type:text"`
+	// RecurringExpenseID and OccurrenceDate identify expenses generated from
+	// a template; the unique index keeps the scheduler from posting twice.
+	RecurringExpenseID *uint             `gorm:"uniqueIndex:idx_recurring_occurrence;default:null"`
+	OccurrenceDate     *time.Time        `gorm:"type:date;uniqueIndex:idx_recurring_occurrence;default:null"`
+	CreatedAt          time.Time         `gorm:"autoCreateTime"`
+	Branch             Branch            `gorm:"constraint:OnDelete:CASCADE"`
+	Category           *ExpenseCategory  `gorm:"constraint:OnDelete:SET NULL"`
+	RecurringExpense   *RecurringExpense `gorm:"constraint:OnDelete:SET NULL"`
+	Attachments        []Attachment      `gorm:"polymorphic:Owner;polymorphicValue:expense"`
+}
+
+type ExpenseCategory struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"size:100;unique;not null"`
+	AccountID *uint     `gorm:"default:null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	Account   *Account  `gorm:"constraint:OnDelete:SET NULL"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ExportMapping translates a ledger dimension into the code used by the
+// external accounting software. Type is one of "account", "branch",
+// "payment_method" or "expense_category"; SourceKey holds the ledger account
+// code, branch ID, payment method or category ID being mapped.
+type ExportMapping struct {
+	ID        uint      `gorm:"primaryKey"`
+	Type      string    `gorm:"size:30;not null;uniqueIndex:idx_export_mapping"`
+	SourceKey string    `gorm:"size:50;not null;uniqueIndex:idx_export_mapping"`
+	Code      string    `gorm:"size:50;not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// ExportProfile describes a custom CSV layout for journal exports. Columns
+// holds a JSON array of {"header": ..., "field": ...} objects and DateFormat
+// uses YYYY, MM and DD placeholders.
+type ExportProfile struct {
+	ID         uint      `gorm:"primaryKey"`
+	Name       string    `gorm:"size:100;unique;not null"`
+	Delimiter  string    `gorm:"size:1;default:','"`
+	DateFormat string    `gorm:"size:30;default:'YYYY-MM-DD'"`
+	Columns    string    `gorm:"type:text;not null"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
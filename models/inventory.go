@@ -1,9 +1,197 @@
 package models
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"laundre/alerts"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrUnknownUnit       = errors.New("unknown unit")
+)
+
+// Inventory is an item stocked at a branch. Stock caches the sum of the
+// item's stock movements and is only changed through RecordStockMovement.
+// Quantities are in BaseUnit and AverageCost is the moving-average cost of
+// one base unit. A zero ReorderPoint disables low-stock alerts for the item.
 type Inventory struct {
-	ID       uint   `gorm:"primaryKey"`
-	BranchID uint   `gorm:"not null"`
-	Name     string `gorm:"size:100;not null"`
-	Stock    int    `gorm:"not null"`
-	Branch   Branch `gorm:"constraint:OnDelete:CASCADE"`
+	ID              uint            `gorm:"primaryKey"`
+	BranchID        uint            `gorm:"not null;uniqueIndex:idx_inventory_sku;uniqueIndex:idx_inventory_barcode"`
+	Name            string          `gorm:"size:100;not null"`
+	SKU             *string         `gorm:"size:50;uniqueIndex:idx_inventory_sku;default:null"`
+	Barcode         *string         `gorm:"size:50;uniqueIndex:idx_inventory_barcode;default:null"`
+	BaseUnit        string          `gorm:"size:20;not null;default:'pcs'"`
+	Stock           float64         `gorm:"type:decimal(12,3);not null;default:0"`
+	AverageCost     float64         `gorm:"type:decimal(12,4);not null;default:0"`
+	ReorderPoint    float64         `gorm:"type:decimal(12,3);not null;default:0"`
+	ReorderQuantity float64         `gorm:"type:decimal(12,3);not null;default:0"`
+	Branch          Branch          `gorm:"constraint:OnDelete:CASCADE"`
+	Units           []InventoryUnit `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// InventoryUnit is a pack size an item is bought or counted in, such as a
+// jerrycan holding 5 L. Factor is the number of base units in one pack.
+type InventoryUnit struct {
+	ID          uint    `gorm:"primaryKey"`
+	InventoryID uint    `gorm:"not null;uniqueIndex:idx_inventory_unit"`
+	Name        string  `gorm:"size:20;not null;uniqueIndex:idx_inventory_unit"`
+	Factor      float64 `gorm:"type:decimal(12,3);not null"`
+}
+
+// Value is the stock valued at average cost.
+func (i Inventory) Value() float64 {
+	return math.Round(i.Stock*i.AverageCost*100) / 100
+}
+
+// UnitFactor returns how many base units one of the named unit holds. The
+// base unit itself and an empty name both count as 1.
+func UnitFactor(tx *gorm.DB, inventory Inventory, unit string) (float64, error) {
+	if unit == "" || unit == inventory.BaseUnit {
+		return 1, nil
+	}
+
+	var inventoryUnit InventoryUnit
+	if err := tx.Where("inventory_id = ? AND name = ?", inventory.ID, unit).First(&inventoryUnit).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, ErrUnknownUnit
+		}
+		return 0, err
+	}
+	return inventoryUnit.Factor, nil
+}
+
+// LowStock reports whether the item is at or below its reorder point.
+func (i Inventory) LowStock() bool {
+	return i.ReorderPoint > 0 && i.Stock <= i.ReorderPoint
+}
+
+// SuggestedOrderQuantity is the configured reorder quantity, or when none is
+// set, the amount needed to bring stock back up to the reorder point.
+func (i Inventory) SuggestedOrderQuantity() float64 {
+	if i.ReorderQuantity > 0 {
+		return i.ReorderQuantity
+	}
+	return math.Max(math.Round((i.ReorderPoint-i.Stock)*1000)/1000, 0)
+}
+
+// StockMovement is one entry in an item's stock ledger. Quantity is signed:
+// positive movements add stock, negative ones remove it. UnitCost is the cost
+// of one base unit the movement was valued at.
+type StockMovement struct {
+	ID            uint      `gorm:"primaryKey"`
+	InventoryID   uint      `gorm:"not null;index"`
+	BranchID      uint      `gorm:"not null;index"`
+	Type          string    `gorm:"type:enum('purchase','consumption','adjustment','transfer','waste');not null"`
+	Quantity      float64   `gorm:"type:decimal(12,3);not null"`
+	BalanceAfter  float64   `gorm:"type:decimal(12,3);not null"`
+	UnitCost      float64   `gorm:"type:decimal(12,4);not null;default:0"`
+	Reason        string    `gorm:"type:text"`
+	UserID        *uint     `gorm:"default:null"`
+	ReferenceType string    `gorm:"size:30;index:idx_stock_reference"`
+	ReferenceID   *uint     `gorm:"index:idx_stock_reference;default:null"`
+	CreatedAt     time.Time `gorm:"autoCreateTime;index"`
+	Inventory     Inventory `gorm:"constraint:OnDelete:CASCADE"`
+	User          *User     `gorm:"constraint:OnDelete:SET NULL"`
+}
+
+// RecordStockMovement appends a movement to the item's ledger and updates
+// the cached balance. The item row is locked for the duration of the
+// surrounding transaction so concurrent movements cannot lose updates.
+//
+// Incoming movements that carry a UnitCost are blended into the item's
+// moving-average cost; every other movement is valued at the current average.
+// The cost of consumption, waste and stock take variances is posted to the
+// ledger along with the movement.
+//
+// Record movements inside StockTransaction, so the low stock alert for an
+// item dropping below its reorder point is only sent once they commit.
+func RecordStockMovement(tx *gorm.DB, movement *StockMovement) error {
+	return recordStockMovement(tx, movement, false)
+}
+
+func recordStockMovement(tx *gorm.DB, movement *StockMovement, allowNegative bool) error {
+	var inventory Inventory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inventory, movement.InventoryID).Error; err != nil {
+		return err
+	}
+
+	balance := math.Round((inventory.Stock+movement.Quantity)*1000) / 1000
+	if balance < 0 && !allowNegative {
+		return ErrInsufficientStock
+	}
+
+	averageCost := inventory.AverageCost
+	if movement.Quantity > 0 && movement.UnitCost > 0 {
+		// Stock below zero has no cost basis, so only count what is on hand.
+		onHand := math.Max(inventory.Stock, 0)
+		averageCost = math.Round((onHand*inventory.AverageCost+movement.Quantity*movement.UnitCost)/(onHand+movement.Quantity)*10000) / 10000
+	} else {
+		movement.UnitCost = averageCost
+	}
+
+	movement.BranchID = inventory.BranchID
+	movement.BalanceAfter = balance
+	if err := tx.Create(movement).Error; err != nil {
+		return err
+	}
+	if err := PostStockMovement(tx, *movement); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&inventory).Updates(map[string]interface{}{"stock": balance, "average_cost": averageCost}).Error; err != nil {
+		return err
+	}
+
+	wasLow := inventory.LowStock()
+	inventory.Stock = balance
+	if !wasLow && inventory.LowStock() {
+		if pending, ok := tx.Statement.Context.Value(stockAlertsKey{}).(*[]alerts.Alert); ok {
+			*pending = append(*pending, lowStockAlert(inventory))
+		} else {
+			alerts.Send(lowStockAlert(inventory))
+		}
+	}
+	return nil
+}
+
+type stockAlertsKey struct{}
+
+// StockTransaction runs fc in a database transaction and sends the low stock
+// alerts raised by the movements it records once the transaction commits, so
+// a rolled back movement never reports stock that was not taken.
+func StockTransaction(db *gorm.DB, fc func(tx *gorm.DB) error) error {
+	var pending []alerts.Alert
+	ctx := context.WithValue(db.Statement.Context, stockAlertsKey{}, &pending)
+	if err := db.WithContext(ctx).Transaction(fc); err != nil {
+		return err
+	}
+	for _, alert := range pending {
+		alerts.Send(alert)
+	}
+	return nil
+}
+
+func lowStockAlert(inventory Inventory) alerts.Alert {
+	return alerts.Alert{
+		Type:     "low_stock",
+		BranchID: inventory.BranchID,
+		Subject:  fmt.Sprintf("Low stock: %s", inventory.Name),
+		Message: fmt.Sprintf("%s is down to %.3f (reorder point %.3f), reorder %.3f",
+			inventory.Name, inventory.Stock, inventory.ReorderPoint, inventory.SuggestedOrderQuantity()),
+	}
+}
+
+// StockBalance derives an item's stock from its movement ledger.
+func StockBalance(tx *gorm.DB, inventoryID uint) (float64, error) {
+	var balance float64
+	err := tx.Model(&StockMovement{}).Where("inventory_id = ?", inventoryID).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&balance).Error
+	return balance, err
 }
@@ -0,0 +1,376 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AccountCash              = "1000"
+	AccountReceivable        = "1100"
+	AccountInventory         = "1200"
+	AccountInventoryTransit  = "1300"
+	AccountPayable           = "2000"
+	AccountCustomerDeposits  = "2100"
+	AccountOwnerEquity       = "3000"
+	AccountRetainedEarnings  = "3100"
+	AccountSalesRevenue      = "4000"
+	AccountSalesReturns      = "4900"
+	AccountOperatingExpenses = "5000"
+	AccountCostOfGoods       = "5100"
+)
+
+const (
+	JournalSale    = "sale"
+	JournalPayment = "payment"
+	JournalRefund  = "refund"
+	JournalExpense = "expense"
+	// JournalComplaint entries settle a complaint with a refund or discount.
+	JournalComplaint = "complaint"
+	// JournalConsumption entries expense the stock used on orders, and
+	// JournalStockLoss entries stock that was wasted, adjusted or found short
+	// in a stock take.
+	JournalConsumption = "consumption"
+	JournalStockLoss   = "stock_loss"
+	// JournalStockPurchase entries add bought stock to inventory and
+	// JournalOpeningStock entries the stock an item started with.
+	JournalStockPurchase = "stock_purchase"
+	JournalOpeningStock  = "opening_stock"
+	// JournalStockTransfer entries move stock between a branch's inventory
+	// and inventory in transit, and JournalTransferDiscrepancy entries write
+	// off what went missing, or was found extra, on the way.
+	JournalStockTransfer       = "stock_transfer"
+	JournalTransferDiscrepancy = "transfer_discrepancy"
+)
+
+var ErrUnbalancedEntry = errors.New("journal entry is not balanced")
+
+type Account struct {
+	ID        uint      `gorm:"primaryKey"`
+	Code      string    `gorm:"size:20;uniqueIndex;not null"`
+	Name      string    `gorm:"size:100;not null"`
+	Type      string    `gorm:"type:enum('asset','liability','equity','revenue','expense');not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// DebitNormal reports whether the account's balance grows with debits.
+func (a Account) DebitNormal() bool {
+	return a.Type == "asset" || a.Type == "expense"
+}
+
+var DefaultAccounts = []Account{
+	{Code: AccountCash, Name: "Cash", Type: "asset"},
+	{Code: AccountReceivable, Name: "Accounts Receivable", Type: "asset"},
+	{Code: AccountInventory, Name: "Inventory", Type: "asset"},
+	{Code: AccountInventoryTransit, Name: "Inventory in Transit", Type: "asset"},
+	{Code: AccountPayable, Name: "Accounts Payable", Type: "liability"},
+	{Code: AccountCustomerDeposits, Name: "Customer Deposits", Type: "liability"},
+	{Code: AccountOwnerEquity, Name: "Owner's Equity", Type: "equity"},
+	{Code: AccountRetainedEarnings, Name: "Retained Earnings", Type: "equity"},
+	{Code: AccountSalesRevenue, Name: "Laundry Revenue", Type: "revenue"},
+	{Code: AccountSalesReturns, Name: "Sales Returns and Allowances", Type: "revenue"},
+	{Code: AccountOperatingExpenses, Name: "Operating Expenses", Type: "expense"},
+	{Code: AccountCostOfGoods, Name: "Cost of Goods Consumed", Type: "expense"},
+}
+
+type JournalEntry struct {
+	ID                uint          `gorm:"primaryKey"`
+	BranchID          uint          `gorm:"not null;index"`
+	Date              time.Time     `gorm:"not null;index"`
+	Description       string        `gorm:"type:text;not null"`
+	SourceType        string        `gorm:"size:30;not null;index:idx_journal_source"`
+	SourceID          uint          `gorm:"not null;index:idx_journal_source"`
+	PaymentMethod     string        `gorm:"size:20"`
+	ExpenseCategoryID *uint         `gorm:"default:null"`
+	ReversalOfID      *uint         `gorm:"default:null"`
+	Reversed          bool          `gorm:"default:false"`
+	CreatedAt         time.Time     `gorm:"autoCreateTime"`
+	Lines             []JournalLine `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type JournalLine struct {
+	ID             uint    `gorm:"primaryKey"`
+	JournalEntryID uint    `gorm:"not null;index"`
+	AccountID      uint    `gorm:"not null;index"`
+	Debit          float64 `gorm:"type:decimal(12,2);not null;default:0"`
+	Credit         float64 `gorm:"type:decimal(12,2);not null;default:0"`
+	Account        Account `gorm:"constraint:OnDelete:RESTRICT"`
+}
+
+// Posting is one side of a journal entry, addressed by account code.
+type Posting struct {
+	AccountCode string
+	Debit       float64
+	Credit      float64
+}
+
+// PostJournalEntry resolves the postings to accounts and stores the entry
+// with its lines. Entries whose debits and credits differ are rejected;
+// entries that net to zero are silently skipped.
+func PostJournalEntry(tx *gorm.DB, entry *JournalEntry, postings ...Posting) error {
+	var debit, credit float64
+	for _, p := range postings {
+		if p.Debit < 0 || p.Credit < 0 {
+			return fmt.Errorf("negative amount posted to account %s", p.AccountCode)
+		}
+		debit += p.Debit
+		credit += p.Credit
+	}
+
+	if math.Round(debit*100) != math.Round(credit*100) {
+		return ErrUnbalancedEntry
+	}
+	if math.Round(debit*100) == 0 {
+		return nil
+	}
+
+	if entry.Date.IsZero() {
+		entry.Date = time.Now()
+	}
+
+	for _, p := range postings {
+		if p.Debit == 0 && p.Credit == 0 {
+			continue
+		}
+
+		var account Account
+		if err := tx.Where("code = ?", p.AccountCode).First(&account).Error; err != nil {
+			return fmt.Errorf("account %s: %w", p.AccountCode, err)
+		}
+
+		entry.Lines = append(entry.Lines, JournalLine{
+			AccountID: account.ID,
+			Debit:     p.Debit,
+			Credit:    p.Credit,
+		})
+	}
+
+	return tx.Create(entry).Error
+}
+
+// ReverseJournalEntries posts a mirror entry for every active entry of the
+// given source and marks the originals as reversed. Reversals carry the date
+// of the entry they cancel so corrections net out within the same period.
+func ReverseJournalEntries(tx *gorm.DB, sourceID uint, description string, sourceTypes ...string) error {
+	var entries []JournalEntry
+	if err := tx.Preload("Lines").
+		Where("source_type IN ? AND source_id = ? AND reversed = ? AND reversal_of_id IS NULL", sourceTypes, sourceID, false).
+		Find(&entries).Error; err != nil {
+		return err
+	}
+
+	for _, original := range entries {
+		reversal := JournalEntry{
+			BranchID:          original.BranchID,
+			Date:              original.Date,
+			Description:       description,
+			SourceType:        original.SourceType,
+			SourceID:          original.SourceID,
+			PaymentMethod:     original.PaymentMethod,
+			ExpenseCategoryID: original.ExpenseCategoryID,
+			ReversalOfID:      &original.ID,
+		}
+		for _, line := range original.Lines {
+			reversal.Lines = append(reversal.Lines, JournalLine{
+				AccountID: line.AccountID,
+				Debit:     line.Credit,
+				Credit:    line.Debit,
+			})
+		}
+
+		if err := tx.Create(&reversal).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&original).Update("reversed", true).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func PostSale(tx *gorm.DB, transaction Transaction) error {
+	entry := JournalEntry{
+		BranchID:    transaction.BranchID,
+		Date:        transaction.CreatedAt,
+		Description: fmt.Sprintf("Sale for transaction #%d", transaction.ID),
+		SourceType:  JournalSale,
+		SourceID:    transaction.ID,
+	}
+	return PostJournalEntry(tx, &entry,
+		Posting{AccountCode: AccountReceivable, Debit: transaction.TotalPrice},
+		Posting{AccountCode: AccountSalesRevenue, Credit: transaction.TotalPrice},
+	)
+}
+
+func PostPayment(tx *gorm.DB, transaction Transaction) error {
+	entry := JournalEntry{
+		BranchID:      transaction.BranchID,
+		Description:   fmt.Sprintf("Payment for transaction #%d", transaction.ID),
+		SourceType:    JournalPayment,
+		SourceID:      transaction.ID,
+		PaymentMethod: transaction.PaymentMethod,
+	}
+	return PostJournalEntry(tx, &entry,
+		Posting{AccountCode: AccountCash, Debit: transaction.TotalPrice},
+		Posting{AccountCode: AccountReceivable, Credit: transaction.TotalPrice},
+	)
+}
+
+func PostRefund(tx *gorm.DB, transaction Transaction) error {
+	entry := JournalEntry{
+		BranchID:      transaction.BranchID,
+		Description:   fmt.Sprintf("Refund for transaction #%d", transaction.ID),
+		SourceType:    JournalRefund,
+		SourceID:      transaction.ID,
+		PaymentMethod: transaction.PaymentMethod,
+	}
+	return PostJournalEntry(tx, &entry,
+		Posting{AccountCode: AccountReceivable, Debit: transaction.TotalPrice},
+		Posting{AccountCode: AccountCash, Credit: transaction.TotalPrice},
+	)
+}
+
+// PostExpense books an approved expense against its category's account,
+// falling back to the general operating expenses account. Expenses paying
+// for a purchase order delivery settle what is owed to the supplier instead,
+// since the stock went into inventory when it was received.
+func PostExpense(tx *gorm.DB, expense Expense) error {
+	var receipts int64
+	if err := tx.Model(&PurchaseReceipt{}).Where("expense_id = ?", expense.ID).Count(&receipts).Error; err != nil {
+		return err
+	}
+
+	accountCode := AccountOperatingExpenses
+	if receipts > 0 {
+		accountCode = AccountPayable
+	} else if expense.CategoryID != nil {
+		var category ExpenseCategory
+		if err := tx.Preload("Account").First(&category, *expense.CategoryID).Error; err != nil {
+			return err
+		}
+		if category.Account != nil {
+			accountCode = category.Account.Code
+		}
+	}
+
+	entry := JournalEntry{
+		BranchID:          expense.BranchID,
+		Date:              expense.CreatedAt,
+		Description:       expense.Description,
+		SourceType:        JournalExpense,
+		SourceID:          expense.ID,
+		ExpenseCategoryID: expense.CategoryID,
+	}
+	return PostJournalEntry(tx, &entry,
+		Posting{AccountCode: accountCode, Debit: expense.Amount},
+		Posting{AccountCode: AccountCash, Credit: expense.Amount},
+	)
+}
+
+// PostComplaintSettlement books a refund or discount given to settle a
+// complaint against sales returns. Refunds are paid out of cash; discounts
+// reduce the amount still receivable on the order.
+func PostComplaintSettlement(tx *gorm.DB, complaint Complaint, creditAccount, paymentMethod string) error {
+	entry := JournalEntry{
+		BranchID:      complaint.BranchID,
+		Description:   fmt.Sprintf("Settlement of complaint #%d on order #%d", complaint.ID, complaint.OrderID),
+		SourceType:    JournalComplaint,
+		SourceID:      complaint.ID,
+		PaymentMethod: paymentMethod,
+	}
+	return PostJournalEntry(tx, &entry,
+		Posting{AccountCode: AccountSalesReturns, Debit: complaint.CompensationAmount},
+		Posting{AccountCode: creditAccount, Credit: complaint.CompensationAmount},
+	)
+}
+
+// PostStockMovement books the cost of a stock movement against inventory.
+// Consumed, wasted and adjusted stock moves between inventory and cost of
+// goods consumed, and stock coming back, such as consumption reversed for a
+// cancelled order or a stock take surplus, is credited back. Purchase order
+// deliveries are owed to the supplier until their expense is approved, other
+// purchases are paid in cash, opening balances are funded by the owner and
+// transfers pass through inventory in transit.
+func PostStockMovement(tx *gorm.DB, movement StockMovement) error {
+	description := movement.Reason
+	if description == "" {
+		description = fmt.Sprintf("Stock movement #%d", movement.ID)
+	}
+	entry := JournalEntry{
+		BranchID:    movement.BranchID,
+		Date:        movement.CreatedAt,
+		Description: description,
+		SourceID:    movement.ID,
+	}
+
+	cost := math.Round(-movement.Quantity*movement.UnitCost*100) / 100
+	switch {
+	case movement.Type == "consumption":
+		entry.SourceType = JournalConsumption
+	case movement.Type == "waste":
+		entry.SourceType = JournalStockLoss
+	case movement.Type == "adjustment" && movement.ReferenceType == "opening_balance":
+		entry.SourceType = JournalOpeningStock
+		return postStockAgainst(tx, &entry, AccountOwnerEquity, cost)
+	case movement.Type == "adjustment":
+		entry.SourceType = JournalStockLoss
+	case movement.Type == "purchase" && movement.ReferenceType == "purchase_receipt":
+		entry.SourceType = JournalStockPurchase
+		return postStockAgainst(tx, &entry, AccountPayable, cost)
+	case movement.Type == "purchase":
+		entry.SourceType = JournalStockPurchase
+		return postStockAgainst(tx, &entry, AccountCash, cost)
+	case movement.Type == "transfer":
+		entry.SourceType = JournalStockTransfer
+		return postStockAgainst(tx, &entry, AccountInventoryTransit, cost)
+	default:
+		return nil
+	}
+	return postStockAgainst(tx, &entry, AccountCostOfGoods, cost)
+}
+
+// postStockAgainst credits account for stock added to inventory and debits
+// it for stock taken out; cost is negative when stock is added.
+func postStockAgainst(tx *gorm.DB, entry *JournalEntry, account string, cost float64) error {
+	if cost < 0 {
+		return PostJournalEntry(tx, entry,
+			Posting{AccountCode: AccountInventory, Debit: -cost},
+			Posting{AccountCode: account, Credit: -cost},
+		)
+	}
+	return PostJournalEntry(tx, entry,
+		Posting{AccountCode: account, Debit: cost},
+		Posting{AccountCode: AccountInventory, Credit: cost},
+	)
+}
+
+// PostTransferDiscrepancy clears what a received transfer item left in
+// transit: stock that never arrived is a loss to the receiving branch and
+// stock that arrived on top of what was sent is a gain.
+func PostTransferDiscrepancy(tx *gorm.DB, transfer StockTransfer, item StockTransferItem) error {
+	entry := JournalEntry{
+		BranchID:    transfer.ToBranchID,
+		Description: fmt.Sprintf("Transfer #%d discrepancy: %s", transfer.ID, item.DiscrepancyReason),
+		SourceType:  JournalTransferDiscrepancy,
+		SourceID:    item.ID,
+	}
+	if transfer.ReceivedAt != nil {
+		entry.Date = *transfer.ReceivedAt
+	}
+	cost := math.Round(item.Discrepancy*item.UnitCost*100) / 100
+	if cost < 0 {
+		return PostJournalEntry(tx, &entry,
+			Posting{AccountCode: AccountCostOfGoods, Debit: -cost},
+			Posting{AccountCode: AccountInventoryTransit, Credit: -cost},
+		)
+	}
+	return PostJournalEntry(tx, &entry,
+		Posting{AccountCode: AccountInventoryTransit, Debit: cost},
+		Posting{AccountCode: AccountCostOfGoods, Credit: cost},
+	)
+}
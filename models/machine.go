@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Machine is a washer, dryer or other piece of equipment at a branch. Only
+// active machines take loads; maintenance and broken are set while a
+// downtime is open.
+type Machine struct {
+	ID           uint      `gorm:"primaryKey"`
+	BranchID     uint      `gorm:"not null;index"`
+	Name         string    `gorm:"size:50;not null"`
+	Type         string    `gorm:"type:enum('washer','dryer','ironer','other');not null"`
+	CapacityKg   float64   `gorm:"type:decimal(8,2);not null"`
+	SerialNumber string    `gorm:"size:50"`
+	Status       string    `gorm:"type:enum('active','maintenance','broken','retired');default:'active'"`
+	Notes        string    `gorm:"type:text"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	Branch       Branch    `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// MachineLoad is one cycle of a machine, holding one or more orders. A
+// machine runs at most one load at a time.
+type MachineLoad struct {
+	ID         uint       `gorm:"primaryKey"`
+	MachineID  uint       `gorm:"not null;index"`
+	BranchID   uint       `gorm:"not null;index"`
+	Status     string     `gorm:"type:enum('planned','running','finished','cancelled');default:'planned'"`
+	Weight     float64    `gorm:"type:decimal(8,2);not null;default:0"`
+	StartedAt  *time.Time `gorm:"default:null;index"`
+	FinishedAt *time.Time `gorm:"default:null"`
+	CreatedBy  uint       `gorm:"not null"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	Machine    Machine    `gorm:"constraint:OnDelete:CASCADE"`
+	Orders     []Order    `gorm:"many2many:machine_load_orders"`
+}
+
+// FillRate is the load weight as a percentage of the machine capacity.
+func (l MachineLoad) FillRate(capacity float64) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return l.Weight / capacity * 100
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaintenancePlan schedules recurring upkeep for a machine every
+// IntervalCycles finished loads and/or every IntervalDays days, whichever
+// comes first. A zero interval disables that trigger. Both are counted from
+// the last completed task, or from when the plan was created.
+type MaintenancePlan struct {
+	ID              uint       `gorm:"primaryKey"`
+	MachineID       uint       `gorm:"not null;index"`
+	Name            string     `gorm:"size:100;not null"`
+	Description     string     `gorm:"type:text"`
+	IntervalCycles  int        `gorm:"default:0"`
+	IntervalDays    int        `gorm:"default:0"`
+	Active          bool       `gorm:"not null"`
+	BaselineAt      time.Time  `gorm:"not null"`
+	BaselineCycles  int64      `gorm:"default:0"`
+	LastCompletedAt *time.Time `gorm:"default:null"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime"`
+	Machine         Machine    `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// Due reports whether the plan needs a task given the machine's current
+// cycle count, and which trigger fired. An inactive plan is never due.
+func (p MaintenancePlan) Due(now time.Time, cycles int64) (bool, string) {
+	if !p.Active {
+		return false, ""
+	}
+	if p.IntervalCycles > 0 && cycles-p.BaselineCycles >= int64(p.IntervalCycles) {
+		return true, "cycles"
+	}
+	if p.IntervalDays > 0 && !now.Before(p.BaselineAt.AddDate(0, 0, p.IntervalDays)) {
+		return true, "days"
+	}
+	return false, ""
+}
+
+// MaintenanceTask is a piece of maintenance work on a machine, generated from
+// a plan or logged by hand for repairs.
+type MaintenanceTask struct {
+	ID          uint             `gorm:"primaryKey"`
+	PlanID      *uint            `gorm:"default:null;index"`
+	MachineID   uint             `gorm:"not null;index"`
+	BranchID    uint             `gorm:"not null;index"`
+	Title       string           `gorm:"size:150;not null"`
+	Trigger     string           `gorm:"type:enum('cycles','days','manual');default:'manual'"`
+	Status      string           `gorm:"type:enum('open','completed','cancelled');default:'open'"`
+	DueDate     time.Time        `gorm:"type:date;not null"`
+	Notes       string           `gorm:"type:text"`
+	Cost        float64          `gorm:"type:decimal(10,2);default:0"`
+	ExpenseID   *uint            `gorm:"default:null"`
+	CompletedBy *uint            `gorm:"default:null"`
+	CompletedAt *time.Time       `gorm:"default:null"`
+	CreatedAt   time.Time        `gorm:"autoCreateTime"`
+	Plan        *MaintenancePlan `gorm:"constraint:OnDelete:SET NULL"`
+	Machine     Machine          `gorm:"constraint:OnDelete:CASCADE"`
+	Expense     *Expense         `gorm:"constraint:OnDelete:SET NULL"`
+}
+
+// MachineDowntime is a period a machine is out of service. EndedAt is nil
+// while the machine is still down.
+type MachineDowntime struct {
+	ID         uint       `gorm:"primaryKey"`
+	MachineID  uint       `gorm:"not null;index"`
+	Type       string     `gorm:"type:enum('breakdown','maintenance');not null"`
+	Reason     string     `gorm:"type:text;not null"`
+	TaskID     *uint      `gorm:"default:null"`
+	StartedAt  time.Time  `gorm:"not null"`
+	EndedAt    *time.Time `gorm:"default:null"`
+	ReportedBy uint       `gorm:"not null"`
+	Machine    Machine    `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// MachineCycles counts the loads a machine has finished.
+func MachineCycles(tx *gorm.DB, machineID uint) (int64, error) {
+	var cycles int64
+	err := tx.Model(&MachineLoad{}).Where("machine_id = ? AND status = ?", machineID, "finished").Count(&cycles).Error
+	return cycles, err
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenancePlanDue(t *testing.T) {
+	baseline := time.Date(2024, time.March, 1, 8, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name        string
+		plan        MaintenancePlan
+		now         time.Time
+		cycles      int64
+		wantDue     bool
+		wantTrigger string
+	}{
+		{
+			name:   "not yet due",
+			plan:   MaintenancePlan{Active: true, IntervalCycles: 100, IntervalDays: 30, BaselineAt: baseline, BaselineCycles: 50},
+			now:    baseline.AddDate(0, 0, 29),
+			cycles: 149,
+		},
+		{
+			name:        "cycle interval reached",
+			plan:        MaintenancePlan{Active: true, IntervalCycles: 100, IntervalDays: 30, BaselineAt: baseline, BaselineCycles: 50},
+			now:         baseline.AddDate(0, 0, 1),
+			cycles:      150,
+			wantDue:     true,
+			wantTrigger: "cycles",
+		},
+		{
+			name:        "day interval reached",
+			plan:        MaintenancePlan{Active: true, IntervalCycles: 100, IntervalDays: 30, BaselineAt: baseline, BaselineCycles: 50},
+			now:         baseline.AddDate(0, 0, 30),
+			cycles:      60,
+			wantDue:     true,
+			wantTrigger: "days",
+		},
+		{
+			name:   "zero intervals never trigger",
+			plan:   MaintenancePlan{Active: true, BaselineAt: baseline},
+			now:    baseline.AddDate(1, 0, 0),
+			cycles: 10000,
+		},
+		{
+			name:   "inactive plans are never due",
+			plan:   MaintenancePlan{Active: false, IntervalCycles: 100, IntervalDays: 30, BaselineAt: baseline},
+			now:    baseline.AddDate(0, 0, 60),
+			cycles: 500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, trigger := tt.plan.Due(tt.now, tt.cycles)
+			if due != tt.wantDue || trigger != tt.wantTrigger {
+				t.Fatalf("Due() = %v, %q, want %v, %q", due, trigger, tt.wantDue, tt.wantTrigger)
+			}
+		})
+	}
+}
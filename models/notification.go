@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Notification is a message to a customer about one of their orders. It is
+// queued as pending and delivered by the notification worker, which retries
+// failed attempts with a growing delay. Messages to customers who opted out
+// are kept as skipped so staff can see why nothing was sent.
+type Notification struct {
+	ID                uint       `gorm:"primaryKey"`
+	BranchID          uint       `gorm:"not null;index"`
+	CustomerID        uint       `gorm:"not null;index"`
+	OrderID           *uint      `gorm:"default:null;index"`
+	Event             string     `gorm:"size:30;not null"`
+	Channel           string     `gorm:"type:enum('whatsapp','sms');not null"`
+	Language          string     `gorm:"type:enum('id','en');not null"`
+	Recipient         string     `gorm:"size:20;not null"`
+	Body              string     `gorm:"type:text;not null"`
+	Status            string     `gorm:"type:enum('pending','sent','delivered','read','failed','skipped');default:'pending';index"`
+	Attempts          int        `gorm:"default:0"`
+	LastError         string     `gorm:"type:text"`
+	NextAttemptAt     *time.Time `gorm:"default:null;index"`
+	ProviderMessageID string     `gorm:"size:100;index"`
+	SentAt            *time.Time `gorm:"default:null"`
+	DeliveredAt       *time.Time `gorm:"default:null"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime"`
+	Branch            Branch     `gorm:"constraint:OnDelete:CASCADE"`
+	Customer          Customer   `gorm:"constraint:OnDelete:CASCADE"`
+	Order             *Order     `gorm:"constraint:OnDelete:SET NULL"`
+}
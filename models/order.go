@@ -1,15 +1,81 @@
 package models
 
-import "time"
+import (
+	"crypto/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Order struct {
-	ID         uint      `gorm:"primaryKey"`
-	BranchID   uint      `gorm:"not null"`
-	CustomerID uint      `gorm:"not null"`
-	Status     string    `gorm:"type:enum('masuk','proses','urgent', 'done');default:'masuk'"`
-	CreatedAt  time.Time `gorm:"autoCreateTime"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
-	Price      float64   `gorm:"type:decimal(10,2);not null"`
-	Branch     Branch    `gorm:"constraint:OnDelete:CASCADE"`
-	Customer   Customer  `gorm:"constraint:OnDelete:CASCADE"`
+	ID         uint    `gorm:"primaryKey"`
+	BranchID   uint    `gorm:"not null"`
+	CustomerID uint    `gorm:"not null"`
+	Status     string  `gorm:"type:enum('masuk','proses','urgent','done','cancelled');default:'masuk'"`
+	Service    string  `gorm:"size:50"`
+	Weight     float64 `gorm:"type:decimal(8,2);default:0"`
+	// TrackingCode is the short code printed on the receipt that customers
+	// and staff use to look the order up.
+	TrackingCode *string `gorm:"size:12;uniqueIndex"`
+	// Instructions holds the customer's preferences when the order was taken,
+	// so the ticket does not change if they are edited later.
+	Instructions string `gorm:"size:500"`
+	// PickupAddressID and DeliveryAddressID pick entries from the customer's
+	// address book for collecting and returning the laundry.
+	PickupAddressID   *uint `gorm:"default:null"`
+	DeliveryAddressID *uint `gorm:"default:null"`
+	// ConsumablesDeducted records whether the service recipe has already been
+	// taken out of stock for this order.
+	ConsumablesDeducted bool `gorm:"default:false"`
+	// CompletedAt is when the order was last marked done; abandoned laundry
+	// is counted from it.
+	CompletedAt *time.Time `gorm:"default:null"`
+	// PickedUpAt is set once the customer has collected the order after the
+	// item checklist was confirmed.
+	PickedUpAt *time.Time `gorm:"default:null"`
+	PickedUpBy *uint      `gorm:"default:null"`
+	// DisposedAt closes an order that was never collected; the outcome is
+	// kept in its OrderDisposition.
+	DisposedAt *time.Time `gorm:"default:null"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime"`
+	Price      float64    `gorm:"type:decimal(10,2);not null"`
+	Branch     Branch     `gorm:"constraint:OnDelete:CASCADE"`
+	Customer   Customer   `gorm:"constraint:OnDelete:CASCADE"`
+
+	PickupAddress   *CustomerAddress `gorm:"foreignKey:PickupAddressID;constraint:OnDelete:SET NULL"`
+	DeliveryAddress *CustomerAddress `gorm:"foreignKey:DeliveryAddressID;constraint:OnDelete:SET NULL"`
+}
+
+// DoneSince is when an order was finished. Orders completed before the
+// completion time was recorded fall back to their last update.
+func (o Order) DoneSince() time.Time {
+	if o.CompletedAt != nil {
+		return *o.CompletedAt
+	}
+	return o.UpdatedAt
+}
+
+const trackingAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewTrackingCode returns a random code such as "LDR-7KQ2MX", leaving out
+// characters that are easily misread.
+func NewTrackingCode() string {
+	random := make([]byte, 6)
+	if _, err := rand.Read(random); err != nil {
+		panic(err)
+	}
+	code := []byte("LDR-")
+	for _, b := range random {
+		code = append(code, trackingAlphabet[int(b)%len(trackingAlphabet)])
+	}
+	return string(code)
+}
+
+func (o *Order) BeforeCreate(tx *gorm.DB) error {
+	if o.TrackingCode == nil {
+		code := NewTrackingCode()
+		o.TrackingCode = &code
+	}
+	return nil
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// OrderDisposition records what happened to laundry that was never picked
+// up.
+type OrderDisposition struct {
+	ID            uint      `gorm:"primaryKey"`
+	OrderID       uint      `gorm:"not null;uniqueIndex"`
+	BranchID      uint      `gorm:"not null;index"`
+	Outcome       string    `gorm:"type:enum('disposed','donated','sold','returned');not null"`
+	Notes         string    `gorm:"type:text"`
+	DaysSinceDone int       `gorm:"not null"`
+	RecordedBy    uint      `gorm:"not null"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	Order         Order     `gorm:"constraint:OnDelete:CASCADE"`
+}
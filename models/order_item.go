@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// OrderItem is a single garment checked in with an order. Condition records
+// stains or damage found at drop-off; photos are attachments with owner type
+// "order_item".
+type OrderItem struct {
+	ID        uint         `gorm:"primaryKey"`
+	OrderID   uint         `gorm:"not null;index"`
+	Tag       string       `gorm:"size:30"`
+	Type      string       `gorm:"size:50;not null"`
+	Color     string       `gorm:"size:30"`
+	Brand     string       `gorm:"size:50"`
+	Condition string       `gorm:"type:text"`
+	Status    string       `gorm:"type:enum('received','processing','ready','picked_up','missing');default:'received'"`
+	CreatedAt time.Time    `gorm:"autoCreateTime"`
+	UpdatedAt time.Time    `gorm:"autoUpdateTime"`
+	Order     Order        `gorm:"constraint:OnDelete:CASCADE"`
+	Photos    []Attachment `gorm:"polymorphic:Owner;polymorphicValue:order_item"`
+}
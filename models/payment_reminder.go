@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// PaymentReminder records a reminder sent for an unpaid transaction. Step is
+// the position in the branch's reminder schedule, so each step is sent once.
+type PaymentReminder struct {
+	ID             uint          `gorm:"primaryKey"`
+	TransactionID  uint          `gorm:"not null;uniqueIndex:idx_reminder_step"`
+	Step           int           `gorm:"not null;uniqueIndex:idx_reminder_step"`
+	BranchID       uint          `gorm:"not null;index"`
+	CustomerID     uint          `gorm:"not null;index"`
+	DaysOverdue    int           `gorm:"not null"`
+	Amount         float64       `gorm:"type:decimal(10,2);not null"`
+	NotificationID *uint         `gorm:"default:null"`
+	SentBy         *uint         `gorm:"default:null"`
+	CreatedAt      time.Time     `gorm:"autoCreateTime"`
+	Transaction    Transaction   `gorm:"constraint:OnDelete:CASCADE"`
+	Customer       Customer      `gorm:"constraint:OnDelete:CASCADE"`
+	Notification   *Notification `gorm:"constraint:OnDelete:SET NULL"`
+}
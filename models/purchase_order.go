@@ -0,0 +1,77 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// PurchaseOrder is a restocking order placed with a supplier for one branch.
+// It moves from draft to ordered, then to partially_received or received as
+// deliveries are booked against it.
+type PurchaseOrder struct {
+	ID           uint                `gorm:"primaryKey"`
+	BranchID     uint                `gorm:"not null;index"`
+	SupplierID   uint                `gorm:"not null;index"`
+	CategoryID   *uint               `gorm:"default:null"`
+	Status       string              `gorm:"type:enum('draft','ordered','partially_received','received','cancelled');default:'draft'"`
+	Notes        string              `gorm:"type:text"`
+	ExpectedDate *time.Time          `gorm:"type:date;default:null"`
+	OrderedAt    *time.Time          `gorm:"default:null"`
+	CreatedBy    uint                `gorm:"not null"`
+	CreatedAt    time.Time           `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time           `gorm:"autoUpdateTime"`
+	Branch       Branch              `gorm:"constraint:OnDelete:CASCADE"`
+	Supplier     Supplier            `gorm:"constraint:OnDelete:RESTRICT"`
+	Category     *ExpenseCategory    `gorm:"constraint:OnDelete:SET NULL"`
+	Items        []PurchaseOrderItem `gorm:"constraint:OnDelete:CASCADE"`
+	Receipts     []PurchaseReceipt   `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// PurchaseOrderItem quantities and prices are in Unit, which holds Factor
+// base units of the inventory item.
+type PurchaseOrderItem struct {
+	ID               uint      `gorm:"primaryKey"`
+	PurchaseOrderID  uint      `gorm:"not null;index"`
+	InventoryID      uint      `gorm:"not null"`
+	Unit             string    `gorm:"size:20"`
+	Factor           float64   `gorm:"type:decimal(12,3);not null;default:1"`
+	Quantity         float64   `gorm:"type:decimal(12,3);not null"`
+	ReceivedQuantity float64   `gorm:"type:decimal(12,3);not null;default:0"`
+	UnitPrice        float64   `gorm:"type:decimal(10,2);not null"`
+	Inventory        Inventory `gorm:"constraint:OnDelete:RESTRICT"`
+}
+
+// Outstanding is the quantity still to be delivered.
+func (i PurchaseOrderItem) Outstanding() float64 {
+	return math.Max(math.Round((i.Quantity-i.ReceivedQuantity)*1000)/1000, 0)
+}
+
+// Total is the ordered value of the purchase order.
+func (p PurchaseOrder) Total() float64 {
+	var total float64
+	for _, item := range p.Items {
+		total += item.Quantity * item.UnitPrice
+	}
+	return math.Round(total*100) / 100
+}
+
+// PurchaseReceipt records one delivery against a purchase order together
+// with the expense it was booked as.
+type PurchaseReceipt struct {
+	ID              uint                  `gorm:"primaryKey"`
+	PurchaseOrderID uint                  `gorm:"not null;index"`
+	ExpenseID       *uint                 `gorm:"default:null"`
+	Notes           string                `gorm:"type:text"`
+	ReceivedBy      uint                  `gorm:"not null"`
+	CreatedAt       time.Time             `gorm:"autoCreateTime"`
+	Expense         *Expense              `gorm:"constraint:OnDelete:SET NULL"`
+	Items           []PurchaseReceiptItem `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type PurchaseReceiptItem struct {
+	ID                  uint    `gorm:"primaryKey"`
+	PurchaseReceiptID   uint    `gorm:"not null;index"`
+	PurchaseOrderItemID uint    `gorm:"not null"`
+	Quantity            float64 `gorm:"type:decimal(12,3);not null"`
+	UnitPrice           float64 `gorm:"type:decimal(10,2);not null"`
+}
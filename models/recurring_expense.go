@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// RecurringExpense is a template the scheduler turns into Expense rows, such
+// as monthly rent or weekly wages.
+type RecurringExpense struct {
+	ID          uint             `gorm:"primaryKey"`
+	BranchID    uint             `gorm:"not null"`
+	CategoryID  *uint            `gorm:"default:null"`
+	Description string           `gorm:"type:text;not null"`
+	Amount      float64          `gorm:"type:decimal(10,2);not null"`
+	Frequency   string           `gorm:"type:enum('monthly','weekly');not null"`
+	DayOfMonth  int              `gorm:"default:1"`
+	DayOfWeek   int              `gorm:"not null"`
+	StartDate   time.Time        `gorm:"type:date;not null"`
+	EndDate     *time.Time       `gorm:"type:date;default:null"`
+	Active      bool             `gorm:"not null"`
+	LastRunDate *time.Time       `gorm:"type:date;default:null"`
+	CreatedBy   uint             `gorm:"not null"`
+	CreatedAt   time.Time        `gorm:"autoCreateTime"`
+	Branch      Branch           `gorm:"constraint:OnDelete:CASCADE"`
+	Category    *ExpenseCategory `gorm:"constraint:OnDelete:SET NULL"`
+}
+
+// Occurrences lists the dates on which the template is due, after the last
+// materialized run (or from StartDate) up to and including until. Monthly
+// schedules fall back to the last day of shorter months. An inactive
+// template has none.
+func (r RecurringExpense) Occurrences(until time.Time) []time.Time {
+	if !r.Active {
+		return nil
+	}
+
+	from := dateOnly(r.StartDate)
+	if r.LastRunDate != nil {
+		from = dateOnly(*r.LastRunDate).AddDate(0, 0, 1)
+	}
+
+	until = dateOnly(until)
+	if r.EndDate != nil && r.EndDate.Before(until) {
+		until = dateOnly(*r.EndDate)
+	}
+
+	var dates []time.Time
+	switch r.Frequency {
+	case "monthly":
+		month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+		for !month.After(until) {
+			day := r.DayOfMonth
+			if last := month.AddDate(0, 1, -1).Day(); day > last {
+				day = last
+			}
+			date := month.AddDate(0, 0, day-1)
+			if !date.Before(from) && !date.After(until) {
+				dates = append(dates, date)
+			}
+			month = month.AddDate(0, 1, 0)
+		}
+	case "weekly":
+		offset := (r.DayOfWeek - int(from.Weekday()) + 7) % 7
+		for date := from.AddDate(0, 0, offset); !date.After(until); date = date.AddDate(0, 0, 7) {
+			dates = append(dates, date)
+		}
+	}
+
+	return dates
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestRecurringExpenseOccurrences(t *testing.T) {
+	lastRun := date(2024, time.March, 31)
+	endDate := date(2024, time.February, 15)
+
+	tests := []struct {
+		name     string
+		template RecurringExpense
+		until    time.Time
+		want     []time.Time
+	}{
+		{
+			name:     "monthly on the 31st falls back in short months",
+			template: RecurringExpense{Active: true, Frequency: "monthly", DayOfMonth: 31, StartDate: date(2024, time.January, 1)},
+			until:    date(2024, time.April, 30),
+			want:     []time.Time{date(2024, time.January, 31), date(2024, time.February, 29), date(2024, time.March, 31), date(2024, time.April, 30)},
+		},
+		{
+			name:     "monthly on the 31st in a non leap year",
+			template: RecurringExpense{Active: true, Frequency: "monthly", DayOfMonth: 31, StartDate: date(2023, time.February, 1)},
+			until:    date(2023, time.February, 28),
+			want:     []time.Time{date(2023, time.February, 28)},
+		},
+		{
+			name:     "monthly resumes after the last run",
+			template: RecurringExpense{Active: true, Frequency: "monthly", DayOfMonth: 31, StartDate: date(2024, time.January, 1), LastRunDate: &lastRun},
+			until:    date(2024, time.May, 31),
+			want:     []time.Time{date(2024, time.April, 30), date(2024, time.May, 31)},
+		},
+		{
+			name:     "monthly skips a day before the start date",
+			template: RecurringExpense{Active: true, Frequency: "monthly", DayOfMonth: 5, StartDate: date(2024, time.January, 10)},
+			until:    date(2024, time.February, 10),
+			want:     []time.Time{date(2024, time.February, 5)},
+		},
+		{
+			name:     "weekly on Sunday",
+			template: RecurringExpense{Active: true, Frequency: "weekly", DayOfWeek: 0, StartDate: date(2024, time.January, 1)},
+			until:    date(2024, time.January, 21),
+			want:     []time.Time{date(2024, time.January, 7), date(2024, time.January, 14), date(2024, time.January, 21)},
+		},
+		{
+			name:     "weekly starting on the day itself",
+			template: RecurringExpense{Active: true, Frequency: "weekly", DayOfWeek: 1, StartDate: date(2024, time.January, 1)},
+			until:    date(2024, time.January, 10),
+			want:     []time.Time{date(2024, time.January, 1), date(2024, time.January, 8)},
+		},
+		{
+			name:     "stops at the end date",
+			template: RecurringExpense{Active: true, Frequency: "monthly", DayOfMonth: 1, StartDate: date(2024, time.January, 1), EndDate: &endDate},
+			until:    date(2024, time.June, 1),
+			want:     []time.Time{date(2024, time.January, 1), date(2024, time.February, 1)},
+		},
+		{
+			name:     "inactive templates are never due",
+			template: RecurringExpense{Active: false, Frequency: "weekly", DayOfWeek: 1, StartDate: date(2024, time.January, 1)},
+			until:    date(2024, time.February, 1),
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.template.Occurrences(tt.until)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Occurrences() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("Occurrences() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
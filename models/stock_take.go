@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockTake is a physical count of a branch's inventory. The system
+// quantities are snapshotted from the stock ledger when the session opens
+// and taken again on approval, which posts the variances as adjustment
+// movements.
+type StockTake struct {
+	ID          uint            `gorm:"primaryKey"`
+	BranchID    uint            `gorm:"not null;index"`
+	Status      string          `gorm:"type:enum('open','submitted','approved','cancelled');default:'open'"`
+	Notes       string          `gorm:"type:text"`
+	CreatedBy   uint            `gorm:"not null"`
+	SubmittedBy *uint           `gorm:"default:null"`
+	SubmittedAt *time.Time      `gorm:"default:null"`
+	ApprovedBy  *uint           `gorm:"default:null"`
+	ApprovedAt  *time.Time      `gorm:"default:null"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime"`
+	Branch      Branch          `gorm:"constraint:OnDelete:CASCADE"`
+	Items       []StockTakeItem `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// StockTakeItem holds the count of one item. Variance is the counted minus
+// the system quantity. Approval refreshes the system quantity and variance
+// from the live stock balance and fixes UnitCost.
+type StockTakeItem struct {
+	ID              uint      `gorm:"primaryKey"`
+	StockTakeID     uint      `gorm:"not null;uniqueIndex:idx_stock_take_item"`
+	InventoryID     uint      `gorm:"not null;uniqueIndex:idx_stock_take_item"`
+	SystemQuantity  float64   `gorm:"type:decimal(12,3);not null"`
+	CountedQuantity *float64  `gorm:"type:decimal(12,3);default:null"`
+	Variance        float64   `gorm:"type:decimal(12,3);default:0"`
+	UnitCost        float64   `gorm:"type:decimal(12,4);default:0"`
+	Inventory       Inventory `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// InventoryUnitCost is the cost used to value an item: its moving-average
+// cost, falling back to the most recent purchase price for items bought
+// before costs were tracked.
+func InventoryUnitCost(tx *gorm.DB, inventoryID uint) (float64, error) {
+	var inventory Inventory
+	if err := tx.First(&inventory, inventoryID).Error; err != nil {
+		return 0, err
+	}
+	if inventory.AverageCost > 0 {
+		return inventory.AverageCost, nil
+	}
+
+	var cost sql.NullFloat64
+	err := tx.Table("purchase_receipt_items").
+		Joins("JOIN purchase_order_items ON purchase_order_items.id = purchase_receipt_items.purchase_order_item_id").
+		Where("purchase_order_items.inventory_id = ?", inventoryID).
+		Order("purchase_receipt_items.id desc").Limit(1).
+		Select("purchase_receipt_items.unit_price / purchase_order_items.factor").Scan(&cost).Error
+	return cost.Float64, err
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// StockTransfer moves inventory from one branch to another. Sending takes the
+// items out of the source branch and leaves them in transit until the
+// destination branch books what actually arrived.
+type StockTransfer struct {
+	ID           uint                `gorm:"primaryKey"`
+	FromBranchID uint                `gorm:"not null;index"`
+	ToBranchID   uint                `gorm:"not null;index"`
+	Status       string              `gorm:"type:enum('draft','in_transit','received','cancelled');default:'draft'"`
+	Notes        string              `gorm:"type:text"`
+	CreatedBy    uint                `gorm:"not null"`
+	SentBy       *uint               `gorm:"default:null"`
+	SentAt       *time.Time          `gorm:"default:null"`
+	ReceivedBy   *uint               `gorm:"default:null"`
+	ReceivedAt   *time.Time          `gorm:"default:null"`
+	CreatedAt    time.Time           `gorm:"autoCreateTime"`
+	FromBranch   Branch              `gorm:"constraint:OnDelete:CASCADE"`
+	ToBranch     Branch              `gorm:"constraint:OnDelete:CASCADE"`
+	Items        []StockTransferItem `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// StockTransferItem is one line of a transfer, in base units. Discrepancy is
+// the received quantity minus the sent quantity, so shortages are negative.
+// UnitCost is the sending branch's average cost when the items left.
+type StockTransferItem struct {
+	ID                     uint       `gorm:"primaryKey"`
+	StockTransferID        uint       `gorm:"not null;index"`
+	InventoryID            uint       `gorm:"not null"`
+	DestinationInventoryID *uint      `gorm:"default:null"`
+	Quantity               float64    `gorm:"type:decimal(12,3);not null"`
+	ReceivedQuantity       *float64   `gorm:"type:decimal(12,3);default:null"`
+	Discrepancy            float64    `gorm:"type:decimal(12,3);default:0"`
+	UnitCost               float64    `gorm:"type:decimal(12,4);default:0"`
+	DiscrepancyReason      string     `gorm:"type:text"`
+	Inventory              Inventory  `gorm:"constraint:OnDelete:RESTRICT"`
+	DestinationInventory   *Inventory `gorm:"constraint:OnDelete:SET NULL"`
+}
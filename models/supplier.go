@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type Supplier struct {
+	ID          uint      `gorm:"primaryKey"`
+	Name        string    `gorm:"size:100;not null"`
+	ContactName string    `gorm:"size:100"`
+	Phone       string    `gorm:"size:20"`
+	Email       string    `gorm:"size:100"`
+	Address     string    `gorm:"type:text"`
+	Notes       string    `gorm:"type:text"`
+	Active      bool      `gorm:"default:true"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,91 @@
+package notifications
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Message is a single outbound text to a customer's phone.
+type Message struct {
+	Channel string    `json:"channel"`
+	To      string    `json:"to"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Provider hands messages to a WhatsApp or SMS gateway. It returns the
+// gateway's message ID, which delivery receipts refer back to.
+type Provider interface {
+	Send(msg Message) (string, error)
+}
+
+var (
+	mu       sync.RWMutex
+	provider Provider = LogProvider{}
+)
+
+// SetProvider replaces the provider used by the delivery worker.
+func SetProvider(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	provider = p
+}
+
+func currentProvider() Provider {
+	mu.RLock()
+	defer mu.RUnlock()
+	return provider
+}
+
+// LogProvider writes messages to the standard logger instead of sending
+// them.
+type LogProvider struct{}
+
+func (LogProvider) Send(msg Message) (string, error) {
+	log.Printf("[%s] to %s: %s", msg.Channel, msg.To, msg.Body)
+	return "", nil
+}
+
+// OutboxProvider appends messages to a local file as JSON lines. It stands in
+// for a real gateway during development, and every message gets a local ID
+// so delivery receipts can be simulated through the webhook.
+type OutboxProvider struct {
+	path string
+	mu   sync.Mutex
+	seq  int64
+}
+
+func NewOutboxProvider(path string) *OutboxProvider {
+	return &OutboxProvider{path: path}
+}
+
+func (o *OutboxProvider) Send(msg Message) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.seq++
+	id := "local-" + time.Now().Format("20060102150405") + "-" + strconv.FormatInt(o.seq, 10)
+
+	line, err := json.Marshal(struct {
+		ID string `json:"id"`
+		Message
+	}{id, msg})
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(o.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return "", err
+	}
+	return id, nil
+}
@@ -0,0 +1,139 @@
+package notifications
+
+import (
+	"errors"
+	"laundre/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// retryDelays is how long the worker waits after each failed attempt. Once
+// they are used up the notification is marked failed.
+var retryDelays = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour}
+
+// MaxAttempts is the number of times a notification is tried before it is
+// given up on.
+var MaxAttempts = len(retryDelays) + 1
+
+// Queue renders the event's message for an order's customer and stores it
+// for the worker to send. Fields left empty in data are filled in from the
+// order; Amount is the balance still owed, so paid orders mention none.
+// Customers who opted out or have no phone number get a skipped record
+// instead.
+func Queue(tx *gorm.DB, event string, orderID uint, data Data) (models.Notification, error) {
+	var order models.Order
+	if err := tx.Preload("Customer").Preload("Branch").First(&order, orderID).Error; err != nil {
+		return models.Notification{}, err
+	}
+
+	if data.CustomerName == "" {
+		data.CustomerName = order.Customer.Name
+	}
+	if data.OrderID == 0 {
+		data.OrderID = order.ID
+	}
+	if data.Service == "" {
+		data.Service = order.Service
+	}
+	if data.Weight == 0 {
+		data.Weight = order.Weight
+	}
+	if data.BranchName == "" {
+		data.BranchName = order.Branch.Name
+	}
+	if data.BranchPhone == "" {
+		data.BranchPhone = order.Branch.Phone
+	}
+	if data.Amount == 0 {
+		var transaction models.Transaction
+		err := tx.Where("order_id = ?", order.ID).First(&transaction).Error
+		if err == nil && transaction.PaymentStatus == "unpaid" {
+			data.Amount = transaction.TotalPrice
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Notification{}, err
+		}
+	}
+
+	customer := order.Customer
+	body, err := Render(event, customer.Language, data)
+	if err != nil {
+		return models.Notification{}, err
+	}
+
+	notification := models.Notification{
+		BranchID:   order.BranchID,
+		CustomerID: customer.ID,
+		OrderID:    &order.ID,
+		Event:      event,
+		Channel:    customer.NotificationChannel,
+		Language:   customer.Language,
+		Recipient:  customer.Phone,
+		Body:       body,
+		Status:     "pending",
+	}
+	if notification.Channel == "" {
+		notification.Channel = "whatsapp"
+	}
+	if notification.Language == "" {
+		notification.Language = "id"
+	}
+	switch {
+	case customer.NotificationsOptOut:
+		notification.Status = "skipped"
+		notification.LastError = "Customer opted out of notifications"
+	case customer.Phone == "":
+		notification.Status = "skipped"
+		notification.LastError = "Customer has no phone number"
+	}
+
+	err = tx.Omit("Branch", "Customer", "Order").Create(&notification).Error
+	return notification, err
+}
+
+// Dispatch sends the pending notifications that are due and returns how many
+// went out and how many failed for good.
+func Dispatch(db *gorm.DB, now time.Time) (int, int, error) {
+	var pending []models.Notification
+	if err := db.Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", "pending", now).
+		Order("id asc").Limit(100).Find(&pending).Error; err != nil {
+		return 0, 0, err
+	}
+
+	p := currentProvider()
+	var sent, failed int
+	for _, notification := range pending {
+		notification.Attempts++
+		messageID, err := p.Send(Message{
+			Channel: notification.Channel,
+			To:      notification.Recipient,
+			Body:    notification.Body,
+			SentAt:  now,
+		})
+
+		updates := map[string]interface{}{"attempts": notification.Attempts}
+		switch {
+		case err == nil:
+			updates["status"] = "sent"
+			updates["sent_at"] = now
+			updates["provider_message_id"] = messageID
+			updates["last_error"] = ""
+			updates["next_attempt_at"] = nil
+			sent++
+		case notification.Attempts >= MaxAttempts:
+			updates["status"] = "failed"
+			updates["last_error"] = err.Error()
+			updates["next_attempt_at"] = nil
+			failed++
+		default:
+			updates["last_error"] = err.Error()
+			updates["next_attempt_at"] = now.Add(retryDelays[notification.Attempts-1])
+		}
+
+		if err := db.Model(&notification).Updates(updates).Error; err != nil {
+			return sent, failed, err
+		}
+	}
+
+	return sent, failed, nil
+}
@@ -0,0 +1,149 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeTable is the canned result returned for every query that reads a
+// table; a nil table returns no rows.
+type fakeTable struct {
+	columns []string
+	row     []driver.Value
+}
+
+// fakeConn answers the handful of queries Queue makes from fixed rows, so
+// the tests run without a MySQL server.
+type fakeConn struct {
+	tables map[string]*fakeTable
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	for name, table := range c.tables {
+		if strings.Contains(query, "FROM `"+name+"`") {
+			if table == nil {
+				return &fakeRows{}, nil
+			}
+			return &fakeRows{columns: table.columns, rows: [][]driver.Value{table.row}}, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+func (c *fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return fakeResult{}, nil
+}
+
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) { return 1, nil }
+func (fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type fakeConnector struct{ conn *fakeConn }
+
+func (f fakeConnector) Connect(context.Context) (driver.Conn, error) { return f.conn, nil }
+func (f fakeConnector) Driver() driver.Driver                        { return nil }
+
+func openFakeDB(t *testing.T, transaction *fakeTable) *gorm.DB {
+	t.Helper()
+	conn := &fakeConn{tables: map[string]*fakeTable{
+		"orders": {
+			columns: []string{"id", "branch_id", "customer_id", "service", "weight"},
+			row:     []driver.Value{int64(7), int64(1), int64(3), "Cuci Kering", 2.5},
+		},
+		"customers": {
+			columns: []string{"id", "name", "phone", "language", "notification_channel"},
+			row:     []driver.Value{int64(3), "Sari", "6281234567890", "en", "whatsapp"},
+		},
+		"branches": {
+			columns: []string{"id", "name", "phone"},
+			row:     []driver.Value{int64(1), "Laundre Pusat", "0211234567"},
+		},
+		"transactions": transaction,
+	}}
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(fakeConnector{conn}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQueueAmount(t *testing.T) {
+	transactionColumns := []string{"id", "order_id", "total_price", "payment_status"}
+	tests := []struct {
+		name        string
+		transaction *fakeTable
+		wantAmount  bool
+	}{
+		{
+			name:        "unpaid order",
+			transaction: &fakeTable{columns: transactionColumns, row: []driver.Value{int64(9), int64(7), 45000.0, "unpaid"}},
+			wantAmount:  true,
+		},
+		{
+			name:        "paid order",
+			transaction: &fakeTable{columns: transactionColumns, row: []driver.Value{int64(9), int64(7), 45000.0, "paid"}},
+		},
+		{
+			name: "order without a transaction",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openFakeDB(t, tt.transaction)
+
+			notification, err := Queue(db, EventOrderReady, 7, Data{})
+			if err != nil {
+				t.Fatalf("Queue() error = %v", err)
+			}
+			if notification.Status != "pending" {
+				t.Errorf("Status = %q, want pending", notification.Status)
+			}
+			if !strings.Contains(notification.Body, "order #7") || !strings.Contains(notification.Body, "Laundre Pusat") {
+				t.Errorf("Body = %q, want the order and branch filled in", notification.Body)
+			}
+			if got := strings.Contains(notification.Body, "Amount due: Rp45.000"); got != tt.wantAmount {
+				t.Errorf("Body = %q, mentions amount due = %v, want %v", notification.Body, got, tt.wantAmount)
+			}
+		})
+	}
+}
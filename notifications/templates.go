@@ -0,0 +1,108 @@
+package notifications
+
+import (
+	"fmt"
+	"laundre/utils"
+	"strings"
+	"text/template"
+)
+
+const (
+	EventOrderReceived   = "order_received"
+	EventOrderReady      = "order_ready"
+	EventPaymentReminder = "payment_reminder"
+	EventDeliveryOnWay   = "delivery_on_the_way"
+	EventPickupReminder  = "pickup_reminder"
+	EventFinalNotice     = "final_notice"
+)
+
+// Data is what message templates can refer to.
+type Data struct {
+	CustomerName string
+	OrderID      uint
+	Service      string
+	Weight       float64
+	Amount       float64
+	BranchName   string
+	BranchPhone  string
+	DaysOverdue  int
+	// DaysWaiting and DisposalDate describe laundry that was not collected.
+	DaysWaiting  int
+	DisposalDate string
+}
+
+var templateText = map[string]map[string]string{
+	EventOrderReceived: {
+		"id": "Halo {{.CustomerName}}, cucian Anda sudah kami terima di {{.BranchName}} dengan nomor order #{{.OrderID}}" +
+			"{{if .Service}} ({{.Service}}{{if .Weight}}, {{printf \"%.1f\" .Weight}} kg{{end}}){{end}}. Terima kasih!",
+		"en": "Hi {{.CustomerName}}, we have received your laundry at {{.BranchName}} as order #{{.OrderID}}" +
+			"{{if .Service}} ({{.Service}}{{if .Weight}}, {{printf \"%.1f\" .Weight}} kg{{end}}){{end}}. Thank you!",
+	},
+	EventOrderReady: {
+		"id": "Halo {{.CustomerName}}, cucian Anda (order #{{.OrderID}}) sudah siap diambil di {{.BranchName}}." +
+			"{{if .Amount}} Total tagihan: Rp{{rupiah .Amount}}.{{end}}",
+		"en": "Hi {{.CustomerName}}, your laundry (order #{{.OrderID}}) is ready for pickup at {{.BranchName}}." +
+			"{{if .Amount}} Amount due: Rp{{rupiah .Amount}}.{{end}}",
+	},
+	EventPaymentReminder: {
+		"id": "Halo {{.CustomerName}}, kami mengingatkan tagihan order #{{.OrderID}} sebesar Rp{{rupiah .Amount}} belum dibayar" +
+			"{{if .DaysOverdue}} sejak {{.DaysOverdue}} hari{{end}}. Hubungi {{.BranchName}} di {{.BranchPhone}} bila ada pertanyaan.",
+		"en": "Hi {{.CustomerName}}, this is a reminder that order #{{.OrderID}} for Rp{{rupiah .Amount}} is still unpaid" +
+			"{{if .DaysOverdue}} after {{.DaysOverdue}} days{{end}}. Contact {{.BranchName}} at {{.BranchPhone}} with any questions.",
+	},
+	EventPickupReminder: {
+		"id": "Halo {{.CustomerName}}, cucian Anda (order #{{.OrderID}}) sudah {{.DaysWaiting}} hari menunggu untuk diambil di {{.BranchName}}." +
+			" Silakan ambil secepatnya. Info: {{.BranchPhone}}.",
+		"en": "Hi {{.CustomerName}}, your laundry (order #{{.OrderID}}) has been waiting for pickup at {{.BranchName}} for {{.DaysWaiting}} days." +
+			" Please collect it soon. Questions: {{.BranchPhone}}.",
+	},
+	EventFinalNotice: {
+		"id": "PEMBERITAHUAN TERAKHIR: Halo {{.CustomerName}}, cucian Anda (order #{{.OrderID}}) belum diambil selama {{.DaysWaiting}} hari." +
+			" Bila tidak diambil{{if .DisposalDate}} sebelum {{.DisposalDate}}{{end}}, cucian akan kami salurkan atau buang. Hubungi {{.BranchName}} di {{.BranchPhone}}.",
+		"en": "FINAL NOTICE: Hi {{.CustomerName}}, your laundry (order #{{.OrderID}}) has not been collected for {{.DaysWaiting}} days." +
+			" If it is not picked up{{if .DisposalDate}} by {{.DisposalDate}}{{end}}, it will be donated or disposed of. Contact {{.BranchName}} at {{.BranchPhone}}.",
+	},
+	EventDeliveryOnWay: {
+		"id": "Halo {{.CustomerName}}, cucian Anda (order #{{.OrderID}}) sedang dalam perjalanan dari {{.BranchName}}.",
+		"en": "Hi {{.CustomerName}}, your laundry (order #{{.OrderID}}) is on its way from {{.BranchName}}.",
+	},
+}
+
+var templates = parseTemplates()
+
+func parseTemplates() map[string]map[string]*template.Template {
+	funcs := template.FuncMap{"rupiah": utils.Rupiah}
+	parsed := map[string]map[string]*template.Template{}
+	for event, languages := range templateText {
+		parsed[event] = map[string]*template.Template{}
+		for language, text := range languages {
+			parsed[event][language] = template.Must(template.New(event + "." + language).Funcs(funcs).Parse(text))
+		}
+	}
+	return parsed
+}
+
+// ValidEvent reports whether there is a template for the event.
+func ValidEvent(event string) bool {
+	_, ok := templates[event]
+	return ok
+}
+
+// Render fills in the event's template in the given language, falling back
+// to Indonesian.
+func Render(event, language string, data Data) (string, error) {
+	languages, ok := templates[event]
+	if !ok {
+		return "", fmt.Errorf("unknown notification event %q", event)
+	}
+	tmpl, ok := languages[language]
+	if !ok {
+		tmpl = languages["id"]
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
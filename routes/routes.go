@@ -3,18 +3,21 @@ package routes
 import (
 	"laundre/handlers"
 	"laundre/middleware"
+	"laundre/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
+func RegisterRoutes(r *gin.Engine, db *gorm.DB, store storage.Storage) {
 
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "pong"})
 	})
 
 	r.POST("/login", handlers.Login(db))
+	r.GET("/files/:id", handlers.DownloadSignedFile(db, store))
+	r.POST("/webhooks/notifications", handlers.NotificationWebhook(db))
 
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware(db))
@@ -43,6 +46,83 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		admin.POST("/transaction/report", handlers.GetTransactionByDate(db))
 		admin.GET("/transaction/report/:branch_id", handlers.GetTransactionsByBranch(db))
 		admin.GET("/expense/branch/:branch_id", handlers.GetExpensesByBranch(db))
+		admin.GET("/expense/pending", handlers.GetPendingExpenses(db))
+		admin.POST("/expense/:id/approve", handlers.ApproveExpense(db))
+		admin.POST("/expense/:id/reject", handlers.RejectExpense(db))
+
+		admin.POST("/expense-categories", handlers.CreateExpenseCategory(db))
+		admin.PUT("/expense-categories/:id", handlers.UpdateExpenseCategory(db))
+		admin.DELETE("/expense-categories/:id", handlers.DeleteExpenseCategory(db))
+
+		admin.POST("/recurring-expenses", handlers.CreateRecurringExpense(db))
+		admin.GET("/recurring-expenses", handlers.GetRecurringExpenses(db))
+		admin.POST("/recurring-expenses/run", handlers.RunRecurringExpenses(db))
+		admin.GET("/recurring-expenses/:id", handlers.GetRecurringExpense(db))
+		admin.PUT("/recurring-expenses/:id", handlers.UpdateRecurringExpense(db))
+		admin.DELETE("/recurring-expenses/:id", handlers.DeleteRecurringExpense(db))
+
+		admin.POST("/budgets", handlers.SaveBudget(db))
+		admin.DELETE("/budgets/:id", handlers.DeleteBudget(db))
+		admin.POST("/revenue-targets", handlers.SaveRevenueTarget(db))
+
+		admin.POST("/inventory/:id/recalculate", handlers.RecalculateStock(db))
+
+		admin.POST("/suppliers", handlers.CreateSupplier(db))
+		admin.PUT("/suppliers/:id", handlers.UpdateSupplier(db))
+		admin.DELETE("/suppliers/:id", handlers.DeleteSupplier(db))
+
+		admin.POST("/stock-takes/:id/approve", handlers.ApproveStockTake(db))
+		admin.POST("/stock-takes/:id/reopen", handlers.ReopenStockTake(db))
+
+		admin.POST("/machines", handlers.CreateMachine(db))
+		admin.PUT("/machines/:id", handlers.UpdateMachine(db))
+		admin.DELETE("/machines/:id", handlers.DeleteMachine(db))
+		admin.POST("/maintenance-plans", handlers.CreateMaintenancePlan(db))
+		admin.PUT("/maintenance-plans/:id", handlers.UpdateMaintenancePlan(db))
+		admin.DELETE("/maintenance-plans/:id", handlers.DeleteMaintenancePlan(db))
+		admin.POST("/maintenance-plans/run", handlers.RunMaintenancePlans(db))
+		admin.POST("/payment-reminders/run", handlers.RunPaymentReminders(db))
+		admin.POST("/abandoned-orders/run", handlers.RunAbandonedLaundry(db))
+		admin.POST("/maintenance-tasks/:id/cancel", handlers.CancelMaintenanceTask(db))
+
+		admin.POST("/corporate-accounts", handlers.CreateCorporateAccount(db))
+		admin.GET("/corporate-accounts", handlers.GetCorporateAccounts(db))
+		admin.GET("/corporate-accounts/:id", handlers.GetCorporateAccount(db))
+		admin.PUT("/corporate-accounts/:id", handlers.UpdateCorporateAccount(db))
+		admin.DELETE("/corporate-accounts/:id", handlers.DeleteCorporateAccount(db))
+		admin.PUT("/corporate-accounts/:id/prices", handlers.SetContractPrices(db))
+
+		admin.POST("/invoices/generate", handlers.GenerateInvoices(db))
+		admin.GET("/invoices", handlers.GetInvoices(db))
+		admin.GET("/invoices/aging", handlers.GetInvoiceAging(db))
+		admin.GET("/invoices/:id", handlers.GetInvoice(db))
+		admin.GET("/invoices/:id/pdf", handlers.GetInvoicePDF(db))
+		admin.POST("/invoices/:id/pay", handlers.PayInvoice(db))
+		admin.POST("/invoices/:id/void", handlers.VoidInvoice(db))
+
+		admin.GET("/customers/duplicates", handlers.GetDuplicateCustomers(db))
+		admin.POST("/customers/:id/merge", handlers.MergeCustomers(db))
+		admin.GET("/customers/:id/merges", handlers.GetCustomerMerges(db))
+
+		admin.POST("/consumption-recipes", handlers.CreateConsumptionRecipe(db))
+		admin.PUT("/consumption-recipes/:id", handlers.UpdateConsumptionRecipe(db))
+		admin.DELETE("/consumption-recipes/:id", handlers.DeleteConsumptionRecipe(db))
+
+		admin.GET("/ledger/accounts", handlers.GetAccounts(db))
+		admin.POST("/ledger/accounts", handlers.CreateAccount(db))
+		admin.GET("/ledger/accounts/:id", handlers.GetAccountLedger(db))
+		admin.GET("/ledger/journal", handlers.GetJournalEntries(db))
+		admin.GET("/ledger/trial-balance", handlers.GetTrialBalance(db))
+		admin.GET("/ledger/balance-sheet", handlers.GetBalanceSheet(db))
+		admin.GET("/ledger/profit-loss", handlers.GetProfitAndLoss(db))
+
+		admin.GET("/exports/journal", handlers.ExportJournal(db))
+		admin.GET("/exports/mappings", handlers.GetExportMappings(db))
+		admin.POST("/exports/mappings", handlers.SaveExportMapping(db))
+		admin.DELETE("/exports/mappings/:id", handlers.DeleteExportMapping(db))
+		admin.GET("/exports/profiles", handlers.GetExportProfiles(db))
+		admin.POST("/exports/profiles", handlers.CreateExportProfile(db))
+		admin.DELETE("/exports/profiles/:id", handlers.DeleteExportProfile(db))
 	}
 
 	shared := api.Group("/shared")
@@ -59,26 +139,128 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		shared.GET("/orders", handlers.GetOrders(db))
 		shared.GET("/orders/:id", handlers.GetOrder(db))
 		shared.PUT("/orders/:id", handlers.UpdateOrder(db))
+		shared.PUT("/orders/:id/addresses", handlers.UpdateOrderAddresses(db))
 		shared.DELETE("/orders/:id", handlers.DeleteOrder(db))
+		shared.POST("/orders/:id/items", handlers.AddOrderItem(db))
+		shared.GET("/orders/:id/items", handlers.GetOrderItems(db))
+		shared.PUT("/orders/:id/items/:item_id", handlers.UpdateOrderItem(db))
+		shared.DELETE("/orders/:id/items/:item_id", handlers.DeleteOrderItem(db, store))
+		shared.PUT("/orders/:id/items/:item_id/status", handlers.UpdateOrderItemStatus(db))
+		shared.POST("/orders/:id/items/:item_id/photos", handlers.UploadOrderItemPhoto(db, store))
+		shared.GET("/orders/:id/items/:item_id/photos/:photo_id", handlers.GetOrderItemPhoto(db, store))
+		shared.DELETE("/orders/:id/items/:item_id/photos/:photo_id", handlers.DeleteOrderItemPhoto(db, store))
+		shared.GET("/orders/:id/pickup-check", handlers.GetPickupChecklist(db))
+		shared.POST("/orders/:id/pickup", handlers.ConfirmPickup(db))
+		shared.POST("/orders/:id/notify", handlers.SendOrderNotification(db))
+		shared.GET("/orders/abandoned", handlers.GetAbandonedOrders(db))
+		shared.POST("/orders/:id/dispose", handlers.DisposeOrder(db))
+
+		shared.GET("/payment-reminders", handlers.GetPaymentReminders(db))
+		shared.GET("/payment-reminders/overdue", handlers.GetOverdueReport(db))
+
+		shared.GET("/notifications", handlers.GetNotifications(db))
+		shared.POST("/notifications/:id/retry", handlers.RetryNotification(db))
+
+		shared.GET("/attachments/:id/url", handlers.GetAttachmentURL(db, store))
+
+		shared.POST("/complaints", handlers.CreateComplaint(db))
+		shared.GET("/complaints", handlers.GetComplaints(db))
+		shared.GET("/complaints/report", handlers.GetComplaintReport(db))
+		shared.GET("/complaints/:id", handlers.GetComplaint(db))
+		shared.PUT("/complaints/:id", handlers.UpdateComplaint(db))
+		shared.POST("/complaints/:id/resolve", handlers.ResolveComplaint(db))
+		shared.POST("/complaints/:id/reject", handlers.RejectComplaint(db))
 
 		shared.POST("/customers", handlers.CreateCustomer(db))
 		shared.GET("/customers", handlers.GetCustomers(db))
 		shared.GET("/customers/:id", handlers.GetCustomer(db))
 		shared.PUT("/customers/:id", handlers.UpdateCustomer(db))
 		shared.DELETE("/customers/:id", handlers.DeleteCustomer(db))
+		shared.PUT("/customers/:id/notification-preferences", handlers.UpdateNotificationPreferences(db))
+		shared.PUT("/customers/:id/preferences", handlers.UpdateCustomerPreferences(db))
+		shared.GET("/customers/:id/addresses", handlers.GetCustomerAddresses(db))
+		shared.POST("/customers/:id/addresses", handlers.CreateCustomerAddress(db))
+		shared.PUT("/customers/:id/addresses/:address_id", handlers.UpdateCustomerAddress(db))
+		shared.DELETE("/customers/:id/addresses/:address_id", handlers.DeleteCustomerAddress(db))
+		shared.GET("/customers/:id/contacts", handlers.GetCustomerContacts(db))
+		shared.POST("/customers/:id/contacts", handlers.CreateCustomerContact(db))
+		shared.PUT("/customers/:id/contacts/:contact_id", handlers.UpdateCustomerContact(db))
+		shared.DELETE("/customers/:id/contacts/:contact_id", handlers.DeleteCustomerContact(db))
+
+		shared.GET("/search", handlers.Search(db))
 
 		shared.POST("/inventory", handlers.CreateInventory(db))
 		shared.GET("/inventory", handlers.GetAllInventories(db))
+		shared.GET("/inventory/low-stock", handlers.GetLowStockInventories(db))
+		shared.GET("/inventory/valuation", handlers.GetStockValuation(db))
 		shared.GET("/inventory/:id", handlers.GetInventoryByID(db))
 		shared.PUT("/inventory/:id", handlers.UpdateInventory(db))
 		shared.DELETE("/inventory/:id", handlers.DeleteInventory(db))
 		shared.POST("/inventory/branch", handlers.GetInventoryByBranch(db))
+		shared.POST("/inventory/:id/movements", handlers.CreateStockMovement(db))
+		shared.GET("/inventory/:id/movements", handlers.GetStockMovements(db))
+		shared.POST("/inventory/:id/units", handlers.AddInventoryUnit(db))
+		shared.DELETE("/inventory/:id/units/:unit_id", handlers.DeleteInventoryUnit(db))
+		shared.GET("/consumption-recipes", handlers.GetConsumptionRecipes(db))
+
+		shared.GET("/suppliers", handlers.GetSuppliers(db))
+		shared.GET("/suppliers/:id", handlers.GetSupplierByID(db))
+		shared.POST("/purchase-orders", handlers.CreatePurchaseOrder(db))
+		shared.GET("/purchase-orders", handlers.GetPurchaseOrders(db))
+		shared.GET("/purchase-orders/:id", handlers.GetPurchaseOrder(db))
+		shared.PUT("/purchase-orders/:id", handlers.UpdatePurchaseOrder(db))
+		shared.DELETE("/purchase-orders/:id", handlers.DeletePurchaseOrder(db))
+		shared.POST("/purchase-orders/:id/submit", handlers.SubmitPurchaseOrder(db))
+		shared.POST("/purchase-orders/:id/cancel", handlers.CancelPurchaseOrder(db))
+		shared.POST("/purchase-orders/:id/receive", handlers.ReceivePurchaseOrder(db))
+
+		shared.POST("/stock-transfers", handlers.CreateStockTransfer(db))
+		shared.GET("/stock-transfers", handlers.GetStockTransfers(db))
+		shared.GET("/stock-transfers/:id", handlers.GetStockTransfer(db))
+		shared.POST("/stock-transfers/:id/send", handlers.SendStockTransfer(db))
+		shared.POST("/stock-transfers/:id/receive", handlers.ReceiveStockTransfer(db))
+		shared.POST("/stock-transfers/:id/cancel", handlers.CancelStockTransfer(db))
+
+		shared.POST("/stock-takes", handlers.CreateStockTake(db))
+		shared.GET("/stock-takes", handlers.GetStockTakes(db))
+		shared.GET("/stock-takes/:id", handlers.GetStockTake(db))
+		shared.PUT("/stock-takes/:id/counts", handlers.RecordStockTakeCounts(db))
+		shared.POST("/stock-takes/:id/submit", handlers.SubmitStockTake(db))
+		shared.POST("/stock-takes/:id/cancel", handlers.CancelStockTake(db))
+		shared.GET("/stock-takes/:id/variance-report", handlers.GetStockTakeVarianceReport(db))
+
+		shared.GET("/machines", handlers.GetMachines(db))
+		shared.GET("/machines/utilization", handlers.GetMachineUtilization(db))
+		shared.GET("/machines/:id", handlers.GetMachineByID(db))
+		shared.POST("/machines/:id/downtime", handlers.StartMachineDowntime(db))
+		shared.POST("/machines/:id/downtime/end", handlers.EndMachineDowntime(db))
+		shared.GET("/machines/:id/downtime", handlers.GetMachineDowntimes(db))
+		shared.GET("/maintenance-plans", handlers.GetMaintenancePlans(db))
+		shared.POST("/maintenance-tasks", handlers.CreateMaintenanceTask(db))
+		shared.GET("/maintenance-tasks", handlers.GetMaintenanceTasks(db))
+		shared.POST("/maintenance-tasks/:id/complete", handlers.CompleteMaintenanceTask(db))
+		shared.POST("/machine-loads", handlers.CreateMachineLoad(db))
+		shared.GET("/machine-loads", handlers.GetMachineLoads(db))
+		shared.GET("/machine-loads/:id", handlers.GetMachineLoad(db))
+		shared.PUT("/machine-loads/:id", handlers.UpdateMachineLoad(db))
+		shared.POST("/machine-loads/:id/start", handlers.StartMachineLoad(db))
+		shared.POST("/machine-loads/:id/finish", handlers.FinishMachineLoad(db))
+		shared.POST("/machine-loads/:id/cancel", handlers.CancelMachineLoad(db))
 
 		shared.POST("/expense", handlers.CreateExpense(db))
 		shared.GET("/expense", handlers.GetAllExpenses(db))
 		shared.GET("/expense/:id", handlers.GetExpenseByID(db))
 		shared.PUT("/expense/:id", handlers.UpdateExpense(db))
-		shared.DELETE("/expense/:id", handlers.DeleteExpense(db))
+		shared.DELETE("/expense/:id", handlers.DeleteExpense(db, store))
+		shared.POST("/expense/:id/receipts", handlers.UploadExpenseReceipt(db, store))
+		shared.GET("/expense/:id/receipts/:receipt_id", handlers.GetExpenseReceipt(db, store))
+		shared.DELETE("/expense/:id/receipts/:receipt_id", handlers.DeleteExpenseReceipt(db, store))
+
+		shared.GET("/expense-categories", handlers.GetExpenseCategories(db))
+
+		shared.GET("/budgets", handlers.GetBudgets(db))
+		shared.GET("/budgets/report", handlers.GetBudgetReport(db))
+		shared.GET("/revenue-targets", handlers.GetRevenueTargets(db))
 	}
 
 }
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"laundre/models"
+	"laundre/notifications"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RunAbandonedLaundry sends the pickup reminder and final notice to
+// customers whose finished orders are still waiting, following each
+// branch's policy. Each notice goes out once per order; an order that is
+// already past the final notice when first seen only gets the final notice.
+// Orders finished before completion times were recorded are left alone, as
+// there is no telling whether they were collected. It returns the number of
+// notices queued.
+func RunAbandonedLaundry(db *gorm.DB, now time.Time) (int, error) {
+	var orders []models.Order
+	if err := db.Preload("Branch").
+		Where("status = ? AND completed_at IS NOT NULL AND picked_up_at IS NULL AND disposed_at IS NULL", "done").
+		Find(&orders).Error; err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, order := range orders {
+		days := DaysSince(order.DoneSince(), now)
+
+		var event string
+		switch order.Branch.AbandonmentStage(days) {
+		case "reminder":
+			event = notifications.EventPickupReminder
+		case "final_notice", "disposal":
+			event = notifications.EventFinalNotice
+		default:
+			continue
+		}
+
+		var sent []string
+		if err := db.Model(&models.Notification{}).
+			Where("order_id = ? AND event IN ?", order.ID,
+				[]string{notifications.EventPickupReminder, notifications.EventFinalNotice}).
+			Pluck("event", &sent).Error; err != nil {
+			return queued, err
+		}
+		if contains(sent, event) || (event == notifications.EventPickupReminder && contains(sent, notifications.EventFinalNotice)) {
+			continue
+		}
+
+		data := notifications.Data{DaysWaiting: days}
+		if order.Branch.DisposalDays > 0 {
+			data.DisposalDate = order.DoneSince().AddDate(0, 0, order.Branch.DisposalDays).Format("02-01-2006")
+		}
+		if _, err := notifications.Queue(db, event, order.ID, data); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+
+	return queued, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
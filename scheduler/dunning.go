@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"fmt"
+	"laundre/alerts"
+	"laundre/models"
+	"laundre/notifications"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DaysSince counts whole calendar days from t to now.
+func DaysSince(t, now time.Time) int {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return int(today.Sub(start).Hours() / 24)
+}
+
+// RunPaymentReminders walks the unpaid transactions and sends the reminder
+// for the latest step of the branch's schedule that has come due. Steps that
+// were missed, e.g. while the server was down, are skipped rather than sent
+// in a burst. Corporate accounts are left out as they are billed through
+// monthly invoices. Transactions unpaid past the branch's escalation limit are
+// flagged for the overdue report and the branch is alerted. It returns how
+// many reminders were queued and how many transactions were escalated.
+func RunPaymentReminders(db *gorm.DB, now time.Time) (int, int, error) {
+	var branches []models.Branch
+	if err := db.Find(&branches).Error; err != nil {
+		return 0, 0, err
+	}
+	schedules := map[uint][]int{}
+	escalateAfter := map[uint]int{}
+	for _, branch := range branches {
+		days, err := models.ParseReminderDays(branch.ReminderDays)
+		if err != nil {
+			log.Printf("Branch %d has an invalid reminder schedule: %v", branch.ID, err)
+		}
+		schedules[branch.ID] = days
+		escalateAfter[branch.ID] = branch.EscalateAfterDays
+	}
+
+	var transactions []models.Transaction
+	corporate := db.Model(&models.CorporateAccount{}).Where("active = ?", true).Select("customer_id")
+	if err := db.Preload("Order").Joins("JOIN orders ON orders.id = transactions.order_id").
+		Where("transactions.payment_status = ? AND orders.customer_id NOT IN (?)", "unpaid", corporate).
+		Find(&transactions).Error; err != nil {
+		return 0, 0, err
+	}
+
+	var reminded, escalated int
+	for _, transaction := range transactions {
+		days := DaysSince(transaction.CreatedAt, now)
+
+		step := 0
+		for i, day := range schedules[transaction.BranchID] {
+			if day <= days {
+				step = i + 1
+			}
+		}
+		if step > 0 {
+			sent, err := sendReminder(db, transaction, step, days)
+			if err != nil {
+				return reminded, escalated, err
+			}
+			if sent {
+				reminded++
+			}
+		}
+
+		limit := escalateAfter[transaction.BranchID]
+		if limit > 0 && days >= limit && transaction.EscalatedAt == nil {
+			if err := db.Model(&transaction).Update("escalated_at", now).Error; err != nil {
+				return reminded, escalated, err
+			}
+			escalated++
+			alerts.Send(alerts.Alert{
+				Type:     "overdue_payment",
+				BranchID: transaction.BranchID,
+				Subject:  fmt.Sprintf("Transaction #%d is overdue", transaction.ID),
+				Message:  fmt.Sprintf("Transaction #%d for order #%d has been unpaid for %d days (%.2f).", transaction.ID, transaction.OrderID, days, transaction.TotalPrice),
+			})
+		}
+	}
+
+	return reminded, escalated, nil
+}
+
+// sendReminder queues the reminder for a step unless that step or a later
+// one was already sent.
+func sendReminder(db *gorm.DB, transaction models.Transaction, step, days int) (bool, error) {
+	var last int
+	if err := db.Model(&models.PaymentReminder{}).Where("transaction_id = ?", transaction.ID).
+		Select("COALESCE(MAX(step), 0)").Scan(&last).Error; err != nil {
+		return false, err
+	}
+	if last >= step {
+		return false, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		notification, err := notifications.Queue(tx, notifications.EventPaymentReminder, transaction.OrderID,
+			notifications.Data{DaysOverdue: days})
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PaymentReminder{
+			TransactionID:  transaction.ID,
+			Step:           step,
+			BranchID:       transaction.BranchID,
+			CustomerID:     transaction.Order.CustomerID,
+			DaysOverdue:    days,
+			Amount:         transaction.TotalPrice,
+			NotificationID: &notification.ID,
+		}).Error
+	})
+	return err == nil, err
+}
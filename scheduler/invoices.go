@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"fmt"
+	"laundre/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MonthStart returns the first day of t's month.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// RunMonthlyInvoices bills every active corporate account for the previous
+// month. Accounts that already have an invoice for the month are skipped, so
+// it is safe to run repeatedly.
+func RunMonthlyInvoices(db *gorm.DB, now time.Time) (int, error) {
+	invoices, err := GenerateInvoices(db, MonthStart(now).AddDate(0, -1, 0), now, 0, nil)
+	return len(invoices), err
+}
+
+// GenerateInvoices creates the invoice for the month starting at period for
+// one corporate account, or for all active accounts when accountID is zero.
+// An invoice collects every unpaid transaction made up to the end of the
+// month that is not on an invoice yet, so orders missed by earlier runs are
+// carried over. Accounts with nothing to bill get no invoice.
+func GenerateInvoices(db *gorm.DB, period, now time.Time, accountID uint, createdBy *uint) ([]models.Invoice, error) {
+	periodEnd := period.AddDate(0, 1, -1)
+	if !now.After(periodEnd) {
+		return nil, fmt.Errorf("the period %s has not ended yet", period.Format("2006-01"))
+	}
+
+	query := db.Where("active = ?", true)
+	if accountID != 0 {
+		query = query.Where("id = ?", accountID)
+	}
+	var accounts []models.CorporateAccount
+	if err := query.Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	issueDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	var created []models.Invoice
+	for _, account := range accounts {
+		var existing int64
+		if err := db.Model(&models.Invoice{}).Where("corporate_account_id = ? AND period_start = ? AND status <> ?", account.ID, period, "void").
+			Count(&existing).Error; err != nil {
+			return created, err
+		}
+		if existing > 0 {
+			continue
+		}
+
+		invoice := models.Invoice{
+			Number:             fmt.Sprintf("INV-%s-%04d", period.Format("200601"), account.ID),
+			CorporateAccountID: account.ID,
+			PeriodStart:        period,
+			PeriodEnd:          periodEnd,
+			IssueDate:          issueDate,
+			DueDate:            issueDate.AddDate(0, 0, account.PaymentTermsDays),
+			Status:             "issued",
+			CreatedBy:          createdBy,
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var transactions []models.Transaction
+			if err := tx.Preload("Order").
+				Joins("JOIN orders ON orders.id = transactions.order_id").
+				Where("orders.customer_id = ? AND transactions.payment_status = ? AND transactions.invoice_id IS NULL AND transactions.created_at < ?",
+					account.CustomerID, "unpaid", periodEnd.AddDate(0, 0, 1)).
+				Order("transactions.created_at asc").Find(&transactions).Error; err != nil {
+				return err
+			}
+			if len(transactions) == 0 {
+				return nil
+			}
+
+			ids := make([]uint, 0, len(transactions))
+			for _, transaction := range transactions {
+				description := fmt.Sprintf("Order #%d", transaction.OrderID)
+				if transaction.Order.Service != "" {
+					description += " - " + transaction.Order.Service
+				}
+				if transaction.Order.Weight > 0 {
+					description += fmt.Sprintf(" (%.2f kg)", transaction.Order.Weight)
+				}
+
+				invoice.Lines = append(invoice.Lines, models.InvoiceLine{
+					TransactionID: transaction.ID,
+					OrderID:       transaction.OrderID,
+					Date:          transaction.CreatedAt,
+					Description:   description,
+					Amount:        transaction.TotalPrice,
+				})
+				invoice.Total += transaction.TotalPrice
+				ids = append(ids, transaction.ID)
+			}
+
+			if err := tx.Omit("CorporateAccount").Create(&invoice).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Transaction{}).Where("id IN ?", ids).Update("invoice_id", invoice.ID).Error; err != nil {
+				return err
+			}
+
+			created = append(created, invoice)
+			return nil
+		})
+		if err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"fmt"
+	"laundre/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RunMaintenancePlans opens a task for every active plan that has come due
+// and returns how many tasks were created. A plan never has more than one
+// open task, so reruns are harmless.
+func RunMaintenancePlans(db *gorm.DB, now time.Time) (int, error) {
+	var plans []models.MaintenancePlan
+	if err := db.Preload("Machine").Where("active = ?", true).Find(&plans).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, plan := range plans {
+		if plan.Machine.Status == "retired" {
+			continue
+		}
+
+		var open int64
+		if err := db.Model(&models.MaintenanceTask{}).Where("plan_id = ? AND status = ?", plan.ID, "open").
+			Count(&open).Error; err != nil {
+			return created, err
+		}
+		if open > 0 {
+			continue
+		}
+
+		cycles, err := models.MachineCycles(db, plan.MachineID)
+		if err != nil {
+			return created, err
+		}
+		due, trigger := plan.Due(now, cycles)
+		if !due {
+			continue
+		}
+
+		planID := plan.ID
+		task := models.MaintenanceTask{
+			PlanID:    &planID,
+			MachineID: plan.MachineID,
+			BranchID:  plan.Machine.BranchID,
+			Title:     fmt.Sprintf("%s: %s", plan.Machine.Name, plan.Name),
+			Trigger:   trigger,
+			Status:    "open",
+			DueDate:   now,
+			Notes:     plan.Description,
+		}
+		if err := db.Create(&task).Error; err != nil {
+			return created, err
+		}
+		created++
+	}
+
+	return created, nil
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"laundre/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RunRecurringExpenses materializes every due occurrence of the active
+// recurring expense templates and returns how many expenses were created.
+// Occurrences that already have an expense are skipped, so reruns and
+// restarts never post the same period twice.
+func RunRecurringExpenses(db *gorm.DB, now time.Time) (int, error) {
+	var templates []models.RecurringExpense
+	if err := db.Where("active = ? AND start_date <= ?", true, now).Find(&templates).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, template := range templates {
+		for _, date := range template.Occurrences(now) {
+			occurrence := date
+			inserted := false
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var count int64
+				if err := tx.Model(&models.Expense{}).
+					Where("recurring_expense_id = ? AND occurrence_date = ?", template.ID, occurrence).
+					Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					return nil
+				}
+
+				expense := models.Expense{
+					BranchID:           template.BranchID,
+					CategoryID:         template.CategoryID,
+					Description:        template.Description,
+					Amount:             template.Amount,
+					Status:             "approved",
+					SubmittedBy:        &template.CreatedBy,
+					RecurringExpenseID: &template.ID,
+					OccurrenceDate:     &occurrence,
+					CreatedAt:          occurrence,
+				}
+				if err := tx.Create(&expense).Error; err != nil {
+					return err
+				}
+				if err := models.PostExpense(tx, expense); err != nil {
+					return err
+				}
+
+				inserted = true
+				return nil
+			})
+			if err != nil {
+				log.Printf("Recurring expense #%d for %s failed: %v", template.ID, occurrence.Format("2006-01-02"), err)
+				break
+			}
+			if inserted {
+				created++
+			}
+
+			if err := db.Model(&template).Update("last_run_date", occurrence).Error; err != nil {
+				return created, err
+			}
+		}
+	}
+
+	return created, nil
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"laundre/notifications"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Start launches the background jobs. Each job runs once at startup and then
+// again after every interval.
+func Start(db *gorm.DB) {
+	go every(time.Hour, func() {
+		created, err := RunRecurringExpenses(db, time.Now())
+		if err != nil {
+			log.Println("Recurring expenses failed:", err)
+		} else if created > 0 {
+			log.Printf("Recurring expenses: created %d expense(s)", created)
+		}
+	})
+
+	go every(time.Hour, func() {
+		created, err := RunMaintenancePlans(db, time.Now())
+		if err != nil {
+			log.Println("Maintenance plans failed:", err)
+		} else if created > 0 {
+			log.Printf("Maintenance plans: opened %d task(s)", created)
+		}
+	})
+
+	go every(time.Hour, func() {
+		reminded, escalated, err := RunPaymentReminders(db, time.Now())
+		if err != nil {
+			log.Println("Payment reminders failed:", err)
+		} else if reminded > 0 || escalated > 0 {
+			log.Printf("Payment reminders: sent %d, escalated %d", reminded, escalated)
+		}
+	})
+
+	go every(time.Hour, func() {
+		queued, err := RunAbandonedLaundry(db, time.Now())
+		if err != nil {
+			log.Println("Abandoned laundry notices failed:", err)
+		} else if queued > 0 {
+			log.Printf("Abandoned laundry: sent %d notice(s)", queued)
+		}
+	})
+
+	go every(time.Hour, func() {
+		created, err := RunMonthlyInvoices(db, time.Now())
+		if err != nil {
+			log.Println("Monthly invoices failed:", err)
+		} else if created > 0 {
+			log.Printf("Monthly invoices: issued %d invoice(s)", created)
+		}
+	})
+
+	go every(time.Minute, func() {
+		sent, failed, err := notifications.Dispatch(db, time.Now())
+		if err != nil {
+			log.Println("Customer notifications failed:", err)
+		} else if sent > 0 || failed > 0 {
+			log.Printf("Customer notifications: sent %d, gave up on %d", sent, failed)
+		}
+	})
+}
+
+func every(interval time.Duration, job func()) {
+	for {
+		job()
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "ap-southeast-1"
+	testBucket    = "receipts"
+)
+
+// fakeS3 is a stand-in for an S3-compatible server. It keeps objects in
+// memory and checks every request's SigV4 signature, computed here
+// independently of the client.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	now     time.Time
+}
+
+func newFakeS3(now time.Time) *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, types: map[string]string{}, now: now}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if err := f.verify(r, body); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) verify(r *http.Request, body []byte) error {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Signature") != "" {
+		return f.verifyPresigned(r, query)
+	}
+
+	auth := r.Header.Get("Authorization")
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		name, value, _ := strings.Cut(part, "=")
+		fields[name] = value
+	}
+	if fields["Signature"] == "" {
+		return errors.New("missing signature")
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	sum := sha256.Sum256(body)
+	if payloadHash != hex.EncodeToString(sum[:]) {
+		return errors.New("payload hash does not match the body")
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	var headers strings.Builder
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+
+	canonical := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), testCanonicalQuery(query), headers.String(), fields["SignedHeaders"], payloadHash,
+	}, "\n")
+	return f.check(fields["Credential"], r.Header.Get("X-Amz-Date"), canonical, fields["Signature"])
+}
+
+func (f *fakeS3) verifyPresigned(r *http.Request, query url.Values) error {
+	date, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date"))
+	if err != nil {
+		return err
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil {
+		return err
+	}
+	if f.now.After(date.Add(time.Duration(expires) * time.Second)) {
+		return errors.New("request has expired")
+	}
+
+	signature := query.Get("X-Amz-Signature")
+	query.Del("X-Amz-Signature")
+	canonical := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), testCanonicalQuery(query), "host:" + r.Host + "\n", query.Get("X-Amz-SignedHeaders"), "UNSIGNED-PAYLOAD",
+	}, "\n")
+	return f.check(query.Get("X-Amz-Credential"), query.Get("X-Amz-Date"), canonical, signature)
+}
+
+func (f *fakeS3) check(credential, amzDate, canonical, signature string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[0] != testAccessKey || parts[2] != testRegion || parts[3] != "s3" {
+		return errors.New("invalid credential " + credential)
+	}
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + strings.Join(parts[1:], "/") + "\n" + hex.EncodeToString(hash[:])
+	key := mac(mac(mac(mac([]byte("AWS4"+testSecretKey), parts[1]), parts[2]), "s3"), "aws4_request")
+	if hex.EncodeToString(mac(key, stringToSign)) != signature {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+func testCanonicalQuery(values url.Values) string {
+	var parts []string
+	for key, list := range values {
+		for _, value := range list {
+			parts = append(parts, url.QueryEscape(key)+"="+strings.ReplaceAll(url.QueryEscape(value), "+", "%20"))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "&")
+}
+
+func newTestS3(t *testing.T, secretKey string) (*S3, *fakeS3) {
+	t.Helper()
+	now := time.Date(2024, time.May, 24, 10, 30, 0, 0, time.UTC)
+	fake := newFakeS3(now)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store := NewS3(S3Config{
+		Endpoint:  server.URL + "/",
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: secretKey,
+	})
+	store.now = func() time.Time { return now }
+	return store, fake
+}
+
+func TestS3SaveOpenDelete(t *testing.T) {
+	keys := []string{
+		"expenses/2024/05/receipt.jpg",
+		"expenses/2024/05/nota toko #1 (copy).jpg",
+		"expenses/2024/05/kuitansi-ü+é.png",
+	}
+
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			store, fake := newTestS3(t, testSecretKey)
+			content := []byte("\x89PNG\r\n\x1a\nreceipt for " + key)
+
+			if err := store.Save(key, bytes.NewReader(content)); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			if got := fake.types["/"+testBucket+"/"+key]; got != "image/png" {
+				t.Errorf("stored content type = %q, want image/png", got)
+			}
+
+			file, err := store.Open(key)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			got, _ := io.ReadAll(file)
+			file.Close()
+			if !bytes.Equal(got, content) {
+				t.Fatalf("Open() = %q, want %q", got, content)
+			}
+
+			if err := store.Delete(key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err := store.Open(key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Open() after Delete() error = %v, want ErrNotFound", err)
+			}
+			if err := store.Delete(key); err != nil {
+				t.Fatalf("Delete() of a missing object error = %v", err)
+			}
+		})
+	}
+}
+
+func TestS3RejectsBadCredentials(t *testing.T) {
+	store, _ := newTestS3(t, "not-the-secret")
+	if err := store.Save("expenses/receipt.jpg", strings.NewReader("data")); err == nil {
+		t.Fatal("Save() with the wrong secret succeeded")
+	}
+}
+
+func TestS3PresignGet(t *testing.T) {
+	store, fake := newTestS3(t, testSecretKey)
+	key := "expenses/2024/05/nota toko.jpg"
+	if err := store.Save(key, strings.NewReader("receipt")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	link, err := store.PresignGet(key, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGet() error = %v", err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Query().Get("X-Amz-Expires"); got != "900" {
+		t.Errorf("X-Amz-Expires = %q, want 900", got)
+	}
+	if got := u.Query().Get("X-Amz-Credential"); got != testAccessKey+"/20240524/"+testRegion+"/s3/aws4_request" {
+		t.Errorf("X-Amz-Credential = %q", got)
+	}
+
+	tampered := *u
+	tampered.Path = strings.Replace(u.Path, "nota", "other", 1)
+	tampered.RawPath = ""
+	expired := fake.now.Add(16 * time.Minute)
+
+	tests := []struct {
+		name   string
+		link   string
+		now    time.Time
+		status int
+		body   string
+	}{
+		{name: "valid link", link: link, now: fake.now.Add(time.Minute), status: http.StatusOK, body: "receipt"},
+		{name: "other object", link: tampered.String(), now: fake.now, status: http.StatusForbidden},
+		{name: "expired link", link: link, now: expired, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.mu.Lock()
+			fake.now = tt.now
+			fake.mu.Unlock()
+
+			resp, err := http.Get(tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("GET status = %d, want %d (%s)", resp.StatusCode, tt.status, body)
+			}
+			if tt.body != "" && string(body) != tt.body {
+				t.Fatalf("GET body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
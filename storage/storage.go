@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+// Storage persists uploaded files under opaque keys such as
+// "expenses/12/3f9a.jpg".
+type Storage interface {
+	Save(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Presigner is implemented by backends that can hand out time-limited
+// download URLs themselves. Files in other backends are served through the
+// API's own signed links.
+type Presigner interface {
+	PresignGet(key string, ttl time.Duration) (string, error)
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"laundre/config"
+	"log"
+)
+
+var fileURLSecret = loadFileURLSecret()
+
+// loadFileURLSecret falls back to a random key when none is configured, so
+// signed links stop working after a restart.
+func loadFileURLSecret() []byte {
+	if secret := config.FileURLSecret(); secret != "" {
+		return []byte(secret)
+	}
+
+	log.Println("FILE_URL_SECRET is not set, signed file links will not survive a restart")
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
+
+// SignFile returns the signature of a download link for an attachment
+// variant that is valid until the expires Unix timestamp.
+func SignFile(attachmentID uint, variant string, expires int64) string {
+	mac := hmac.New(sha256.New, fileURLSecret)
+	fmt.Fprintf(mac, "%d:%s:%d", attachmentID, variant, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifyFile(attachmentID uint, variant string, expires int64, signature string) bool {
+	expected := SignFile(attachmentID, variant, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "budi", b: "", want: 4},
+		{a: "", b: "budi", want: 4},
+		{a: "budi", b: "budi", want: 0},
+		{a: "budi", b: "budy", want: 1},
+		{a: "budi", b: "bud", want: 1},
+		{a: "budi", b: "abudi", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "santoso", b: "santsoo", want: 2},
+		{a: "siti", b: "situ", want: 1},
+		{a: "józef", b: "jozef", want: 1},
+		{a: "ありがとう", b: "ありがと", want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{a: "", b: "", want: 1},
+		{a: "budi", b: "budi", want: 1},
+		{a: "budi", b: "budy", want: 0.75},
+		{a: "abcd", b: "wxyz", want: 0},
+		{a: "józef", b: "jozef", want: 0.8},
+	}
+
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Budi  Santoso.", want: "budi santoso"},
+		{name: "  BUDI-santoso ", want: "budi santoso"},
+		{name: "Toko Jaya 2", want: "toko jaya 2"},
+		{name: "...", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeName(tt.name); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
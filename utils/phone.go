@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone converts a phone number to E.164, e.g. "0812-3456 789" and
+// "62 812 3456 789" both become "+628123456789". Numbers without a country
+// code are taken to be Indonesian.
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+") || strings.HasPrefix(phone, "00")
+
+	var digits strings.Builder
+	for _, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' || r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case international:
+		number = strings.TrimPrefix(number, "00")
+	case strings.HasPrefix(number, "62"):
+	case strings.HasPrefix(number, "0"):
+		number = "62" + number[1:]
+	default:
+		number = "62" + number
+	}
+
+	// E.164 allows at most 15 digits; anything under 8 cannot be a
+	// subscriber number with its country code.
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return "+" + number, nil
+}
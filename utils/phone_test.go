@@ -0,0 +1,37 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone   string
+		want    string
+		wantErr bool
+	}{
+		{phone: "0812-3456 789", want: "+628123456789"},
+		{phone: "62 812 3456 789", want: "+628123456789"},
+		{phone: "+62 812.3456.789", want: "+628123456789"},
+		{phone: "  (0812) 3456-789  ", want: "+628123456789"},
+		{phone: "8123456789", want: "+628123456789"},
+		{phone: "0062 812 3456 789", want: "+628123456789"},
+		{phone: "+1 (555) 123-4567", want: "+15551234567"},
+		{phone: "0044 20 7946 0958", want: "+442079460958"},
+		{phone: "", wantErr: true},
+		{phone: "0812", wantErr: true},
+		{phone: "0812-3456-789 ext 2", wantErr: true},
+		{phone: "+0812345678", wantErr: true},
+		{phone: "+1234567890123456", wantErr: true},
+		{phone: "０８１２３４５６７８９", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.phone)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizePhone(%q) error = %v, wantErr %v", tt.phone, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, want %q", tt.phone, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rupiah formats an amount with dots between thousands, e.g. 125.000.
+func Rupiah(amount float64) string {
+	digits := fmt.Sprintf("%.0f", amount)
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	if negative {
+		return "-" + b.String()
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withDimensions rewrites the size declared in a PNG header, leaving the
+// pixel data as it is.
+func withDimensions(data []byte, width, height uint32) []byte {
+	out := append([]byte(nil), data...)
+	// Signature (8), chunk length (4), "IHDR" (4), then width and height.
+	binary.BigEndian.PutUint32(out[16:], width)
+	binary.BigEndian.PutUint32(out[20:], height)
+	binary.BigEndian.PutUint32(out[29:], crc32.ChecksumIEEE(out[12:29]))
+	return out
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		maxSize    int
+		wantWidth  int
+		wantHeight int
+		wantErr    error
+	}{
+		{name: "landscape is scaled down", data: encodePNG(t, 400, 200), maxSize: 100, wantWidth: 100, wantHeight: 50},
+		{name: "portrait is scaled down", data: encodePNG(t, 120, 480), maxSize: 100, wantWidth: 25, wantHeight: 100},
+		{name: "small image keeps its size", data: encodePNG(t, 40, 30), maxSize: 100, wantWidth: 40, wantHeight: 30},
+		{name: "huge declared size is rejected", data: withDimensions(encodePNG(t, 1, 1), 100000, 100000), maxSize: 100, wantErr: ErrImageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Thumbnail(bytes.NewReader(tt.data), tt.maxSize)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Thumbnail() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Thumbnail() error = %v", err)
+			}
+
+			config, err := jpeg.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("thumbnail is not a JPEG: %v", err)
+			}
+			if config.Width != tt.wantWidth || config.Height != tt.wantHeight {
+				t.Fatalf("thumbnail is %dx%d, want %dx%d", config.Width, config.Height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+
+	if _, err := Thumbnail(bytes.NewReader([]byte("not an image")), 100); err == nil {
+		t.Fatal("Thumbnail() accepted data that is not an image")
+	}
+}